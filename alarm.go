@@ -0,0 +1,318 @@
+package dynamo
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/crowdmob/goamz/cloudwatch"
+)
+
+const (
+	// DynamoDB CloudWatch metrics.
+	MetricConsumedReadCapacity  = "ConsumedReadCapacityUnits"
+	MetricConsumedWriteCapacity = "ConsumedWriteCapacityUnits"
+	MetricReadThrottleEvents    = "ReadThrottleEvents"
+	MetricWriteThrottleEvents   = "WriteThrottleEvents"
+	MetricThrottledRequests     = "ThrottledRequests"
+	MetricSystemErrors          = "SystemErrors"
+	MetricUserErrors            = "UserErrors"
+	MetricLatency               = "SuccessfulRequestLatency"
+	MetricReturnedItemCount     = "ReturnedItemCount"
+
+	// Alarm comparison operators.
+	ComparisonGreaterThan        = "GreaterThanThreshold"
+	ComparisonGreaterThanOrEqual = "GreaterThanOrEqualToThreshold"
+	ComparisonLessThan           = "LessThanThreshold"
+	ComparisonLessThanOrEqual    = "LessThanOrEqualToThreshold"
+
+	// Metric statistics.
+	StatisticSum         = "Sum"
+	StatisticAverage     = "Average"
+	StatisticMaximum     = "Maximum"
+	StatisticMinimum     = "Minimum"
+	StatisticSampleCount = "SampleCount"
+
+	cloudWatchNamespace  = "AWS/DynamoDB"
+	cloudWatchAPIVersion = "2010-08-01"
+	alarmPrefix          = "dynamo/" // Names of alarms created by PutAlarms start with this and the table name.
+)
+
+// AlarmSpec is how alarms fire and who they notify. The zero value evaluates the Sum over one 5 minute period and
+// notifies nobody.
+type AlarmSpec struct {
+	Actions           []string // ARNs, i.e. of SNS topics, notified when the alarm fires.
+	OKActions         []string // ARNs notified when the alarm recovers.
+	Period            int      // In seconds, a multiple of 60.
+	EvaluationPeriods int
+	Statistic         string
+	Comparison        string
+}
+
+// Alarm is an alarm on a metric of a table or one of its global secondary indexes.
+type Alarm struct {
+	Name      string // Generated by AlarmName if empty.
+	Table     string
+	Index     string // Global secondary index, empty for the table itself.
+	Metric    string
+	Operation string // Only used with MetricSystemErrors and MetricLatency, i.e. "PutItem".
+	Threshold float64
+}
+
+// AlarmInfo is an alarm as returned by ListAlarms.
+type AlarmInfo struct {
+	Alarm
+	AlarmSpec
+	State       string // "OK", "ALARM" or "INSUFFICIENT_DATA".
+	StateReason string
+}
+
+// AlarmName returns the name PutAlarms gives an alarm without one, i.e. "dynamo/users/by-email/ReadThrottleEvents".
+func AlarmName(a Alarm) string {
+	parts := []string{a.Table}
+	if len(a.Index) > 0 {
+		parts = append(parts, a.Index)
+	}
+	parts = append(parts, a.Metric)
+	if len(a.Operation) > 0 {
+		parts = append(parts, a.Operation)
+	}
+	return alarmPrefix + strings.Join(parts, "/")
+}
+
+// PutAlarms creates the alarms, or updates them if alarms of the same name exist.
+func (c *Client) PutAlarms(alarms []Alarm, spec AlarmSpec) error {
+	if len(spec.Statistic) == 0 {
+		spec.Statistic = StatisticSum
+	}
+	if len(spec.Comparison) == 0 {
+		spec.Comparison = ComparisonGreaterThan
+	}
+	if spec.Period == 0 {
+		spec.Period = 60 * 5
+	}
+	if spec.EvaluationPeriods == 0 {
+		spec.EvaluationPeriods = 1
+	}
+	if spec.Period%60 != 0 {
+		return fmt.Errorf("Alarm period must be a multiple of 60 seconds, was %d", spec.Period)
+	}
+	metricAlarms := make([]cloudwatch.MetricAlarm, len(alarms))
+	for i, a := range alarms {
+		if len(a.Table) == 0 || len(a.Metric) == 0 {
+			return errors.New("Alarms need a table and metric")
+		}
+		if len(a.Name) == 0 {
+			a.Name = AlarmName(a)
+		}
+		metricAlarms[i] = cloudwatch.MetricAlarm{
+			AlarmName:          a.Name,
+			ComparisonOperator: spec.Comparison,
+			Namespace:          cloudWatchNamespace,
+			Statistic:          spec.Statistic,
+			AlarmActions:       alarmActions(spec.Actions),
+			OkActions:          alarmActions(spec.OKActions),
+			Period:             spec.Period,
+			EvaluationPeriods:  spec.EvaluationPeriods,
+			MetricName:         a.Metric,
+			Threshold:          a.Threshold,
+			Dimensions:         metricDimensions(a.Table, a.Index, a.Operation),
+		}
+	}
+	cw, err := c.cloudWatch()
+	if err != nil {
+		return err
+	}
+	wg := sync.WaitGroup{}
+	errs := make([]error, len(metricAlarms))
+	for i := range metricAlarms {
+		wg.Add(1)
+		go func(i int) {
+			_, errs[i] = cw.PutMetricAlarm(&metricAlarms[i])
+			wg.Done()
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListAlarms returns the alarms PutAlarms created for table.
+func (c *Client) ListAlarms(table string) ([]AlarmInfo, error) {
+	alarms := []AlarmInfo{}
+	params := map[string]string{
+		"Action":          "DescribeAlarms",
+		"AlarmNamePrefix": alarmPrefix + table + "/",
+	}
+	for {
+		res := describeAlarmsResponse{}
+		if err := c.cloudWatchQuery(params, &res); err != nil {
+			return alarms, err
+		}
+		for _, a := range res.MetricAlarms {
+			alarms = append(alarms, a.info())
+		}
+		if len(res.NextToken) == 0 {
+			return alarms, nil
+		}
+		params["NextToken"] = res.NextToken
+	}
+}
+
+// DeleteAlarms deletes the alarms with the given names, at most 100 at a time.
+func (c *Client) DeleteAlarms(names ...string) error {
+	if len(names) == 0 {
+		return nil
+	} else if len(names) > 100 {
+		return errors.New("Maximum of 100 alarms can be deleted at a time")
+	}
+	params := map[string]string{"Action": "DeleteAlarms"}
+	for i, name := range names {
+		params["AlarmNames.member."+strconv.Itoa(i+1)] = name
+	}
+	return c.cloudWatchQuery(params, nil)
+}
+
+// DeleteTableAlarms deletes every alarm PutAlarms created for table, as well as the alarms named <table>-ReadAlarm and
+// <table>-WriteAlarm that AddAlarms created before it used PutAlarms.
+func (c *Client) DeleteTableAlarms(table string) error {
+	alarms, err := c.ListAlarms(table)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(alarms))
+	for i, a := range alarms {
+		names[i] = a.Name
+	}
+	legacy, err := c.legacyAlarms(table)
+	if err != nil {
+		return err
+	}
+	names = append(names, legacy...)
+	for len(names) > 0 {
+		n := len(names)
+		if n > 100 {
+			n = 100
+		}
+		if err := c.DeleteAlarms(names[:n]...); err != nil {
+			return err
+		}
+		names = names[n:]
+	}
+	return nil
+}
+
+// legacyAlarms returns the names of the alarms of table that AddAlarms named <table>-ReadAlarm and
+// <table>-WriteAlarm, which exist. DeleteAlarms deletes nothing if any name doesn't.
+func (c *Client) legacyAlarms(table string) ([]string, error) {
+	params := map[string]string{
+		"Action":              "DescribeAlarms",
+		"AlarmNames.member.1": table + "-ReadAlarm",
+		"AlarmNames.member.2": table + "-WriteAlarm",
+	}
+	res := describeAlarmsResponse{}
+	if err := c.cloudWatchQuery(params, &res); err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, a := range res.MetricAlarms {
+		if a.AlarmName == params["AlarmNames.member.1"] || a.AlarmName == params["AlarmNames.member.2"] {
+			names = append(names, a.AlarmName)
+		}
+	}
+	return names, nil
+}
+
+// cloudWatchQuery makes a CloudWatch request that the cloudwatch package doesn't support, decoding the XML result
+// into dst unless it's nil.
+func (c *Client) cloudWatchQuery(params map[string]string, dst interface{}) error {
+	params["Version"] = cloudWatchAPIVersion
+	cw, err := c.cloudWatch()
+	if err != nil {
+		return err
+	}
+	res, err := cw.Service.Query("GET", "/", params)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return cw.Service.BuildError(res)
+	} else if dst == nil {
+		return nil
+	}
+	return xml.NewDecoder(res.Body).Decode(dst)
+}
+
+func alarmActions(arns []string) []cloudwatch.AlarmAction {
+	actions := make([]cloudwatch.AlarmAction, len(arns))
+	for i, arn := range arns {
+		actions[i] = cloudwatch.AlarmAction{ARN: arn}
+	}
+	return actions
+}
+
+func metricDimensions(table, index, operation string) []cloudwatch.Dimension {
+	dims := []cloudwatch.Dimension{{Name: "TableName", Value: table}}
+	if len(index) > 0 {
+		dims = append(dims, cloudwatch.Dimension{Name: "GlobalSecondaryIndexName", Value: index})
+	}
+	if len(operation) > 0 {
+		dims = append(dims, cloudwatch.Dimension{Name: "Operation", Value: operation})
+	}
+	return dims
+}
+
+type describeAlarmsResponse struct {
+	MetricAlarms []describedAlarm `xml:"DescribeAlarmsResult>MetricAlarms>member"`
+	NextToken    string           `xml:"DescribeAlarmsResult>NextToken"`
+}
+
+type describedAlarm struct {
+	AlarmName          string
+	ComparisonOperator string
+	Dimensions         []cloudwatch.Dimension `xml:"Dimensions>member"`
+	EvaluationPeriods  int
+	MetricName         string
+	Period             int
+	Statistic          string
+	Threshold          float64
+	StateValue         string
+	StateReason        string
+	AlarmActions       []string `xml:"AlarmActions>member"`
+	OKActions          []string `xml:"OKActions>member"`
+}
+
+func (a describedAlarm) info() AlarmInfo {
+	info := AlarmInfo{
+		Alarm: Alarm{Name: a.AlarmName, Metric: a.MetricName, Threshold: a.Threshold},
+		AlarmSpec: AlarmSpec{
+			Actions:           a.AlarmActions,
+			OKActions:         a.OKActions,
+			Period:            a.Period,
+			EvaluationPeriods: a.EvaluationPeriods,
+			Statistic:         a.Statistic,
+			Comparison:        a.ComparisonOperator,
+		},
+		State:       a.StateValue,
+		StateReason: a.StateReason,
+	}
+	for _, d := range a.Dimensions {
+		switch d.Name {
+		case "TableName":
+			info.Table = d.Value
+		case "GlobalSecondaryIndexName":
+			info.Index = d.Value
+		case "Operation":
+			info.Operation = d.Value
+		}
+	}
+	return info
+}
@@ -0,0 +1,109 @@
+package dynamo
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakeCloudWatch struct {
+	params []map[string]string
+	body   string
+}
+
+func (f *fakeCloudWatch) Query(method, path string, params map[string]string) (*http.Response, error) {
+	f.params = append(f.params, params)
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(f.body))}, nil
+}
+
+func (f *fakeCloudWatch) BuildError(r *http.Response) error {
+	return errors.New("CloudWatch error")
+}
+
+func TestListAlarms(t *testing.T) {
+	c, s := newTestClient(nil)
+	defer s.Close()
+	cw := &fakeCloudWatch{body: `<DescribeAlarmsResponse><DescribeAlarmsResult><MetricAlarms><member>
+		<AlarmName>dynamo/users/by-email/ReadThrottleEvents</AlarmName>
+		<MetricName>ReadThrottleEvents</MetricName>
+		<Dimensions>
+			<member><Name>TableName</Name><Value>users</Value></member>
+			<member><Name>GlobalSecondaryIndexName</Name><Value>by-email</Value></member>
+		</Dimensions>
+		<Period>60</Period><EvaluationPeriods>2</EvaluationPeriods><Threshold>10</Threshold>
+		<AlarmActions><member>arn:aws:sns:eu-west-1:123:ops</member></AlarmActions>
+		<StateValue>ALARM</StateValue>
+	</member></MetricAlarms></DescribeAlarmsResult></DescribeAlarmsResponse>`}
+	c.cw.Service = cw
+
+	alarms, err := c.ListAlarms("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cw.params[0]["AlarmNamePrefix"] != "dynamo/users/" {
+		t.Errorf("Unexpected prefix %q", cw.params[0]["AlarmNamePrefix"])
+	}
+	a := alarms[0]
+	if len(alarms) != 1 || a.Table != "users" || a.Index != "by-email" || a.State != "ALARM" || a.Actions[0] != "arn:aws:sns:eu-west-1:123:ops" {
+		t.Errorf("Unexpected alarms %+v", alarms)
+	}
+	if AlarmName(a.Alarm) != a.Name {
+		t.Errorf("Expected generated name %q, got %q", a.Name, AlarmName(a.Alarm))
+	}
+
+	cw.body = ""
+	if err := c.DeleteAlarms(a.Name); err != nil {
+		t.Fatal(err)
+	}
+	if p := cw.params[1]; p["Action"] != "DeleteAlarms" || p["AlarmNames.member.1"] != a.Name {
+		t.Errorf("Unexpected delete params %v", p)
+	}
+}
+
+// queryCloudWatch answers CloudWatch queries with a body chosen by their parameters.
+type queryCloudWatch struct {
+	mu     sync.Mutex
+	params []map[string]string
+	body   func(params map[string]string) string
+}
+
+func (f *queryCloudWatch) Query(method, path string, params map[string]string) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.params = append(f.params, params)
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(f.body(params)))}, nil
+}
+
+func (f *queryCloudWatch) BuildError(r *http.Response) error {
+	return errors.New("CloudWatch error")
+}
+
+func TestDeleteTableAlarms(t *testing.T) {
+	c, s := newTestClient(nil)
+	defer s.Close()
+	cw := &queryCloudWatch{body: func(params map[string]string) string {
+		name := "dynamo/users/ReadThrottleEvents"
+		if params["Action"] != "DescribeAlarms" {
+			return ""
+		} else if len(params["AlarmNamePrefix"]) == 0 {
+			name = "users-WriteAlarm"
+		}
+		return `<DescribeAlarmsResponse><DescribeAlarmsResult><MetricAlarms><member><AlarmName>` + name +
+			`</AlarmName></member></MetricAlarms></DescribeAlarmsResult></DescribeAlarmsResponse>`
+	}}
+	c.cw.Service = cw
+
+	if err := c.DeleteTableAlarms("users"); err != nil {
+		t.Fatal(err)
+	}
+	if p := cw.params[1]; p["AlarmNames.member.1"] != "users-ReadAlarm" || p["AlarmNames.member.2"] != "users-WriteAlarm" {
+		t.Errorf("Unexpected legacy alarm params %v", p)
+	}
+	if p := cw.params[2]; p["Action"] != "DeleteAlarms" || p["AlarmNames.member.1"] != "dynamo/users/ReadThrottleEvents" ||
+		p["AlarmNames.member.2"] != "users-WriteAlarm" || len(p["AlarmNames.member.3"]) > 0 {
+		t.Errorf("Unexpected delete params %v", p)
+	}
+}
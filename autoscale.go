@@ -0,0 +1,243 @@
+package dynamo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTargetUtilization = 70
+	defaultScalingWindow     = 5 * time.Minute
+	defaultDecreasesPerDay   = 4
+	defaultDecreaseCooldown  = time.Hour
+	scaleDownMargin          = 0.8 // Only scale down if the new capacity is below this fraction of the current one.
+)
+
+// MetricsSource reports the capacity a table consumes. It's CloudWatch by default, and can be faked in tests.
+type MetricsSource interface {
+	// ConsumedCapacity returns the highest read and write units per second consumed by table over the window.
+	ConsumedCapacity(table string, window time.Duration) (read, write float64, err error)
+}
+
+// ScalingPolicy is how the autoscaler scales a table. Capacity is kept between the minimum and maximum units, so
+// that the consumed capacity is TargetUtilization percent of it.
+type ScalingPolicy struct {
+	MinRead, MaxRead   int
+	MinWrite, MaxWrite int
+	TargetUtilization  float64       // Percent, defaults to 70.
+	Window             time.Duration // Of consumed capacity considered, defaults to 5 minutes.
+}
+
+// ScalingDecision is the outcome of checking a table, passed to Autoscaler.Log.
+type ScalingDecision struct {
+	Time                        time.Time
+	Table                       string
+	ConsumedRead, ConsumedWrite float64
+	Current                     Throughput
+	NewRead, NewWrite           int
+	Changed                     bool // Whether the throughput should change.
+	Applied                     bool // Whether it was changed, false in dry-run mode.
+	Reason                      string
+	Err                         error
+}
+
+func (d ScalingDecision) String() string {
+	s := fmt.Sprintf("%s: consumed %.1f/%.1f, provisioned %d/%d", d.Table, d.ConsumedRead, d.ConsumedWrite, d.Current.ReadUnits, d.Current.WriteUnits)
+	if d.Changed {
+		s += fmt.Sprintf(", scaling to %d/%d", d.NewRead, d.NewWrite)
+		if !d.Applied {
+			s += " (dry run)"
+		}
+	}
+	if len(d.Reason) > 0 {
+		s += ": " + d.Reason
+	}
+	if d.Err != nil {
+		s += ": " + d.Err.Error()
+	}
+	return s
+}
+
+// Autoscaler periodically compares the consumed capacity of tables to their provisioned throughput and changes it to
+// match their ScalingPolicy. Increases are applied right away. Decreases wait for the consumed capacity to drop well
+// below the current capacity, and respect DynamoDB's daily limit on decreases.
+type Autoscaler struct {
+	Metrics          MetricsSource
+	DryRun           bool                  // Log decisions without changing throughput.
+	Log              func(ScalingDecision) // Called with every decision, if set.
+	DecreasesPerDay  int                   // Defaults to 4, DynamoDB's limit.
+	DecreaseCooldown time.Duration         // Between decreases, defaults to an hour.
+
+	c        *Client
+	mu       sync.Mutex
+	policies map[string]ScalingPolicy
+	now      func() time.Time
+}
+
+// NewAutoscaler returns an autoscaler reading consumed capacity from CloudWatch.
+func NewAutoscaler(c *Client) *Autoscaler {
+	return &Autoscaler{
+		Metrics:  cloudWatchSource{c},
+		c:        c,
+		policies: map[string]ScalingPolicy{},
+		now:      time.Now,
+	}
+}
+
+// Add scales table according to p, replacing any previous policy for it.
+func (a *Autoscaler) Add(table string, p ScalingPolicy) error {
+	if p.MinRead <= 0 || p.MinWrite <= 0 || p.MaxRead < p.MinRead || p.MaxWrite < p.MinWrite {
+		return errors.New("Scaling policy needs minimums above 0 and maximums at least as high")
+	} else if p.TargetUtilization < 0 || p.TargetUtilization > 100 {
+		return fmt.Errorf("Target utilization must be a percentage, was %v", p.TargetUtilization)
+	}
+	if p.TargetUtilization == 0 {
+		p.TargetUtilization = defaultTargetUtilization
+	}
+	if p.Window == 0 {
+		p.Window = defaultScalingWindow
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.policies[table] = p
+	return nil
+}
+
+// Remove stops scaling table.
+func (a *Autoscaler) Remove(table string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.policies, table)
+}
+
+// Run checks every table each interval until stop is closed.
+func (a *Autoscaler) Run(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		a.Check()
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Check checks every table once, returning the decisions made.
+func (a *Autoscaler) Check() []ScalingDecision {
+	a.mu.Lock()
+	policies := make(map[string]ScalingPolicy, len(a.policies))
+	for t, p := range a.policies {
+		policies[t] = p
+	}
+	a.mu.Unlock()
+	decisions := []ScalingDecision{}
+	for table, p := range policies {
+		d := a.check(table, p)
+		if a.Log != nil {
+			a.Log(d)
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions
+}
+
+func (a *Autoscaler) check(table string, p ScalingPolicy) ScalingDecision {
+	d := ScalingDecision{Time: a.now(), Table: table}
+	desc, err := a.c.DescribeTable(table)
+	if err != nil {
+		d.Err = err
+		return d
+	}
+	d.Current = desc.ProvisionedThroughput
+	if desc.TableStatus != "ACTIVE" {
+		d.Reason = "table is " + desc.TableStatus
+		return d
+	}
+	d.ConsumedRead, d.ConsumedWrite, err = a.Metrics.ConsumedCapacity(table, p.Window)
+	if err != nil {
+		d.Err = err
+		return d
+	}
+	d.NewRead = a.desired(d.ConsumedRead, d.Current.ReadUnits, p.MinRead, p.MaxRead, p.TargetUtilization)
+	d.NewWrite = a.desired(d.ConsumedWrite, d.Current.WriteUnits, p.MinWrite, p.MaxWrite, p.TargetUtilization)
+	if (d.NewRead < d.Current.ReadUnits || d.NewWrite < d.Current.WriteUnits) && !a.canDecrease(d.Current, d.Time) {
+		d.NewRead = maxInt(d.NewRead, d.Current.ReadUnits)
+		d.NewWrite = maxInt(d.NewWrite, d.Current.WriteUnits)
+		d.Reason = "decrease limit reached"
+	}
+	d.Changed = d.NewRead != d.Current.ReadUnits || d.NewWrite != d.Current.WriteUnits
+	if !d.Changed || a.DryRun {
+		return d
+	}
+	if d.Err = a.c.ChangeThroughput(table, d.NewRead, d.NewWrite); d.Err == nil {
+		d.Applied = true
+	}
+	return d
+}
+
+// desired returns the capacity for consumed units at the target utilization, within the policy limits. Capacity is
+// only lowered if the consumed units dropped well below it, so it doesn't flap around the target.
+func (a *Autoscaler) desired(consumed float64, current, min, max int, target float64) int {
+	want := int(math.Ceil(consumed / (target / 100)))
+	if want < current && float64(want) > float64(current)*scaleDownMargin {
+		want = current
+	}
+	if want < min {
+		want = min
+	} else if want > max {
+		want = max
+	}
+	return want
+}
+
+// canDecrease reports whether DynamoDB will allow another decrease: the decreases today (UTC) must be below the limit
+// and the last decrease must be longer ago than the cooldown.
+func (a *Autoscaler) canDecrease(t Throughput, now time.Time) bool {
+	limit, cooldown := a.DecreasesPerDay, a.DecreaseCooldown
+	if limit == 0 {
+		limit = defaultDecreasesPerDay
+	}
+	if cooldown == 0 {
+		cooldown = defaultDecreaseCooldown
+	}
+	if t.LastDecreaseDateTime == 0 {
+		return true
+	}
+	last := time.Unix(int64(t.LastDecreaseDateTime), 0).UTC()
+	if now.Sub(last) < cooldown {
+		return false
+	}
+	today := now.UTC().Truncate(24 * time.Hour)
+	return last.Before(today) || t.NumberOfDecreasesToday < limit
+}
+
+// cloudWatchSource reads consumed capacity from CloudWatch.
+type cloudWatchSource struct {
+	c *Client
+}
+
+func (s cloudWatchSource) ConsumedCapacity(table string, window time.Duration) (read, write float64, err error) {
+	end := time.Now().UTC().Truncate(time.Minute)
+	m := &TableMetrics{Table: table, Start: end.Add(-window), End: end, Period: 60}
+	r, err := s.c.metricSeries(m, MetricConsumedReadCapacity, StatisticSum, "", "", 1.0/60)
+	if err != nil {
+		return 0, 0, err
+	}
+	w, err := s.c.metricSeries(m, MetricConsumedWriteCapacity, StatisticSum, "", "", 1.0/60)
+	if err != nil {
+		return 0, 0, err
+	}
+	return r.Max(), w.Max(), nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
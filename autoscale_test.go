@@ -0,0 +1,71 @@
+package dynamo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type fakeMetrics struct {
+	read, write float64
+}
+
+func (f fakeMetrics) ConsumedCapacity(table string, window time.Duration) (float64, float64, error) {
+	return f.read, f.write, nil
+}
+
+func TestAutoscaler(t *testing.T) {
+	now := time.Date(2014, 3, 1, 12, 0, 0, 0, time.UTC)
+	throughput := Throughput{ReadUnits: 100, WriteUnits: 10}
+	var changed *TableRequest
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Amz-Target") == DynamoBaseEndpoint+UpdateTableEndpoint {
+			changed = &TableRequest{}
+			json.Unmarshal(b, changed)
+			w.Write([]byte(`{}`))
+			return
+		}
+		json.NewEncoder(w).Encode(TableDescriptionWrapper{Table: TableDescription{
+			TableName:             "things",
+			TableStatus:           "ACTIVE",
+			ProvisionedThroughput: throughput,
+		}})
+	})
+	defer s.Close()
+	a := NewAutoscaler(c)
+	a.now = func() time.Time { return now }
+	a.Add("things", ScalingPolicy{MinRead: 5, MaxRead: 1000, MinWrite: 5, MaxWrite: 50, TargetUtilization: 50})
+
+	// Reads dropped and writes need more than the maximum.
+	a.Metrics = fakeMetrics{read: 20, write: 40}
+	a.DryRun = true
+	d := a.Check()[0]
+	if !d.Changed || d.Applied || d.NewRead != 40 || d.NewWrite != 50 || changed != nil {
+		t.Errorf("Unexpected dry run decision %v", d)
+	}
+
+	a.DryRun = false
+	d = a.Check()[0]
+	if !d.Applied || changed == nil || changed.ProvisionedThroughput.ReadUnits != 40 || changed.ProvisionedThroughput.WriteUnits != 50 {
+		t.Errorf("Expected throughput to change to 40/50, decision was %v", d)
+	}
+
+	// The daily limit of decreases is used up, so only increases go through.
+	changed = nil
+	throughput.LastDecreaseDateTime = float64(now.Add(-2 * time.Hour).Unix())
+	throughput.NumberOfDecreasesToday = 4
+	d = a.Check()[0]
+	if d.NewRead != 100 || d.NewWrite != 50 || d.Reason != "decrease limit reached" {
+		t.Errorf("Expected only writes to increase, decision was %v", d)
+	}
+
+	// Consumption close to the target doesn't scale down.
+	throughput = Throughput{ReadUnits: 100, WriteUnits: 10}
+	a.Metrics = fakeMetrics{read: 45, write: 5}
+	if d = a.Check()[0]; d.Changed {
+		t.Errorf("Expected no change, decision was %v", d)
+	}
+}
@@ -0,0 +1,121 @@
+package dynamo
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	defaultWaitInterval = 20 * time.Second
+	defaultWaitTimeout  = time.Hour
+)
+
+// ErrWaitTimeout is returned by WaitForRestore when the restore didn't complete in time.
+var ErrWaitTimeout = errors.New("Timed out waiting for the restore to complete")
+
+// WaitOptions configure WaitForRestore. The zero value polls every 20 seconds for up to an hour.
+type WaitOptions struct {
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// CreateBackup starts an on-demand backup of a table, which is CREATING until it's AVAILABLE, see DescribeBackup.
+func (c *Client) CreateBackup(table, name string) (BackupDetails, error) {
+	res := CreateBackupResponse{}
+	err := c.makeRequest(CreateBackupEndpoint, CreateBackupRequest{TableName: table, BackupName: name}, &res)
+	return res.BackupDetails, err
+}
+
+// ListBackups returns the backups matching req, reading every page from req.ExclusiveStartBackupArn. The backups of
+// every table are listed if req.TableName is empty, and only user backups unless req.BackupType says otherwise.
+func (c *Client) ListBackups(req ListBackupsRequest) ([]BackupSummary, error) {
+	backups := []BackupSummary{}
+	for {
+		res := ListBackupsResponse{}
+		if err := c.makeRequest(ListBackupsEndpoint, req, &res); err != nil {
+			return nil, err
+		}
+		backups = append(backups, res.BackupSummaries...)
+		if len(res.LastEvaluatedBackupArn) == 0 {
+			return backups, nil
+		}
+		req.ExclusiveStartBackupArn = res.LastEvaluatedBackupArn
+	}
+}
+
+func (c *Client) DescribeBackup(arn string) (BackupDescription, error) {
+	res := BackupResponse{}
+	err := c.makeRequest(DescribeBackupEndpoint, BackupRequest{BackupArn: arn}, &res)
+	return res.BackupDescription, err
+}
+
+// DeleteBackup deletes a backup, returning its description.
+func (c *Client) DeleteBackup(arn string) (BackupDescription, error) {
+	res := BackupResponse{}
+	err := c.makeRequest(DeleteBackupEndpoint, BackupRequest{BackupArn: arn}, &res)
+	return res.BackupDescription, err
+}
+
+// RestoreTableFromBackup starts restoring a backup to a new table, see WaitForRestore.
+func (c *Client) RestoreTableFromBackup(table, arn string) (TableDescription, error) {
+	res := TableDescriptionWrapper{}
+	err := c.makeRequest(RestoreTableFromBackupEndpoint, RestoreTableFromBackupRequest{TargetTableName: table, BackupArn: arn}, &res)
+	return res.Description, err
+}
+
+// DescribeContinuousBackups returns the point in time recovery status of a table, and the times it can be restored to
+// when it's enabled.
+func (c *Client) DescribeContinuousBackups(table string) (ContinuousBackupsDescription, error) {
+	res := ContinuousBackupsResponse{}
+	err := c.makeRequest(DescribeContinuousBackupsEndpoint, BasicRequest{TableName: table}, &res)
+	return res.ContinuousBackupsDescription, err
+}
+
+// UpdateContinuousBackups enables or disables point in time recovery of a table, which can then be restored to any
+// second of the last 35 days.
+func (c *Client) UpdateContinuousBackups(table string, pointInTimeRecovery bool) (ContinuousBackupsDescription, error) {
+	req := UpdateContinuousBackupsRequest{
+		TableName:                        table,
+		PointInTimeRecoverySpecification: PointInTimeRecoverySpecification{PointInTimeRecoveryEnabled: pointInTimeRecovery},
+	}
+	res := ContinuousBackupsResponse{}
+	err := c.makeRequest(UpdateContinuousBackupsEndpoint, req, &res)
+	return res.ContinuousBackupsDescription, err
+}
+
+// RestoreTableToPointInTime starts restoring a table with point in time recovery to a new table, as it was at t, or at
+// its latest restorable time if t is zero. See WaitForRestore.
+func (c *Client) RestoreTableToPointInTime(source, target string, t time.Time) (TableDescription, error) {
+	req := RestoreTableToPointInTimeRequest{SourceTableName: source, TargetTableName: target}
+	if t.IsZero() {
+		req.UseLatestRestorableTime = true
+	} else {
+		req.RestoreDateTime = float64(t.UnixNano()) / float64(time.Second)
+	}
+	res := TableDescriptionWrapper{}
+	err := c.makeRequest(RestoreTableToPointInTimeEndpoint, req, &res)
+	return res.Description, err
+}
+
+// WaitForRestore polls a table being restored until it's active with its data restored, returning its description.
+// It returns ErrWaitTimeout with the last description if that takes longer than the timeout.
+func (c *Client) WaitForRestore(table string, opts WaitOptions) (TableDescription, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultWaitInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultWaitTimeout
+	}
+	deadline := time.Now().Add(opts.Timeout)
+	for {
+		desc, err := c.DescribeTable(table)
+		if err != nil {
+			return desc, err
+		} else if desc.TableStatus == "ACTIVE" && (desc.RestoreSummary == nil || !desc.RestoreSummary.RestoreInProgress) {
+			return desc, nil
+		} else if !time.Now().Add(opts.Interval).Before(deadline) {
+			return desc, ErrWaitTimeout
+		}
+		time.Sleep(opts.Interval)
+	}
+}
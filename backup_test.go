@@ -0,0 +1,84 @@
+package dynamo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackups(t *testing.T) {
+	var pitr RestoreTableToPointInTimeRequest
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		var res interface{}
+		switch strings.TrimPrefix(r.Header.Get("X-Amz-Target"), DynamoBaseEndpoint) {
+		case CreateBackupEndpoint:
+			req := CreateBackupRequest{}
+			json.Unmarshal(b, &req)
+			res = CreateBackupResponse{BackupDetails{BackupArn: "arn/" + req.BackupName, BackupName: req.BackupName, BackupStatus: BackupCreating}}
+		case ListBackupsEndpoint:
+			req := ListBackupsRequest{}
+			json.Unmarshal(b, &req)
+			if len(req.ExclusiveStartBackupArn) == 0 {
+				res = ListBackupsResponse{BackupSummaries: []BackupSummary{{BackupArn: "arn/a"}}, LastEvaluatedBackupArn: "arn/a"}
+			} else {
+				res = ListBackupsResponse{BackupSummaries: []BackupSummary{{BackupArn: "arn/b", TableName: req.TableName}}}
+			}
+		case RestoreTableToPointInTimeEndpoint:
+			pitr = RestoreTableToPointInTimeRequest{}
+			json.Unmarshal(b, &pitr)
+			res = TableDescriptionWrapper{Description: TableDescription{TableName: pitr.TargetTableName, TableStatus: "CREATING"}}
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(res)
+	})
+	defer s.Close()
+
+	if details, err := c.CreateBackup("things", "things-daily"); err != nil || details.BackupArn != "arn/things-daily" {
+		t.Errorf("Created %+v, %v", details, err)
+	}
+	if _, err := c.CreateBackup("things", "a"); err == nil {
+		t.Error("Created a backup with an invalid name")
+	}
+	if backups, err := c.ListBackups(ListBackupsRequest{TableName: "things"}); err != nil || len(backups) != 2 || backups[1].TableName != "things" {
+		t.Errorf("Listed %+v, %v", backups, err)
+	}
+
+	if _, err := c.RestoreTableToPointInTime("things", "things-restored", time.Time{}); err != nil || !pitr.UseLatestRestorableTime || pitr.RestoreDateTime != 0 {
+		t.Errorf("Restored with %+v, %v", pitr, err)
+	}
+	at := time.Unix(1500000000, 500000000)
+	if desc, err := c.RestoreTableToPointInTime("things", "things-restored", at); err != nil || desc.TableName != "things-restored" {
+		t.Errorf("Restored %+v, %v", desc, err)
+	} else if pitr.UseLatestRestorableTime || pitr.RestoreDateTime != 1500000000.5 {
+		t.Errorf("Restored with %+v", pitr)
+	}
+}
+
+func TestWaitForRestore(t *testing.T) {
+	polls := 0
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		desc := TableDescription{TableName: "things", TableStatus: "CREATING", RestoreSummary: &RestoreSummary{RestoreInProgress: true}}
+		if polls >= 2 {
+			desc.TableStatus = "ACTIVE"
+		}
+		if polls >= 3 {
+			desc.RestoreSummary.RestoreInProgress = false
+		}
+		json.NewEncoder(w).Encode(TableDescriptionWrapper{Table: desc})
+	})
+	defer s.Close()
+
+	if _, err := c.WaitForRestore("things", WaitOptions{Interval: time.Millisecond, Timeout: time.Millisecond}); err != ErrWaitTimeout || polls != 1 {
+		t.Errorf("Waited %d polls: %v", polls, err)
+	}
+	if desc, err := c.WaitForRestore("things", WaitOptions{Interval: time.Millisecond}); err != nil || polls != 3 || desc.TableStatus != "ACTIVE" {
+		t.Errorf("Waited %d polls for %+v: %v", polls, desc, err)
+	}
+}
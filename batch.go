@@ -0,0 +1,108 @@
+package dynamo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultBatchRetries = 8
+	batchBackoff        = 50 * time.Millisecond
+	maxBatchBackoff     = 5 * time.Second
+)
+
+// UnprocessedError is returned by BatchWriter.Flush when DynamoDB still hadn't processed some writes after the retries.
+type UnprocessedError struct {
+	Items []RequestItem
+}
+
+func (e *UnprocessedError) Error() string {
+	return fmt.Sprintf("%d batch writes were left unprocessed", len(e.Items))
+}
+
+// BatchWriter buffers puts and deletes on a table, writing them 25 at a time and retrying the items DynamoDB leaves
+// unprocessed with exponential backoff. It isn't safe for concurrent use.
+type BatchWriter struct {
+	MaxRetries int // Of unprocessed items, defaults to 8.
+
+	c       *Client
+	table   string
+	pending []RequestItem
+	written int64
+	sleep   func(time.Duration)
+}
+
+// NewBatchWriter returns a batch writer for table.
+func (c *Client) NewBatchWriter(table string) *BatchWriter {
+	return &BatchWriter{MaxRetries: defaultBatchRetries, c: c, table: table, sleep: time.Sleep}
+}
+
+// Put adds a put of item, first writing the buffered items if there are 25 of them.
+func (w *BatchWriter) Put(item AttributeSet) error {
+	if err := checkItemSize(item); err != nil {
+		return err
+	}
+	return w.add(RequestItem{PutRequest: &PutRequest{Item: item}})
+}
+
+// Delete adds a delete of the item with key, first writing the buffered items if there are 25 of them.
+func (w *BatchWriter) Delete(key AttributeSet) error {
+	return w.add(RequestItem{DeleteRequest: &DeleteRequest{Key: key}})
+}
+
+func (w *BatchWriter) add(item RequestItem) error {
+	if len(w.pending) == BatchWriteItemLimit {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	w.pending = append(w.pending, item)
+	return nil
+}
+
+// Flush writes the buffered items. If it fails, the items that weren't written are dropped.
+func (w *BatchWriter) Flush() error {
+	items := w.pending
+	w.pending = nil
+	backoff := batchBackoff
+	for retry := 0; len(items) > 0; retry++ {
+		if retry > w.MaxRetries {
+			return &UnprocessedError{Items: items}
+		} else if retry > 0 {
+			w.sleep(backoff)
+			if backoff *= 2; backoff > maxBatchBackoff {
+				backoff = maxBatchBackoff
+			}
+		}
+		res, err := w.c.BatchWriteRaw(w.table, items)
+		if IsErrorCode(err, ProvisionedThroughputExceededException) || IsErrorCode(err, ThrottlingException) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		unprocessed := res.UnprocessedItems[w.table]
+		w.written += int64(len(items) - len(unprocessed))
+		items = unprocessed
+	}
+	return nil
+}
+
+// Written returns the number of items written so far.
+func (w *BatchWriter) Written() int64 {
+	return w.written
+}
+
+// BatchWriteRaw makes a single batch write of at most 25 puts and deletes on table.
+func (c *Client) BatchWriteRaw(table string, items []RequestItem) (BatchResponse, error) {
+	res := BatchResponse{}
+	if len(items) > BatchWriteItemLimit {
+		return res, errors.New("Maximum of 25 item limit for batch writes exceeded")
+	}
+	req := BatchWriteRequest{
+		RequestItems:           map[string][]RequestItem{table: items},
+		ReturnConsumedCapacity: c.returnConsumed(),
+	}
+	err := c.makeRequest(BatchWriteItemEndpoint, req, &res)
+	return res, err
+}
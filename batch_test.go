@@ -0,0 +1,58 @@
+package dynamo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBatchWriter(t *testing.T) {
+	requests, written := 0, map[string]bool{}
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		req := BatchWriteRequest{}
+		json.NewDecoder(r.Body).Decode(&req)
+		items := req.RequestItems["things"]
+		if len(items) > BatchWriteItemLimit {
+			t.Errorf("Batch of %d items", len(items))
+		}
+		// Leave every other item of the first batches unprocessed.
+		res := BatchResponse{UnprocessedItems: map[string][]RequestItem{}}
+		for i, item := range items {
+			if i%2 == 1 && requests < 3 {
+				res.UnprocessedItems["things"] = append(res.UnprocessedItems["things"], item)
+			} else {
+				written[item.PutRequest.Item["id"].S] = true
+			}
+		}
+		json.NewEncoder(w).Encode(res)
+	})
+	defer s.Close()
+	bw := c.NewBatchWriter("things")
+	sleeps := []time.Duration{}
+	bw.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	for i := 0; i < 30; i++ {
+		if err := bw.Put(AttributeSet{"id": {S: fmt.Sprint(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 30 || bw.Written() != 30 {
+		t.Errorf("Expected 30 items written, got %d and %d", len(written), bw.Written())
+	}
+	if len(sleeps) != 2 || sleeps[1] != 2*sleeps[0] {
+		t.Errorf("Expected two retries with backoff, slept %v", sleeps)
+	}
+
+	bw.MaxRetries = 0
+	requests = 0
+	bw.Put(AttributeSet{"id": {S: "a"}})
+	bw.Put(AttributeSet{"id": {S: "b"}})
+	if err, ok := bw.Flush().(*UnprocessedError); !ok || len(err.Items) != 1 {
+		t.Errorf("Expected an unprocessed item, got %v", err)
+	}
+}
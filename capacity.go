@@ -0,0 +1,214 @@
+package dynamo
+
+import (
+	"sync"
+)
+
+// CapacityFunc is called with the capacity consumed by every request that reported it, along with the endpoint
+// called, i.e. PutItemEndpoint.
+type CapacityFunc func(endpoint string, consumed []ConsumedStats)
+
+// TrackCapacity makes every request return its consumed capacity and passes it to fn. level is ConsumedTotal for the
+// capacity per table, or ConsumedIndexes to also break it down by table and index. Passing ConsumedNone stops tracking.
+// See WithCapacity for the capacity of single calls.
+func (c *Client) TrackCapacity(level string, fn CapacityFunc) {
+	if level == ConsumedNone || fn == nil {
+		c.capacityLevel, c.onCapacity = "", nil
+		return
+	}
+	c.capacityLevel, c.onCapacity = level, fn
+}
+
+// WithCapacity returns a copy of the client whose requests also pass their consumed capacity at level to fn, so the
+// capacity of a single call, or of a group of calls, can be read without changing the client's tracking:
+//
+//	acc := NewCapacityAccumulator()
+//	err := c.WithCapacity(ConsumedIndexes, acc.Add).PutItem("things", doc)
+//
+// The client's own CapacityFunc, if any, is still called. The copy shares everything else with the client.
+func (c *Client) WithCapacity(level string, fn CapacityFunc) *Client {
+	cc := *c
+	if level == ConsumedNone || fn == nil {
+		return &cc
+	}
+	if c.capacityLevel != ConsumedIndexes {
+		cc.capacityLevel = level
+	}
+	if prev := c.onCapacity; prev != nil {
+		cc.onCapacity = func(endpoint string, consumed []ConsumedStats) {
+			prev(endpoint, consumed)
+			fn(endpoint, consumed)
+		}
+	} else {
+		cc.onCapacity = fn
+	}
+	return &cc
+}
+
+// CapacityKey identifies the totals of a CapacityAccumulator.
+type CapacityKey struct {
+	Table    string
+	Endpoint string
+}
+
+// CapacityTotal is the capacity consumed by the requests to an endpoint for a table.
+type CapacityTotal struct {
+	Requests      int
+	CapacityUnits float64
+	ReadUnits     float64
+	WriteUnits    float64
+	Indexes       map[string]float64 // Units consumed by each secondary index, only with ConsumedIndexes.
+}
+
+// CapacityAccumulator aggregates consumed capacity per table and endpoint. Its Add method is a CapacityFunc:
+//
+//	acc := NewCapacityAccumulator()
+//	c.TrackCapacity(ConsumedIndexes, acc.Add)
+type CapacityAccumulator struct {
+	mu     sync.Mutex
+	totals map[CapacityKey]*CapacityTotal
+}
+
+func NewCapacityAccumulator() *CapacityAccumulator {
+	return &CapacityAccumulator{totals: map[CapacityKey]*CapacityTotal{}}
+}
+
+func (a *CapacityAccumulator) Add(endpoint string, consumed []ConsumedStats) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, s := range consumed {
+		key := CapacityKey{Table: s.TableName, Endpoint: endpoint}
+		t := a.totals[key]
+		if t == nil {
+			t = &CapacityTotal{Indexes: map[string]float64{}}
+			a.totals[key] = t
+		}
+		t.Requests++
+		t.CapacityUnits += s.CapacityUnits
+		read, write := s.ReadCapacityUnits, s.WriteCapacityUnits
+		if read == 0 && write == 0 {
+			if writeEndpoints[endpoint] {
+				write = s.CapacityUnits
+			} else {
+				read = s.CapacityUnits
+			}
+		}
+		t.ReadUnits += read
+		t.WriteUnits += write
+		for name, c := range s.LocalSecondaryIndexes {
+			t.Indexes[name] += c.CapacityUnits
+		}
+		for name, c := range s.GlobalSecondaryIndexes {
+			t.Indexes[name] += c.CapacityUnits
+		}
+	}
+}
+
+// Totals returns a copy of the totals so far.
+func (a *CapacityAccumulator) Totals() map[CapacityKey]CapacityTotal {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	totals := make(map[CapacityKey]CapacityTotal, len(a.totals))
+	for k, t := range a.totals {
+		c := *t
+		c.Indexes = make(map[string]float64, len(t.Indexes))
+		for name, units := range t.Indexes {
+			c.Indexes[name] = units
+		}
+		totals[k] = c
+	}
+	return totals
+}
+
+// Reset clears the totals.
+func (a *CapacityAccumulator) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.totals = map[CapacityKey]*CapacityTotal{}
+}
+
+// returnConsumed is the ReturnConsumedCapacity value requests should use.
+func (c *Client) returnConsumed() string {
+	if len(c.capacityLevel) > 0 {
+		return c.capacityLevel
+	} else if c.limiter != nil || c.tracer != nil {
+		return ConsumedTotal
+	}
+	return ""
+}
+
+// tableRequest is implemented by requests whose capacity can be metered per table.
+type tableRequest interface {
+	tableNames() []string
+}
+
+// capacityResponse is implemented by responses carrying ConsumedCapacity.
+type capacityResponse interface {
+	consumed() []ConsumedStats
+}
+
+func (r PutRequest) tableNames() []string        { return []string{r.TableName} }
+func (r Update) tableNames() []string            { return []string{r.TableName} }
+func (r Query) tableNames() []string             { return []string{r.TableName} }
+func (r ScanRequest) tableNames() []string       { return []string{r.TableName} }
+func (r GetRequest) tableNames() []string        { return []string{r.TableName} }
+func (r DeleteItemRequest) tableNames() []string { return []string{r.TableName} }
+func (r BasicRequest) tableNames() []string      { return []string{r.TableName} }
+
+func (r BatchWriteRequest) tableNames() []string {
+	tables := []string{}
+	for t := range r.RequestItems {
+		tables = append(tables, t)
+	}
+	return tables
+}
+
+func (r BatchGetRequest) tableNames() []string {
+	tables := []string{}
+	for t := range r.RequestItems {
+		tables = append(tables, t)
+	}
+	return tables
+}
+
+func (r TransactWriteRequest) tableNames() []string {
+	seen, tables := map[string]bool{}, []string{}
+	for _, item := range r.TransactItems {
+		for _, op := range []*TransactOperation{item.ConditionCheck, item.Delete, item.Put, item.Update} {
+			if op != nil && !seen[op.TableName] {
+				seen[op.TableName] = true
+				tables = append(tables, op.TableName)
+			}
+		}
+	}
+	return tables
+}
+
+func (r TransactGetRequest) tableNames() []string {
+	seen, tables := map[string]bool{}, []string{}
+	for _, item := range r.TransactItems {
+		if !seen[item.Get.TableName] {
+			seen[item.Get.TableName] = true
+			tables = append(tables, item.Get.TableName)
+		}
+	}
+	return tables
+}
+
+func (r *UpdateResponse) consumed() []ConsumedStats        { return single(r.ConsumedCapacity) }
+func (r *QueryResponse) consumed() []ConsumedStats         { return single(r.ConsumedCapacity) }
+func (r *GetResponse) consumed() []ConsumedStats           { return single(r.ConsumedCapacity) }
+func (r *BatchResponse) consumed() []ConsumedStats         { return r.ConsumedCapacity }
+func (r *TransactWriteResponse) consumed() []ConsumedStats { return r.ConsumedCapacity }
+func (r *TransactGetResponse) consumed() []ConsumedStats   { return r.ConsumedCapacity }
+
+func (r *ExecuteStatementResponse) consumed() []ConsumedStats      { return single(r.ConsumedCapacity) }
+func (r *BatchExecuteStatementResponse) consumed() []ConsumedStats { return r.ConsumedCapacity }
+func (r *ExecuteTransactionResponse) consumed() []ConsumedStats    { return r.ConsumedCapacity }
+
+func single(s ConsumedStats) []ConsumedStats {
+	if len(s.TableName) == 0 {
+		return nil
+	}
+	return []ConsumedStats{s}
+}
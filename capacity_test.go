@@ -0,0 +1,65 @@
+package dynamo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestTrackCapacity(t *testing.T) {
+	var got PutRequest
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &got)
+		w.Write([]byte(`{"ConsumedCapacity":{"TableName":"things","CapacityUnits":3,
+			"Table":{"CapacityUnits":1},"GlobalSecondaryIndexes":{"by-name":{"CapacityUnits":2}}}}`))
+	})
+	defer s.Close()
+	acc := NewCapacityAccumulator()
+	c.TrackCapacity(ConsumedIndexes, acc.Add)
+
+	for i := 0; i < 2; i++ {
+		if err := c.PutItem("things", versionedDoc{Id: "a", Name: "b"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got.ReturnConsumedCapacity != ConsumedIndexes {
+		t.Errorf("Expected ReturnConsumedCapacity %q, was %q", ConsumedIndexes, got.ReturnConsumedCapacity)
+	}
+	total := acc.Totals()[CapacityKey{Table: "things", Endpoint: PutItemEndpoint}]
+	if total.Requests != 2 || total.WriteUnits != 6 || total.ReadUnits != 0 || total.Indexes["by-name"] != 4 {
+		t.Errorf("Unexpected totals %+v", total)
+	}
+}
+
+func TestWithCapacity(t *testing.T) {
+	var got PutRequest
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		got = PutRequest{}
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &got)
+		w.Write([]byte(`{"ConsumedCapacity":{"TableName":"things","CapacityUnits":3}}`))
+	})
+	defer s.Close()
+
+	call := NewCapacityAccumulator()
+	if err := c.WithCapacity(ConsumedTotal, call.Add).PutItemRaw("things", AttributeSet{"id": {S: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got.ReturnConsumedCapacity != ConsumedTotal || call.Totals()[CapacityKey{"things", PutItemEndpoint}].WriteUnits != 3 {
+		t.Errorf("Unexpected capacity %+v for %q", call.Totals(), got.ReturnConsumedCapacity)
+	}
+	if c.PutItemRaw("things", AttributeSet{"id": {S: "a"}}); got.ReturnConsumedCapacity != "" {
+		t.Errorf("Client returned capacity %q after a copy tracked it", got.ReturnConsumedCapacity)
+	}
+
+	// The client's own tracking keeps its level and callback.
+	client := NewCapacityAccumulator()
+	c.TrackCapacity(ConsumedIndexes, client.Add)
+	call.Reset()
+	c.WithCapacity(ConsumedTotal, call.Add).PutItemRaw("things", AttributeSet{"id": {S: "a"}})
+	if got.ReturnConsumedCapacity != ConsumedIndexes || len(call.Totals()) != 1 || len(client.Totals()) != 1 {
+		t.Errorf("Unexpected capacity %+v and %+v for %q", call.Totals(), client.Totals(), got.ReturnConsumedCapacity)
+	}
+}
@@ -0,0 +1,89 @@
+package dynamo
+
+import (
+	"compress/gzip"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+const maxChecksumAttempts = 3 // Requests whose responses fail the checksum are sent up to this many times.
+
+// ChecksumError is returned when reading a response whose CRC32 checksum doesn't match the X-Amz-Crc32 header,
+// meaning it was corrupted on the way. DoAndUnmarshal sends the request again when it happens.
+type ChecksumError struct {
+	Expected, Actual uint32
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("Response CRC32 checksum %d didn't match X-Amz-Crc32 header %d", e.Actual, e.Expected)
+}
+
+// SetCompression asks DynamoDB to gzip responses, which saves bandwidth on large Query and Scan pages at the cost of
+// some CPU. Responses are decompressed transparently.
+func (c *Client) SetCompression(gzip bool) {
+	c.gzip = gzip
+}
+
+// verifyResponse wraps the body of res so that reading it checks its checksum and decompresses it.
+func verifyResponse(res *http.Response) error {
+	if h := res.Header.Get("X-Amz-Crc32"); len(h) > 0 && !res.Uncompressed {
+		expected, err := strconv.ParseUint(h, 10, 32)
+		if err != nil {
+			return fmt.Errorf("Invalid X-Amz-Crc32 header %q", h)
+		}
+		res.Body = &checksumBody{ReadCloser: res.Body, hash: crc32.NewIEEE(), expected: uint32(expected)}
+	}
+	if res.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(res.Body)
+		if err != nil {
+			res.Body.Close()
+			return err
+		}
+		res.Body = &gzipBody{Reader: zr, body: res.Body}
+		res.Header.Del("Content-Encoding")
+		res.ContentLength = -1
+	}
+	return nil
+}
+
+// checksumBody returns a ChecksumError at the end of the body if its checksum doesn't match.
+type checksumBody struct {
+	io.ReadCloser
+	hash     hash.Hash32
+	expected uint32
+}
+
+func (b *checksumBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	if err == io.EOF && b.hash.Sum32() != b.expected {
+		err = &ChecksumError{Expected: b.expected, Actual: b.hash.Sum32()}
+	}
+	return n, err
+}
+
+// gzipBody decompresses a body. The checksum covers the compressed body, so it's read to the end once decompressed.
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		if _, cerr := io.Copy(ioutil.Discard, b.body); cerr != nil {
+			err = cerr
+		}
+	}
+	return n, err
+}
+
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
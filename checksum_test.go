@@ -0,0 +1,85 @@
+package dynamo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"hash/crc32"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+var describeBody = []byte(`{"Table":{"TableName":"things","TableStatus":"ACTIVE"}}`)
+
+func TestChecksum(t *testing.T) {
+	calls := 0
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		sum := crc32.ChecksumIEEE(describeBody)
+		if calls == 1 {
+			sum++
+		}
+		w.Header().Set("X-Amz-Crc32", strconv.FormatUint(uint64(sum), 10))
+		w.Write(describeBody)
+	})
+	defer s.Close()
+	desc, err := c.DescribeTable("things")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || desc.TableStatus != "ACTIVE" {
+		t.Errorf("Expected the corrupted response to be retried, got %d calls and %+v", calls, desc)
+	}
+}
+
+func TestChecksumFailure(t *testing.T) {
+	calls := 0
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-Amz-Crc32", "1")
+		w.Write(describeBody)
+	})
+	defer s.Close()
+	_, err := c.DescribeTable("things")
+	if _, ok := err.(*ChecksumError); !ok || calls != maxChecksumAttempts {
+		t.Errorf("Expected ChecksumError after %d calls, got %v after %d", maxChecksumAttempts, err, calls)
+	}
+}
+
+func TestChecksumWithoutResponse(t *testing.T) {
+	calls := 0
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-Amz-Crc32", "1")
+		w.Write(describeBody)
+	})
+	defer s.Close()
+	err := c.ChangeThroughput("things", 1, 1)
+	if _, ok := err.(*ChecksumError); !ok || calls != maxChecksumAttempts {
+		t.Errorf("Expected ChecksumError after %d calls, got %v after %d", maxChecksumAttempts, err, calls)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("Expected gzip to be accepted, got %q", r.Header.Get("Accept-Encoding"))
+		}
+		b := &bytes.Buffer{}
+		zw := gzip.NewWriter(b)
+		zw.Write(describeBody)
+		zw.Close()
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("X-Amz-Crc32", strconv.FormatUint(uint64(crc32.ChecksumIEEE(b.Bytes())), 10))
+		w.Write(b.Bytes())
+	})
+	defer s.Close()
+	c.SetCompression(true)
+	desc, err := c.DescribeTable("things")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.TableName != "things" {
+		t.Errorf("Unexpected table %+v", desc)
+	}
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/poptip/dynamo"
+	"github.com/poptip/dynamo/export"
+	"github.com/poptip/dynamo/importer"
+)
+
+// Range key operators accepted by query, and their conditions.
+var rangeOperators = map[string]string{
+	"=":           dynamo.ConditionEqual,
+	"<":           dynamo.ConditionLessThan,
+	"<=":          dynamo.ConditionLessThanOrEqual,
+	">":           dynamo.ConditionGreaterThan,
+	">=":          dynamo.ConditionGreaterThanOrEqual,
+	"begins_with": dynamo.ConditionBeginsWith,
+	"between":     dynamo.ConditionBetween,
+}
+
+func listTables(e *env, args []string) error {
+	fs := e.newFlags("list-tables", "")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+	rows := [][]string{}
+	start := ""
+	for {
+		names, last, err := e.c.ListTables(start, 100)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			rows = append(rows, []string{name})
+		}
+		if len(last) == 0 {
+			break
+		}
+		start = last
+	}
+	return e.out.rows([]string{"Table"}, rows)
+}
+
+func describe(e *env, args []string) error {
+	fs := e.newFlags("describe", "<table>")
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	desc, err := e.c.DescribeTable(args[0])
+	if err != nil {
+		return err
+	}
+	types := attributeTypes(desc)
+	keys := func(schema []dynamo.Key) string {
+		parts := make([]string, len(schema))
+		for i, k := range schema {
+			parts[i] = fmt.Sprintf("%s (%s, %s)", k.Name, types[k.Name], k.Type)
+		}
+		return strings.Join(parts, ", ")
+	}
+	fields := [][2]string{
+		{"Table", desc.TableName},
+		{"Status", desc.TableStatus},
+		{"Created", time.Unix(int64(desc.CreationDateTime), 0).UTC().Format(time.RFC3339)},
+		{"Key", keys(desc.KeySchema)},
+		{"Items", strconv.Itoa(desc.ItemCount)},
+		{"Size", fmt.Sprintf("%d bytes", desc.TableSizeBytes)},
+		{"Throughput", fmt.Sprintf("%d read, %d write", desc.ProvisionedThroughput.ReadUnits, desc.ProvisionedThroughput.WriteUnits)},
+	}
+	for _, lsi := range desc.LocalSecondaryIndexes {
+		fields = append(fields, [2]string{"Local index " + lsi.IndexName, keys(lsi.KeySchema)})
+	}
+	for _, gsi := range desc.GlobalSecondaryIndexes {
+		t := gsi.ProvisionedThroughput
+		fields = append(fields, [2]string{"Global index " + gsi.IndexName, fmt.Sprintf("%s, %s, %d read, %d write", keys(gsi.KeySchema), gsi.IndexStatus, t.ReadUnits, t.WriteUnits)})
+	}
+	return e.out.value(desc, fields)
+}
+
+func create(e *env, args []string) error {
+	fs := e.newFlags("create", "<table>")
+	hash := fs.String("hash", "", "Hash key as name:type, i.e. id:S")
+	rng := fs.String("range", "", "Range key as name:type")
+	read := fs.Int("read", 5, "Provisioned read capacity units")
+	write := fs.Int("write", 5, "Provisioned write capacity units")
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	hashName, hashType, err := keyFlag("hash", *hash)
+	if err != nil {
+		return err
+	}
+	rangeName, rangeType := "", ""
+	if len(*rng) > 0 {
+		if rangeName, rangeType, err = keyFlag("range", *rng); err != nil {
+			return err
+		}
+	}
+	desc, err := e.c.CreateTableSimple(args[0], hashName, hashType, rangeName, rangeType, *read, *write)
+	if err != nil {
+		return err
+	}
+	return e.out.value(desc, [][2]string{{"Table", desc.TableName}, {"Status", desc.TableStatus}})
+}
+
+func keyFlag(name, v string) (string, string, error) {
+	parts := strings.SplitN(v, ":", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return "", "", fmt.Errorf("-%s must be name:type, i.e. id:S", name)
+	}
+	return parts[0], parts[1], nil
+}
+
+func deleteTable(e *env, args []string) error {
+	fs := e.newFlags("delete", "<table>")
+	yes := fs.Bool("yes", false, "Confirm the table and all its items should be deleted")
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if !*yes {
+		return fmt.Errorf("Not deleting %s without -yes", args[0])
+	}
+	desc, err := e.c.DeleteTable(args[0])
+	if err != nil {
+		return err
+	}
+	return e.out.value(desc, [][2]string{{"Table", desc.TableName}, {"Status", desc.TableStatus}})
+}
+
+func setThroughput(e *env, args []string) error {
+	fs := e.newFlags("set-throughput", "<table>")
+	read := fs.Int("read", 0, "Provisioned read capacity units")
+	write := fs.Int("write", 0, "Provisioned write capacity units")
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if *read <= 0 || *write <= 0 {
+		return errors.New("Both -read and -write are needed")
+	}
+	return e.c.ChangeThroughput(args[0], *read, *write)
+}
+
+func get(e *env, args []string) error {
+	fs := e.newFlags("get", "<table> <name=value>...")
+	consistent := fs.Bool("consistent", false, "Strongly consistent read")
+	args, err := parseArgs(fs, args, 2, -1)
+	if err != nil {
+		return err
+	}
+	desc, err := e.c.DescribeTable(args[0])
+	if err != nil {
+		return err
+	}
+	key, err := parseAttributes(args[1:], attributeTypes(desc))
+	if err != nil {
+		return err
+	}
+	item, err := e.c.GetItemRaw(args[0], key, *consistent)
+	if err != nil {
+		return err
+	} else if item == nil {
+		return errors.New("No item with that key")
+	}
+	return e.out.items([]dynamo.AttributeSet{item}, keyNames(desc.KeySchema))
+}
+
+func put(e *env, args []string) error {
+	fs := e.newFlags("put", "<table> [item]")
+	format := fs.String("format", "json", "Format of the item: json or dynamodb-json. Read from stdin if not given")
+	args, err := parseArgs(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	var line []byte
+	if len(args) == 2 {
+		line = []byte(args[1])
+	} else if line, err = ioutil.ReadAll(e.in); err != nil {
+		return err
+	}
+	f := export.Format(*format)
+	if f == "json" {
+		f = export.JSONLines
+	}
+	item, err := importer.ParseItem(line, f)
+	if err != nil {
+		return err
+	}
+	return e.c.PutItemRaw(args[0], item)
+}
+
+func query(e *env, args []string) error {
+	fs := e.newFlags("query", "<table> <hash=value> [<range> <operator> <value> [value]]")
+	index := fs.String("index", "", "Index to query")
+	limit := fs.Int("limit", 100, "Maximum number of items, 0 for all")
+	consistent := fs.Bool("consistent", false, "Strongly consistent read")
+	args, err := parseArgs(fs, args, 2, 6)
+	if err != nil {
+		return err
+	}
+	desc, err := e.c.DescribeTable(args[0])
+	if err != nil {
+		return err
+	}
+	types := attributeTypes(desc)
+	hash, err := parseAttributes(args[1:2], types)
+	if err != nil {
+		return err
+	}
+	q := dynamo.Query{
+		TableName:      args[0],
+		IndexName:      *index,
+		ConsistentRead: *consistent,
+		KeyConditions:  map[string]dynamo.Condition{},
+	}
+	for name, val := range hash {
+		q.KeyConditions[name] = dynamo.Condition{ComparisonOperator: dynamo.ConditionEqual, AttributeValueList: []dynamo.AttributeVal{val}}
+	}
+	if rest := args[2:]; len(rest) > 0 {
+		if len(rest) < 3 {
+			return errors.New("Range condition must be <range> <operator> <value> [value]")
+		}
+		op, ok := rangeOperators[strings.ToLower(rest[1])]
+		if !ok {
+			return fmt.Errorf("Unknown operator %q", rest[1])
+		} else if (op == dynamo.ConditionBetween) != (len(rest) == 4) {
+			return errors.New("between takes two values, the other operators one")
+		}
+		cond := dynamo.Condition{ComparisonOperator: op}
+		for _, v := range rest[2:] {
+			cond.AttributeValueList = append(cond.AttributeValueList, attributeValue(v, types[rest[0]]))
+		}
+		q.KeyConditions[rest[0]] = cond
+	}
+	items := []dynamo.AttributeSet{}
+	for {
+		page, last, err := e.c.RawQuery(q)
+		if err != nil {
+			return err
+		}
+		items = append(items, page...)
+		if len(last) == 0 || (*limit > 0 && len(items) >= *limit) {
+			break
+		}
+		q.ExclusiveStartKey = last
+	}
+	if *limit > 0 && len(items) > *limit {
+		items = items[:*limit]
+	}
+	return e.out.items(items, keyNames(desc.KeySchema))
+}
+
+func scan(e *env, args []string) error {
+	fs := e.newFlags("scan", "<table> [name=value]...")
+	limit := fs.Int("limit", 100, "Maximum number of items, 0 for all")
+	args, err := parseArgs(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+	desc, err := e.c.DescribeTable(args[0])
+	if err != nil {
+		return err
+	}
+	filter, err := parseAttributes(args[1:], attributeTypes(desc))
+	if err != nil {
+		return err
+	}
+	s := dynamo.ScanRequest{TableName: args[0], TotalSegments: 1}
+	if len(filter) > 0 {
+		s.ScanFilter = map[string]dynamo.Condition{}
+	}
+	for name, val := range filter {
+		s.ScanFilter[name] = dynamo.Condition{ComparisonOperator: dynamo.ConditionEqual, AttributeValueList: []dynamo.AttributeVal{val}}
+	}
+	items := []dynamo.AttributeSet{}
+	for {
+		page, last, err := e.c.RawScan(s)
+		if err != nil {
+			return err
+		}
+		items = append(items, page...)
+		if len(last) == 0 || (*limit > 0 && len(items) >= *limit) {
+			break
+		}
+		s.ExclusiveStartKey = last
+	}
+	if *limit > 0 && len(items) > *limit {
+		items = items[:*limit]
+	}
+	return e.out.items(items, keyNames(desc.KeySchema))
+}
+
+func selectItems(e *env, args []string) error {
+	fs := e.newFlags("select", "<statement>")
+	explain := fs.Bool("explain", false, "Print how the statement would run instead of running it")
+	args, err := parseArgs(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+	stmt := strings.Join(args, " ")
+	if !strings.HasPrefix(strings.ToUpper(stmt), "SELECT ") {
+		stmt = "SELECT " + stmt
+	}
+	if *explain {
+		plan, err := e.c.Explain(stmt)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(e.out.w, plan.Explanation)
+		return nil
+	}
+	items, err := e.c.Select(stmt)
+	if err != nil {
+		return err
+	}
+	return e.out.items(items, nil)
+}
+
+func exportTable(e *env, args []string) error {
+	fs := e.newFlags("export", "<table>")
+	output := fs.String("o", "", "File to write, stdout if empty. Exports to a file resume if interrupted")
+	format := fs.String("format", string(export.JSONLines), "Format: jsonl, csv or dynamodb-json")
+	columns := fs.String("columns", "", "Comma separated attributes to write as CSV columns")
+	compress := fs.Bool("gzip", false, "Gzip the output")
+	segments := fs.Int("segments", 1, "Segments scanned in parallel")
+	fraction := fs.Float64("read-fraction", 0, "Fraction of the provisioned read throughput to use")
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	opts := export.Options{
+		Format:       export.Format(*format),
+		Compress:     *compress,
+		Segments:     *segments,
+		ReadFraction: *fraction,
+	}
+	for _, name := range splitList(*columns) {
+		opts.Columns = append(opts.Columns, export.Column{Attribute: name})
+	}
+	if len(*output) == 0 {
+		_, err := export.Table(e.c, args[0], e.out.w, opts)
+		return err
+	}
+	p, err := export.ToFile(e.c, args[0], *output, opts)
+	if err != nil {
+		return err
+	}
+	return e.out.value(p, [][2]string{
+		{"Items", strconv.FormatInt(p.Items, 10)},
+		{"Pages", strconv.FormatInt(p.Pages, 10)},
+		{"Elapsed", p.Elapsed.String()},
+	})
+}
+
+func importTable(e *env, args []string) error {
+	fs := e.newFlags("import", "<table> <file>")
+	format := fs.String("format", string(export.JSONLines), "Format: jsonl, csv or dynamodb-json")
+	types := fs.String("types", "", "Comma separated CSV column types, i.e. count:N,tags:SS")
+	deadLetter := fs.String("dead-letter", "", "File rejected rows are appended to")
+	checkpoint := fs.String("checkpoint", "", "File recording progress, so an interrupted import can resume")
+	fraction := fs.Float64("write-fraction", 0, "Fraction of the provisioned write throughput to use")
+	args, err := parseArgs(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	opts := importer.Options{
+		Format:        export.Format(*format),
+		Types:         map[string]string{},
+		DeadLetter:    *deadLetter,
+		Checkpoint:    *checkpoint,
+		WriteFraction: *fraction,
+	}
+	for _, t := range splitList(*types) {
+		name, typ, err := keyFlag("types", t)
+		if err != nil {
+			return err
+		}
+		opts.Types[name] = typ
+	}
+	p, err := importer.File(e.c, args[0], args[1], opts)
+	if err != nil {
+		return err
+	}
+	return e.out.value(p, [][2]string{
+		{"Rows", strconv.FormatInt(p.Rows, 10)},
+		{"Written", strconv.FormatInt(p.Written, 10)},
+		{"Rejected", strconv.FormatInt(p.Rejected, 10)},
+		{"Elapsed", p.Elapsed.String()},
+	})
+}
+
+func alarms(e *env, args []string) error {
+	fs := e.newFlags("alarms", "<table>")
+	del := fs.Bool("delete", false, "Delete the alarms of the table")
+	metric := fs.String("put", "", "Create or update an alarm on this metric, i.e. ReadThrottleEvents")
+	threshold := fs.Float64("threshold", 0, "Threshold of the new alarm")
+	index := fs.String("index", "", "Global secondary index of the new alarm")
+	operation := fs.String("operation", "", "Operation of the new alarm, for SystemErrors and SuccessfulRequestLatency")
+	actions := fs.String("actions", "", "Comma separated ARNs notified when the new alarm fires")
+	period := fs.Int("period", 300, "Period of the new alarm in seconds")
+	periods := fs.Int("evaluation-periods", 1, "Periods the new alarm evaluates")
+	statistic := fs.String("statistic", dynamo.StatisticSum, "Statistic of the new alarm")
+	comparison := fs.String("comparison", dynamo.ComparisonGreaterThan, "Comparison of the new alarm")
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	table := args[0]
+	if *del {
+		return e.c.DeleteTableAlarms(table)
+	} else if len(*metric) > 0 {
+		alarm := dynamo.Alarm{Table: table, Index: *index, Metric: *metric, Operation: *operation, Threshold: *threshold}
+		spec := dynamo.AlarmSpec{
+			Actions:           splitList(*actions),
+			Period:            *period,
+			EvaluationPeriods: *periods,
+			Statistic:         *statistic,
+			Comparison:        *comparison,
+		}
+		return e.c.PutAlarms([]dynamo.Alarm{alarm}, spec)
+	}
+	list, err := e.c.ListAlarms(table)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(list))
+	for i, a := range list {
+		rows[i] = []string{a.Name, a.Metric, a.Index, a.Operation, strconv.FormatFloat(a.Threshold, 'g', -1, 64), a.State}
+	}
+	return e.out.rows([]string{"Name", "Metric", "Index", "Operation", "Threshold", "State"}, rows)
+}
+
+// attributeTypes returns the types of the key attributes of a table and its indexes.
+func attributeTypes(desc dynamo.TableDescription) map[string]string {
+	types := map[string]string{}
+	for _, def := range desc.AttributeDefinitions {
+		types[def.Name] = def.Type
+	}
+	return types
+}
+
+func keyNames(schema []dynamo.Key) []string {
+	names := make([]string, len(schema))
+	for i, k := range schema {
+		names[i] = k.Name
+	}
+	return names
+}
+
+// parseAttributes parses name=value args, typing values by types and defaulting to strings.
+func parseAttributes(args []string, types map[string]string) (dynamo.AttributeSet, error) {
+	attrs := dynamo.AttributeSet{}
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("%q is not name=value", arg)
+		}
+		attrs[parts[0]] = attributeValue(parts[1], types[parts[0]])
+	}
+	return attrs, nil
+}
+
+func attributeValue(v, typ string) dynamo.AttributeVal {
+	switch typ {
+	case dynamo.TypeNumber:
+		return dynamo.AttributeVal{N: v}
+	case dynamo.TypeBinary:
+		return dynamo.AttributeVal{B: v}
+	}
+	return dynamo.AttributeVal{S: v}
+}
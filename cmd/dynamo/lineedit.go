@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxHistory = 1000 // Lines kept by the line editor.
+
+// lineEditor reads lines from a terminal with cursor movement, history and tab completion. When the input isn't a
+// terminal, lines are read as they are.
+type lineEditor struct {
+	r        *bufio.Reader
+	w        io.Writer
+	fd       int
+	terminal bool
+	history  []string
+
+	// complete returns the words that can follow head, the line before the word being completed. The editor keeps
+	// those starting with the word.
+	complete func(head string) []string
+}
+
+func newLineEditor(in io.Reader, out io.Writer) *lineEditor {
+	ed := &lineEditor{r: bufio.NewReader(in), w: out, fd: -1}
+	if f, ok := in.(*os.File); ok && isTerminal(int(f.Fd())) {
+		ed.fd = int(f.Fd())
+		ed.terminal = true
+	}
+	return ed
+}
+
+// addHistory adds a line to the history, unless it's empty or repeats the last line.
+func (ed *lineEditor) addHistory(line string) {
+	if len(strings.TrimSpace(line)) == 0 || (len(ed.history) > 0 && ed.history[len(ed.history)-1] == line) {
+		return
+	}
+	ed.history = append(ed.history, line)
+	if len(ed.history) > maxHistory {
+		ed.history = ed.history[len(ed.history)-maxHistory:]
+	}
+}
+
+// readLine reads a line, returning io.EOF at the end of the input or on Ctrl-D.
+func (ed *lineEditor) readLine(prompt string) (string, error) {
+	if !ed.terminal {
+		line, err := ed.r.ReadString('\n')
+		if err == io.EOF && len(line) > 0 {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+	restore, err := makeRaw(ed.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	return ed.edit(prompt)
+}
+
+// edit reads the keys typed until Enter, echoing the line as it's edited.
+func (ed *lineEditor) edit(prompt string) (string, error) {
+	line := []rune{}
+	pos := 0
+	hist := len(ed.history)
+	saved := "" // The line being typed while browsing the history.
+	fmt.Fprint(ed.w, prompt)
+	for {
+		r, _, err := ed.r.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(ed.w, "\r\n")
+			ed.addHistory(string(line))
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(ed.w, "^C\r\n")
+			line, pos, hist = []rune{}, 0, len(ed.history)
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(ed.w, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(line)
+		case 11: // Ctrl-K
+			line = line[:pos]
+		case 21: // Ctrl-U
+			line, pos = line[pos:], 0
+		case 8, 127:
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case '\t':
+			line, pos = ed.completeWord(prompt, line, pos)
+		case 27:
+			key, err := ed.escape()
+			if err != nil {
+				return "", err
+			}
+			switch key {
+			case 'A', 'B':
+				if key == 'A' && hist > 0 {
+					if hist == len(ed.history) {
+						saved = string(line)
+					}
+					hist--
+				} else if key == 'B' && hist < len(ed.history) {
+					hist++
+				} else {
+					break
+				}
+				if hist == len(ed.history) {
+					line = []rune(saved)
+				} else {
+					line = []rune(ed.history[hist])
+				}
+				pos = len(line)
+			case 'C':
+				if pos < len(line) {
+					pos++
+				}
+			case 'D':
+				if pos > 0 {
+					pos--
+				}
+			case 'H':
+				pos = 0
+			case 'F':
+				pos = len(line)
+			case '3': // Delete
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if !unicode.IsPrint(r) {
+				continue
+			}
+			line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+			pos++
+		}
+		ed.redraw(prompt, line, pos)
+	}
+}
+
+// escape reads the rest of an escape sequence, returning the letter of arrow keys, Home and End, or the number of
+// sequences such as Delete's ESC [ 3 ~.
+func (ed *lineEditor) escape() (rune, error) {
+	r, _, err := ed.r.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return 0, err
+	}
+	key, _, err := ed.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if key >= '0' && key <= '9' {
+		for r := key; r != '~'; {
+			if r, _, err = ed.r.ReadRune(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return key, nil
+}
+
+func (ed *lineEditor) redraw(prompt string, line []rune, pos int) {
+	fmt.Fprintf(ed.w, "\r%s%s\x1b[K", prompt, string(line))
+	if n := len(line) - pos; n > 0 {
+		fmt.Fprintf(ed.w, "\x1b[%dD", n)
+	}
+}
+
+// completeWord completes the word before the cursor. A single match is inserted, followed by a space unless it ends
+// with "="; otherwise the common prefix of the matches is, or the matches are listed when there's none to add.
+func (ed *lineEditor) completeWord(prompt string, line []rune, pos int) ([]rune, int) {
+	if ed.complete == nil {
+		return line, pos
+	}
+	head := string(line[:pos])
+	start := strings.LastIndexAny(head, " \t") + 1
+	word := head[start:]
+	matches := []string{}
+	for _, c := range ed.complete(head[:start]) {
+		if strings.HasPrefix(c, word) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return line, pos
+	}
+	sort.Strings(matches)
+	insert := ""
+	if len(matches) == 1 {
+		insert = matches[0][len(word):]
+		if !strings.HasSuffix(matches[0], "=") {
+			insert += " "
+		}
+	} else if prefix := commonPrefix(matches); len(prefix) > len(word) {
+		insert = prefix[len(word):]
+	} else {
+		fmt.Fprintf(ed.w, "\r\n%s\r\n", strings.Join(matches, "  "))
+		return line, pos
+	}
+	ins := []rune(insert)
+	line = append(line[:pos], append(ins, line[pos:]...)...)
+	return line, pos + len(ins)
+}
+
+// commonPrefix returns the longest prefix of whole runes the words share.
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,173 @@
+// Command dynamo inspects and manages DynamoDB tables.
+//
+//	dynamo [-region us-east-1] [-output table|json|dynamodb-json] <command> [flags] [args]
+//
+// Credentials are read from -access-key and -secret-key, then the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+// environment variables, the shared credentials file (see -profile) and finally the EC2 instance role. The region
+// defaults to AWS_REGION or AWS_DEFAULT_REGION, then us-east-1.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/crowdmob/goamz/aws"
+	"github.com/poptip/dynamo"
+)
+
+// command runs a subcommand with the arguments after its name.
+type command struct {
+	run     func(e *env, args []string) error
+	summary string
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"list-tables":    {listTables, "List tables"},
+		"describe":       {describe, "Describe a table"},
+		"create":         {create, "Create a table"},
+		"delete":         {deleteTable, "Delete a table"},
+		"set-throughput": {setThroughput, "Change the provisioned throughput of a table"},
+		"get":            {get, "Get an item by key"},
+		"put":            {put, "Put an item"},
+		"query":          {query, "Query a table or index"},
+		"scan":           {scan, "Scan a table"},
+		"select":         {selectItems, "Run a SQL-like SELECT statement"},
+		"export":         {exportTable, "Export a table to a file"},
+		"import":         {importTable, "Import a file into a table"},
+		"alarms":         {alarms, "List, create or delete the alarms of a table"},
+		"shell":          {shell, "Explore tables interactively"},
+	}
+}
+
+// env is what commands run with.
+type env struct {
+	c   *dynamo.Client
+	out *printer
+	in  io.Reader
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "dynamo:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("dynamo", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	region := fs.String("region", firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"), "AWS region")
+	endpoint := fs.String("endpoint", "", "DynamoDB endpoint, i.e. of DynamoDB Local, instead of the region's")
+	accessKey := fs.String("access-key", "", "AWS access key ID")
+	secretKey := fs.String("secret-key", "", "AWS secret access key")
+	profile := fs.String("profile", "", "Profile of the shared credentials file")
+	output := fs.String("output", "table", "Output format: table, json or dynamodb-json")
+	fs.Usage = func() {
+		fmt.Fprintln(stdout, "Usage: dynamo [flags] <command> [command flags] [args]\n\nCommands:")
+		names := []string{}
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(stdout, "  %-15s %s\n", name, commands[name].summary)
+		}
+		fmt.Fprintln(stdout, "\nFlags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("No command given")
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		return fmt.Errorf("Unknown command %q", fs.Arg(0))
+	}
+	out, err := newPrinter(stdout, *output)
+	if err != nil {
+		return err
+	}
+	c, err := newClient(*region, *endpoint, *accessKey, *secretKey, *profile)
+	if err != nil {
+		return err
+	}
+	return cmd.run(&env{c: c, out: out, in: stdin}, fs.Args()[1:])
+}
+
+func newClient(regionName, endpoint, accessKey, secretKey, profile string) (*dynamo.Client, error) {
+	if len(regionName) == 0 {
+		regionName = aws.USEast.Name
+	}
+	region, ok := aws.Regions[regionName]
+	if !ok {
+		return nil, fmt.Errorf("Unknown region %q", regionName)
+	}
+	if len(endpoint) > 0 {
+		region.DynamoDBEndpoint = endpoint
+	}
+	var p dynamo.CredentialsProvider
+	switch {
+	case len(accessKey) > 0 || len(secretKey) > 0:
+		p = dynamo.StaticProvider{AccessKey: accessKey, SecretKey: secretKey}
+	case len(profile) > 0:
+		p = dynamo.SharedFileProvider{Profile: profile}
+	default:
+		p = dynamo.DefaultProvider()
+	}
+	return dynamo.NewClientWithCredentials(p, region)
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); len(v) > 0 {
+			return v
+		}
+	}
+	return ""
+}
+
+// newFlags returns the flag set of a command, writing its usage and errors to the command's output like the top-level
+// usage. Its usage lists args after the flags.
+func (e *env) newFlags(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.out.w)
+	fs.Usage = func() {
+		fmt.Fprintf(e.out.w, "Usage: dynamo %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses the flags of a command, checking the number of args left.
+func parseArgs(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if n := fs.NArg(); n < min || (max >= 0 && n > max) {
+		fs.Usage()
+		return nil, fmt.Errorf("%s: wrong number of arguments", fs.Name())
+	}
+	return fs.Args(), nil
+}
+
+// splitList splits a comma separated flag, ignoring spaces and empty elements.
+func splitList(s string) []string {
+	list := []string{}
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); len(e) > 0 {
+			list = append(list, e)
+		}
+	}
+	return list
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/poptip/dynamo"
+)
+
+// serve answers requests with the response for their operation, recording the requests.
+func serve(t *testing.T, responses map[string]func(req map[string]interface{}) interface{}) (*httptest.Server, *[]map[string]interface{}) {
+	reqs := []map[string]interface{}{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), dynamo.DynamoBaseEndpoint)
+		b, _ := ioutil.ReadAll(r.Body)
+		req := map[string]interface{}{}
+		json.Unmarshal(b, &req)
+		reqs = append(reqs, req)
+		fn, ok := responses[op]
+		if !ok {
+			t.Errorf("Unexpected %s request", op)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(fn(req))
+	}))
+	return s, &reqs
+}
+
+func runTest(s *httptest.Server, args ...string) (string, error) {
+	out := &bytes.Buffer{}
+	args = append([]string{"-endpoint", s.URL, "-access-key", "key", "-secret-key", "secret"}, args...)
+	err := run(args, strings.NewReader(""), out)
+	return out.String(), err
+}
+
+var thingsTable = dynamo.TableDescriptionWrapper{Table: dynamo.TableDescription{
+	TableName:            "things",
+	TableStatus:          "ACTIVE",
+	KeySchema:            []dynamo.Key{{Name: "id", Type: dynamo.TypeHashKey}, {Name: "n", Type: dynamo.TypeRangeKey}},
+	AttributeDefinitions: []dynamo.AttributeDefinition{{Name: "id", Type: dynamo.TypeString}, {Name: "n", Type: dynamo.TypeNumber}},
+}}
+
+func TestListTables(t *testing.T) {
+	s, reqs := serve(t, map[string]func(map[string]interface{}) interface{}{
+		dynamo.ListTablesEndpoint: func(req map[string]interface{}) interface{} {
+			if req["ExclusiveStartTableName"] == "b" {
+				return dynamo.ListTablesResponse{TableNames: []string{"c"}}
+			}
+			return dynamo.ListTablesResponse{TableNames: []string{"a", "b"}, LastEvaluatedTableName: "b"}
+		},
+	})
+	defer s.Close()
+	out, err := runTest(s, "list-tables")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "Table\na\nb\nc\n" || len(*reqs) != 2 {
+		t.Errorf("Unexpected output %q after %d requests", out, len(*reqs))
+	}
+}
+
+func TestQuery(t *testing.T) {
+	s, reqs := serve(t, map[string]func(map[string]interface{}) interface{}{
+		dynamo.DescribeTableEndpoint: func(map[string]interface{}) interface{} { return thingsTable },
+		dynamo.QueryEndpoint: func(map[string]interface{}) interface{} {
+			return dynamo.QueryResponse{Items: []dynamo.AttributeSet{
+				{"id": {S: "a"}, "n": {N: "2"}, "tags": {SS: []string{"x", "y"}}},
+				{"id": {S: "a"}, "n": {N: "3"}, "name": {S: "three"}},
+			}}
+		},
+	})
+	defer s.Close()
+	out, err := runTest(s, "query", "things", "id=a", "n", "between", "1", "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "id  n  name   tags\na   2         {x, y}\na   3  three  \n"
+	if out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+	cond := (*reqs)[1]["KeyConditions"].(map[string]interface{})["n"].(map[string]interface{})
+	if cond["ComparisonOperator"] != dynamo.ConditionBetween || len(cond["AttributeValueList"].([]interface{})) != 2 {
+		t.Errorf("Unexpected range condition %v", cond)
+	}
+
+	out, err = runTest(s, "-output", "json", "query", "-limit", "1", "things", "id=a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != `{"id":"a","n":2,"tags":["x","y"]}`+"\n" {
+		t.Errorf("Unexpected JSON output %q", out)
+	}
+}
+
+func TestGetAndPut(t *testing.T) {
+	s, reqs := serve(t, map[string]func(map[string]interface{}) interface{}{
+		dynamo.DescribeTableEndpoint: func(map[string]interface{}) interface{} { return thingsTable },
+		dynamo.GetItemEndpoint: func(map[string]interface{}) interface{} {
+			return dynamo.GetResponse{Item: dynamo.AttributeSet{"id": {S: "a"}, "n": {N: "1"}}}
+		},
+		dynamo.PutItemEndpoint: func(map[string]interface{}) interface{} { return dynamo.UpdateResponse{} },
+	})
+	defer s.Close()
+	out, err := runTest(s, "-output", "dynamodb-json", "get", "things", "id=a", "n=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != `{"Item":{"id":{"S":"a"},"n":{"N":"1"}}}`+"\n" {
+		t.Errorf("Unexpected output %q", out)
+	}
+	key := (*reqs)[1]["Key"].(map[string]interface{})
+	if n := key["n"].(map[string]interface{}); n["N"] != "1" {
+		t.Errorf("Expected the range key to be typed by the table, got %v", key)
+	}
+	if _, err := runTest(s, "put", "things", `{"id":"b","n":2}`); err != nil {
+		t.Fatal(err)
+	}
+	item := (*reqs)[2]["Item"].(map[string]interface{})
+	if n := item["n"].(map[string]interface{}); n["N"] != "2" {
+		t.Errorf("Unexpected item %v", item)
+	}
+	if _, err := runTest(s, "delete", "things"); err == nil {
+		t.Error("Expected delete without -yes to fail")
+	}
+}
+
+func TestUsage(t *testing.T) {
+	s, _ := serve(t, nil)
+	defer s.Close()
+	if out, err := runTest(s); err == nil || !strings.HasPrefix(out, "Usage: dynamo [flags]") {
+		t.Errorf("Unexpected usage %q, %v", out, err)
+	}
+	if out, err := runTest(s, "describe"); err == nil || !strings.HasPrefix(out, "Usage: dynamo describe [flags] <table>") {
+		t.Errorf("Unexpected describe usage %q, %v", out, err)
+	}
+	if out, err := runTest(s, "scan", "-nope", "things"); err == nil || !strings.Contains(out, "flag provided but not defined: -nope") {
+		t.Errorf("Unexpected scan flag error %q, %v", out, err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/poptip/dynamo"
+	"github.com/poptip/dynamo/export"
+)
+
+const (
+	outputTable      = "table"
+	outputJSON       = "json"
+	outputDynamoJSON = "dynamodb-json"
+
+	maxCellWidth = 40 // Longer values are cut in table output.
+)
+
+// printer writes results in the output format.
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case outputTable, outputJSON, outputDynamoJSON:
+		return &printer{w: w, format: format}, nil
+	}
+	return nil, fmt.Errorf("Unknown output format %q", format)
+}
+
+// items prints items as a table with a column per attribute, key attributes first, or a JSON object per line.
+func (p *printer) items(items []dynamo.AttributeSet, keys []string) error {
+	if p.format != outputTable {
+		enc := json.NewEncoder(p.w)
+		for _, item := range items {
+			var err error
+			if p.format == outputDynamoJSON {
+				err = enc.Encode(struct{ Item dynamo.AttributeSet }{item})
+			} else {
+				err = enc.Encode(export.PlainItem(item))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	columns := itemColumns(items, keys)
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(columns, "\t"))
+	row := make([]string, len(columns))
+	for _, item := range items {
+		for i, name := range columns {
+			row[i] = cell(item[name])
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// itemColumns returns the attributes of items, the keys first and the rest sorted.
+func itemColumns(items []dynamo.AttributeSet, keys []string) []string {
+	seen := map[string]bool{}
+	for _, k := range keys {
+		seen[k] = true
+	}
+	rest := []string{}
+	for _, item := range items {
+		for name := range item {
+			if !seen[name] {
+				seen[name] = true
+				rest = append(rest, name)
+			}
+		}
+	}
+	sort.Strings(rest)
+	return append(append([]string{}, keys...), rest...)
+}
+
+func cell(val dynamo.AttributeVal) string {
+	var s string
+	switch {
+	case len(val.N) > 0:
+		s = val.N
+	case len(val.B) > 0:
+		s = val.B
+	case len(val.SS) > 0:
+		s = "{" + strings.Join(val.SS, ", ") + "}"
+	case len(val.NS) > 0:
+		s = "{" + strings.Join(val.NS, ", ") + "}"
+	case len(val.BS) > 0:
+		s = "{" + strings.Join(val.BS, ", ") + "}"
+	default:
+		s = val.S
+	}
+	s = strings.NewReplacer("\t", " ", "\n", " ").Replace(s)
+	if r := []rune(s); len(r) > maxCellWidth {
+		s = string(r[:maxCellWidth-3]) + "..."
+	}
+	return s
+}
+
+// value prints v as indented JSON, or as a list of fields in table output.
+func (p *printer) value(v interface{}, fields [][2]string) error {
+	if p.format != outputTable || fields == nil {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(p.w, string(b))
+		return err
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	for _, f := range fields {
+		fmt.Fprintf(tw, "%s:\t%s\n", f[0], f[1])
+	}
+	return tw.Flush()
+}
+
+// rows prints a table with the header, or a JSON object per row keyed by the header.
+func (p *printer) rows(header []string, rows [][]string) error {
+	if p.format != outputTable {
+		enc := json.NewEncoder(p.w)
+		for _, row := range rows {
+			obj := map[string]string{}
+			for i, h := range header {
+				obj[h] = row[i]
+			}
+			if err := enc.Encode(obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
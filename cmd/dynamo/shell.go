@@ -0,0 +1,557 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/poptip/dynamo"
+)
+
+const (
+	defaultPageSize = 20
+	sampleSize      = 25 // Items scanned to learn the attributes of a table.
+)
+
+// Operators of find conditions, and their conditions. Query accepts the range key operators only.
+var findOperators = map[string]string{
+	"=":           dynamo.ConditionEqual,
+	"!=":          dynamo.ConditionNotEqual,
+	"<":           dynamo.ConditionLessThan,
+	"<=":          dynamo.ConditionLessThanOrEqual,
+	">":           dynamo.ConditionGreaterThan,
+	">=":          dynamo.ConditionGreaterThanOrEqual,
+	"begins_with": dynamo.ConditionBeginsWith,
+	"contains":    dynamo.ConditionContains,
+	"between":     dynamo.ConditionBetween,
+}
+
+const shellHelp = `Commands:
+  tables                          List tables
+  use <table>                     Switch to a table, learning its attributes
+  describe [table]                Describe the current table or another one
+  get <name=value>...             Get an item of the current table by key
+  find [index <index>] [<cond> [and <cond>]...]
+                                  Query or scan the current table, a page at a time
+  select <statement>              Run a SELECT statement, see dynamo.Select
+  next                            Show the next page of the last find or select
+  limit <n>                       Set the number of items per page
+  history                         List the commands typed
+  help                            Show this help
+  exit                            Leave the shell
+
+Conditions are <attribute> <operator> <value>, or <attribute> between <value> and <value>. The operators are
+=, !=, <, <=, >, >=, begins_with and contains. Quoted values are strings; others are typed after the attribute.
+find queries when there's an equality on the hash key and at most one condition on the range key, and scans
+otherwise. Tab completes commands, tables and attributes.
+`
+
+// shellState is the state of an interactive shell.
+type shellState struct {
+	e        *env
+	ed       *lineEditor
+	history  string // File the history is appended to, if any.
+	pageSize int
+
+	table  string
+	desc   dynamo.TableDescription
+	attrs  map[string]string // Attribute types of the current table, learned from its description and items.
+	tables []string          // Cached for completion.
+	pager  *pager            // Of the last find or select.
+}
+
+func shell(e *env, args []string) error {
+	fs := e.newFlags("shell", "[table]")
+	history := fs.String("history", defaultHistoryFile(), "File the history is kept in, none if empty")
+	args, err := parseArgs(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	sh := &shellState{e: e, ed: newLineEditor(e.in, e.out.w), history: *history, pageSize: defaultPageSize}
+	sh.ed.complete = sh.complete
+	sh.loadHistory()
+	if len(args) == 1 {
+		if err := sh.use(args[0]); err != nil {
+			return err
+		}
+	}
+	for {
+		prompt := "dynamo> "
+		if len(sh.table) > 0 {
+			prompt = "dynamo:" + sh.table + "> "
+		}
+		line, err := sh.ed.readLine(prompt)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		words, err := splitWords(line)
+		if err == nil && len(words) > 0 {
+			sh.saveHistory(line)
+			if words[0].text == "exit" || words[0].text == "quit" {
+				return nil
+			}
+			if strings.EqualFold(words[0].text, "select") {
+				err = sh.selectStatement(line)
+			} else {
+				err = sh.exec(words)
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(e.out.w, "Error:", err)
+		}
+	}
+}
+
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".dynamo_history")
+}
+
+func (sh *shellState) loadHistory() {
+	f, err := os.Open(sh.history)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		sh.ed.addHistory(s.Text())
+	}
+}
+
+// saveHistory appends a line to the history file. Lines typed in a terminal are added to the editor's history as
+// they're read.
+func (sh *shellState) saveHistory(line string) {
+	if !sh.ed.terminal {
+		sh.ed.addHistory(line)
+	}
+	if len(sh.history) == 0 {
+		return
+	}
+	f, err := os.OpenFile(sh.history, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+func (sh *shellState) exec(words []word) error {
+	args := make([]string, len(words)-1)
+	for i, w := range words[1:] {
+		args[i] = w.text
+	}
+	switch words[0].text {
+	case "help":
+		fmt.Fprint(sh.e.out.w, shellHelp)
+		return nil
+	case "tables":
+		sh.tables = nil
+		return listTables(sh.e, nil)
+	case "use":
+		if len(args) != 1 {
+			return errors.New("use <table>")
+		}
+		return sh.use(args[0])
+	case "describe":
+		if len(args) == 0 && len(sh.table) > 0 {
+			args = []string{sh.table}
+		}
+		return describe(sh.e, args)
+	case "get":
+		if err := sh.needTable(); err != nil {
+			return err
+		}
+		return get(sh.e, append([]string{sh.table}, args...))
+	case "find":
+		if err := sh.needTable(); err != nil {
+			return err
+		}
+		return sh.find(words[1:])
+	case "next":
+		if sh.pager == nil || !sh.pager.more() {
+			return errors.New("No more items")
+		}
+		return sh.showPage()
+	case "limit":
+		n, err := strconv.Atoi(strings.Join(args, " "))
+		if err != nil || n <= 0 {
+			return errors.New("limit <n>, n > 0")
+		}
+		sh.pageSize = n
+		return nil
+	case "history":
+		for i, line := range sh.ed.history {
+			fmt.Fprintf(sh.e.out.w, "%4d  %s\n", i+1, line)
+		}
+		return nil
+	}
+	return fmt.Errorf("Unknown command %q, try help", words[0].text)
+}
+
+func (sh *shellState) needTable() error {
+	if len(sh.table) == 0 {
+		return errors.New("No table, use <table> first")
+	}
+	return nil
+}
+
+// use switches to a table, learning its attributes from its description and a sample of its items.
+func (sh *shellState) use(table string) error {
+	desc, err := sh.e.c.DescribeTable(table)
+	if err != nil {
+		return err
+	}
+	sh.table, sh.desc, sh.attrs, sh.pager = table, desc, map[string]string{}, nil
+	items, _, err := sh.e.c.RawScan(dynamo.ScanRequest{TableName: table, Limit: sampleSize, TotalSegments: 1})
+	if err != nil {
+		return err
+	}
+	sh.learn(items)
+	fmt.Fprintf(sh.e.out.w, "Using %s: %d attributes known\n", table, len(sh.attrs))
+	return nil
+}
+
+// learn records the attributes of items. Types of the attribute definitions take precedence.
+func (sh *shellState) learn(items []dynamo.AttributeSet) {
+	for _, item := range items {
+		for name, val := range item {
+			if _, ok := sh.attrs[name]; !ok {
+				sh.attrs[name] = valueType(val)
+			}
+		}
+	}
+	for name, typ := range attributeTypes(sh.desc) {
+		sh.attrs[name] = typ
+	}
+}
+
+func valueType(val dynamo.AttributeVal) string {
+	switch {
+	case len(val.N) > 0:
+		return dynamo.TypeNumber
+	case len(val.B) > 0:
+		return dynamo.TypeBinary
+	case len(val.SS) > 0:
+		return dynamo.TypeStringSet
+	case len(val.NS) > 0:
+		return dynamo.TypeNumberSet
+	case len(val.BS) > 0:
+		return dynamo.TypeBinarySet
+	}
+	return dynamo.TypeString
+}
+
+// findCondition is a condition of find.
+type findCondition struct {
+	name string
+	op   string
+	vals []word
+}
+
+// find parses a find command, plans it and shows the first page of results.
+func (sh *shellState) find(words []word) error {
+	index := ""
+	if len(words) > 0 && words[0].text == "index" {
+		if len(words) < 2 {
+			return errors.New("index <index> expected")
+		}
+		index, words = words[1].text, words[2:]
+	}
+	conds := []findCondition{}
+	for len(words) > 0 {
+		if len(conds) > 0 {
+			if !strings.EqualFold(words[0].text, "and") {
+				return fmt.Errorf("and expected instead of %q", words[0].text)
+			}
+			words = words[1:]
+		}
+		if len(words) < 3 {
+			return errors.New("Conditions are <attribute> <operator> <value>")
+		}
+		cond := findCondition{name: words[0].text, op: strings.ToLower(words[1].text), vals: words[2:3]}
+		if _, ok := findOperators[cond.op]; !ok {
+			return fmt.Errorf("Unknown operator %q", words[1].text)
+		}
+		words = words[3:]
+		if cond.op == "between" {
+			if len(words) < 2 || !strings.EqualFold(words[0].text, "and") {
+				return errors.New("between <value> and <value> expected")
+			}
+			cond.vals, words = append(cond.vals, words[1]), words[2:]
+		}
+		for _, c := range conds {
+			if c.name == cond.name {
+				return fmt.Errorf("More than one condition on %s", cond.name)
+			}
+		}
+		conds = append(conds, cond)
+	}
+	p, explanation, err := sh.plan(index, conds)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.e.out.w, explanation)
+	sh.pager = p
+	return sh.showPage()
+}
+
+// plan compiles conditions with dynamo.Select's planner, returning a pager of the results and the explanation of
+// the choice between query and scan.
+func (sh *shellState) plan(index string, conds []findCondition) (*pager, string, error) {
+	sel := dynamo.Select{Table: sh.table, Index: index}
+	for _, c := range conds {
+		pred := dynamo.Predicate{Attribute: c.name, Condition: dynamo.Condition{ComparisonOperator: findOperators[c.op]}}
+		for _, v := range c.vals {
+			pred.AttributeValueList = append(pred.AttributeValueList, sh.value(c.name, v))
+		}
+		sel.Where = append(sel.Where, pred)
+	}
+	plan, err := sel.Plan(sh.desc)
+	if err != nil {
+		return nil, "", err
+	}
+	return sh.planPager(plan), plan.Explanation, nil
+}
+
+// selectStatement runs a SELECT statement, showing the first page of results.
+func (sh *shellState) selectStatement(stmt string) error {
+	plan, err := sh.e.c.Explain(stmt)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.e.out.w, plan.Explanation)
+	sh.pager = sh.planPager(plan)
+	return sh.showPage()
+}
+
+func (sh *shellState) planPager(plan dynamo.Plan) *pager {
+	var keys []string
+	if table := planTable(plan); table == sh.table {
+		keys = keyNames(sh.desc.KeySchema)
+	}
+	p := newPager(keys, func(start dynamo.AttributeSet) ([]dynamo.AttributeSet, dynamo.AttributeSet, error) {
+		return sh.e.c.RunPlan(plan, start)
+	})
+	p.limit = plan.Limit
+	return p
+}
+
+func planTable(plan dynamo.Plan) string {
+	if plan.Query != nil {
+		return plan.Query.TableName
+	}
+	return plan.Scan.TableName
+}
+
+// value types a value of a condition: quoted values are strings, others are typed after the attribute, or are
+// numbers if they look like one.
+func (sh *shellState) value(name string, w word) dynamo.AttributeVal {
+	if w.quoted {
+		return dynamo.AttributeVal{S: w.text}
+	}
+	typ, ok := sh.attrs[name]
+	switch {
+	case typ == dynamo.TypeNumberSet:
+		typ = dynamo.TypeNumber
+	case typ == dynamo.TypeBinarySet:
+		typ = dynamo.TypeBinary
+	case !ok && dynamo.NumberRegex.MatchString(w.text):
+		typ = dynamo.TypeNumber
+	}
+	return attributeValue(w.text, typ)
+}
+
+func (sh *shellState) showPage() error {
+	items, err := sh.pager.page(sh.pageSize)
+	if err != nil {
+		return err
+	}
+	sh.learn(items)
+	if len(items) == 0 {
+		fmt.Fprintln(sh.e.out.w, "No items")
+		return nil
+	}
+	if err := sh.e.out.items(items, sh.pager.keys); err != nil {
+		return err
+	}
+	if sh.pager.more() {
+		fmt.Fprintf(sh.e.out.w, "-- %d items shown, type next for more --\n", sh.pager.shown)
+	} else {
+		fmt.Fprintf(sh.e.out.w, "-- %d items --\n", sh.pager.shown)
+	}
+	return nil
+}
+
+// complete returns the words that can follow head: commands first, then tables or attributes.
+func (sh *shellState) complete(head string) []string {
+	words, err := splitWords(head)
+	if err != nil {
+		return nil
+	}
+	if len(words) == 0 {
+		return []string{"tables", "use", "describe", "get", "find", "select", "next", "limit", "history", "help", "exit"}
+	}
+	switch words[0].text {
+	case "use", "describe":
+		if len(words) == 1 {
+			return sh.tableNames()
+		}
+	case "get":
+		names := []string{}
+		for _, name := range keyNames(sh.desc.KeySchema) {
+			names = append(names, name+"=")
+		}
+		return names
+	case "find":
+		last := words[len(words)-1].text
+		if last == "index" {
+			names := []string{}
+			for _, idx := range sh.desc.LocalSecondaryIndexes {
+				names = append(names, idx.IndexName)
+			}
+			for _, idx := range sh.desc.GlobalSecondaryIndexes {
+				names = append(names, idx.IndexName)
+			}
+			return names
+		}
+		if _, ok := sh.attrs[last]; ok && len(words) > 1 {
+			ops := []string{}
+			for op := range findOperators {
+				ops = append(ops, op)
+			}
+			return ops
+		}
+		names := []string{"and"}
+		if len(words) == 1 {
+			names = []string{"index"}
+		}
+		for name := range sh.attrs {
+			names = append(names, name)
+		}
+		return names
+	}
+	return nil
+}
+
+// tableNames lists the tables once, for completion.
+func (sh *shellState) tableNames() []string {
+	if sh.tables != nil {
+		return sh.tables
+	}
+	tables := []string{}
+	start := ""
+	for {
+		names, last, err := sh.e.c.ListTables(start, 100)
+		if err != nil {
+			return nil
+		}
+		tables = append(tables, names...)
+		if len(last) == 0 {
+			break
+		}
+		start = last
+	}
+	sh.tables = tables
+	return tables
+}
+
+// pager fetches the results of a query or scan a page at a time, whatever the number of items the pages of the
+// response have.
+type pager struct {
+	keys  []string
+	fetch func(start dynamo.AttributeSet) ([]dynamo.AttributeSet, dynamo.AttributeSet, error)
+	last  dynamo.AttributeSet
+	buf   []dynamo.AttributeSet
+	done  bool
+	shown int
+	limit int // Items shown at most, unlimited if 0.
+}
+
+func newPager(keys []string, fetch func(dynamo.AttributeSet) ([]dynamo.AttributeSet, dynamo.AttributeSet, error)) *pager {
+	return &pager{keys: keys, fetch: fetch}
+}
+
+// page returns the next n items, or fewer at the end.
+func (p *pager) page(n int) ([]dynamo.AttributeSet, error) {
+	if p.limit > 0 && n > p.limit-p.shown {
+		n = p.limit - p.shown
+	}
+	for len(p.buf) < n && !p.done {
+		items, last, err := p.fetch(p.last)
+		if err != nil {
+			return nil, err
+		}
+		p.buf = append(p.buf, items...)
+		p.last, p.done = last, len(last) == 0
+	}
+	if n > len(p.buf) {
+		n = len(p.buf)
+	}
+	items := p.buf[:n]
+	p.buf = p.buf[n:]
+	p.shown += n
+	return items, nil
+}
+
+func (p *pager) more() bool {
+	if p.limit > 0 && p.shown >= p.limit {
+		return false
+	}
+	return len(p.buf) > 0 || !p.done
+}
+
+// word is a word of a shell line, remembering whether it was quoted.
+type word struct {
+	text   string
+	quoted bool
+}
+
+// splitWords splits a line on spaces. Single or double quotes keep spaces in words, and a backslash escapes the next
+// character.
+func splitWords(line string) ([]word, error) {
+	words := []word{}
+	cur := []rune{}
+	in, quote, quoted := false, rune(0), false
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur, escaped = append(cur, r), false
+		case r == '\\':
+			in, escaped = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur = append(cur, r)
+			}
+		case r == '"' || r == '\'':
+			in, quote, quoted = true, r, true
+		case r == ' ' || r == '\t':
+			if in {
+				words = append(words, word{string(cur), quoted})
+			}
+			cur, in, quoted = cur[:0], false, false
+		default:
+			cur, in = append(cur, r), true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("Unterminated quote")
+	}
+	if in {
+		words = append(words, word{string(cur), quoted})
+	}
+	return words, nil
+}
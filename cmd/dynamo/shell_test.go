@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/poptip/dynamo"
+)
+
+func TestShell(t *testing.T) {
+	s, reqs := serve(t, map[string]func(map[string]interface{}) interface{}{
+		dynamo.DescribeTableEndpoint: func(map[string]interface{}) interface{} { return thingsTable },
+		dynamo.ScanEndpoint: func(req map[string]interface{}) interface{} {
+			if req["ExclusiveStartKey"] == nil {
+				return dynamo.QueryResponse{
+					Items:            []dynamo.AttributeSet{{"id": {S: "a"}, "n": {N: "1"}, "size": {N: "4"}}},
+					LastEvaluatedKey: dynamo.AttributeSet{"id": {S: "a"}, "n": {N: "1"}},
+				}
+			}
+			return dynamo.QueryResponse{Items: []dynamo.AttributeSet{{"id": {S: "b"}, "n": {N: "2"}, "size": {N: "5"}}}}
+		},
+		dynamo.QueryEndpoint: func(map[string]interface{}) interface{} {
+			return dynamo.QueryResponse{Items: []dynamo.AttributeSet{{"id": {S: "a"}, "n": {N: "2"}}}}
+		},
+	})
+	defer s.Close()
+	script := "find id = a\nuse things\nfind id = a and n > 1\nlimit 1\nfind size >= '3'\nnext\nnext\nSELECT id FROM things WHERE id = 'a' LIMIT 1\n"
+	out := &bytes.Buffer{}
+	args := []string{"-endpoint", s.URL, "-access-key", "key", "-secret-key", "secret", "shell", "-history", ""}
+	if err := run(args, strings.NewReader(script), out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Error: No table, use <table> first",
+		"Using things: 3 attributes known",
+		"Query on things with key conditions on id, n",
+		"Scan of things filtered on size: no equality on hash key id",
+		"-- 1 items shown, type next for more --",
+		"-- 2 items --",
+		"Error: No more items",
+		"Query on things with key conditions on id\nid  n\na   2\n-- 1 items --",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Output lacks %q:\n%s", want, out.String())
+		}
+	}
+	if len(*reqs) != 7 {
+		t.Fatalf("Unexpected %d requests", len(*reqs))
+	}
+	keys := (*reqs)[2]["KeyConditions"].(map[string]interface{})
+	if n := keys["n"].(map[string]interface{}); n["ComparisonOperator"] != dynamo.ConditionGreaterThan {
+		t.Errorf("Unexpected range condition %v", n)
+	}
+	filter := (*reqs)[3]["ScanFilter"].(map[string]interface{})
+	val := filter["size"].(map[string]interface{})["AttributeValueList"].([]interface{})[0]
+	if val.(map[string]interface{})["S"] != "3" {
+		t.Errorf("Quoted value isn't a string: %v", val)
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	words, err := splitWords(`find name = "a b" and  x = c\ d`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := []string{}
+	for _, w := range words {
+		texts = append(texts, w.text)
+	}
+	if strings.Join(texts, "|") != "find|name|=|a b|and|x|=|c d" || !words[3].quoted || words[7].quoted {
+		t.Errorf("Unexpected words %q", texts)
+	}
+	if _, err := splitWords(`find name = "a`); err == nil {
+		t.Error("Unterminated quote accepted")
+	}
+}
+
+func TestLineEditor(t *testing.T) {
+	keys := "us\tth\t\r" + // Completion.
+		"f\x1b[Dx\x1b[Cy\r" + // Cursor movement.
+		"\x1b[A\x1b[A\x7f\r" + // History.
+		"\x04"
+	ed := &lineEditor{r: bufio.NewReader(strings.NewReader(keys)), w: &bytes.Buffer{}}
+	ed.complete = func(head string) []string {
+		if len(head) == 0 {
+			return []string{"use", "tables"}
+		}
+		return []string{"things", "others"}
+	}
+	for _, want := range []string{"use things ", "xfy", "use things"} {
+		line, err := ed.edit("> ")
+		if err != nil {
+			t.Fatal(err)
+		} else if line != want {
+			t.Errorf("Line is %q instead of %q", line, want)
+		}
+	}
+	if _, err := ed.edit("> "); err == nil {
+		t.Error("No error on Ctrl-D")
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	// "é" and "è" share their first byte, which mustn't be completed on its own.
+	if prefix := commonPrefix([]string{"café", "cafè"}); prefix != "caf" {
+		t.Errorf("Common prefix is %q", prefix)
+	}
+	if prefix := commonPrefix([]string{"thing", "things"}); prefix != "thing" {
+		t.Errorf("Common prefix is %q", prefix)
+	}
+}
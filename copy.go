@@ -0,0 +1,341 @@
+package dynamo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+const maxVerifyKeys = 100 // Missing and extra keys kept by VerifyCopy.
+
+// errScanStopped stops the segments of a parallel scan once one of them failed.
+var errScanStopped = errors.New("Scan stopped after another segment failed")
+
+// TransformFunc turns an item of the source table into the items to write to the destination table. Returning no
+// items skips it.
+type TransformFunc func(item AttributeSet) ([]AttributeSet, error)
+
+// CopyOptions configure CopyTable. The zero value copies with a single, unlimited scan.
+type CopyOptions struct {
+	Segments  int     // Scanned in parallel, defaults to 1.
+	ReadRate  float64 // Read capacity units per second used on the source table, unlimited if 0.
+	WriteRate float64 // Write capacity units per second used on the destination table, unlimited if 0.
+	DryRun    bool    // Scan and transform without writing, counting what would be written.
+	Verify    bool    // Run VerifyCopy once the copy completes.
+
+	// Checkpoint is a file recording the progress of every segment. If it exists when the copy starts, the copy
+	// resumes where it stopped. It's removed once the copy completes. Dry runs and copies can't resume each other.
+	Checkpoint string
+
+	Progress func(CopyStats) // Called after every page, never concurrently.
+}
+
+// CopyStats counts the items of a copy.
+type CopyStats struct {
+	Scanned int64 // Items read from the source table.
+	Written int64 // Items written to the destination table, or that would be in a dry run.
+	Skipped int64 // Source items the transform returned no items for.
+	Pages   int64
+	Verify  *VerifyResult `json:",omitempty"` // Only set when verifying.
+}
+
+// VerifyResult compares the destination table of a copy to the transformed source table.
+type VerifyResult struct {
+	Expected     int64          // Distinct keys the transformed source items have.
+	Found        int64          // Items in the destination table.
+	MissingCount int64          // Expected keys that aren't in the destination table.
+	ExtraCount   int64          // Keys in the destination table that weren't expected.
+	Missing      []AttributeSet // The first 100 missing keys.
+	Extra        []AttributeSet // The first 100 extra keys.
+}
+
+// OK reports whether the destination has exactly the expected keys.
+func (r VerifyResult) OK() bool {
+	return r.MissingCount == 0 && r.ExtraCount == 0
+}
+
+type copyCheckpoint struct {
+	Source, Destination string
+	DryRun              bool
+	Stats               CopyStats
+	Segments            []segmentState
+}
+
+// segmentState is where a segment of a parallel scan is at.
+type segmentState struct {
+	LastKey AttributeSet `json:",omitempty"`
+	Done    bool
+}
+
+// CopyTable scans src in parallel segments, passes every item through transform and writes the results to dst with
+// batch writes. A nil transform copies items as they are. When ReadRate or WriteRate is set the copy uses a rate limiter
+// of its own, leaving the client's rate limiter as it is.
+func (c *Client) CopyTable(src, dst string, transform TransformFunc, opts CopyOptions) (CopyStats, error) {
+	if transform == nil {
+		transform = func(item AttributeSet) ([]AttributeSet, error) { return []AttributeSet{item}, nil }
+	}
+	if opts.Segments <= 0 {
+		opts.Segments = 1
+	}
+	if opts.ReadRate > 0 || opts.WriteRate > 0 {
+		l := NewRateLimiter()
+		l.SetReadRate(src, opts.ReadRate)
+		l.SetWriteRate(dst, opts.WriteRate)
+		c = c.WithRateLimiter(l)
+	}
+	cp := copyCheckpoint{Source: src, Destination: dst, DryRun: opts.DryRun, Segments: make([]segmentState, opts.Segments)}
+	if err := loadCopyCheckpoint(opts.Checkpoint, &cp); err != nil {
+		return cp.Stats, err
+	}
+	cj := &copyJob{c: c, dst: dst, transform: transform, opts: opts, cp: cp}
+	if err := c.scanSegments(src, cj.cp.Segments, cj.scanSegment); err != nil {
+		return cj.cp.Stats, err
+	}
+	if len(opts.Checkpoint) > 0 {
+		if err := os.Remove(opts.Checkpoint); err != nil && !os.IsNotExist(err) {
+			return cj.cp.Stats, err
+		}
+	}
+	if opts.Verify && !opts.DryRun {
+		res, err := c.VerifyCopy(src, dst, transform, opts.Segments)
+		cj.cp.Stats.Verify = &res
+		return cj.cp.Stats, err
+	}
+	return cj.cp.Stats, nil
+}
+
+type copyJob struct {
+	c         *Client
+	dst       string
+	transform TransformFunc
+	opts      CopyOptions
+
+	mu sync.Mutex // Guards cp.
+	cp copyCheckpoint
+}
+
+// scanSegment copies a segment, page by page. Pages are written before the segment's progress is saved.
+func (cj *copyJob) scanSegment(seg int, scan func(ItemFunc) (QueryResponse, error)) error {
+	bw := cj.c.NewBatchWriter(cj.dst)
+	for {
+		stats := CopyStats{Pages: 1}
+		res, err := scan(func(item AttributeSet) error {
+			stats.Scanned++
+			// Items passed to ItemFunc are reused, and this one may be buffered by bw.
+			kept := make(AttributeSet, len(item))
+			for name, val := range item {
+				kept[name] = val
+			}
+			items, err := cj.transform(kept)
+			if err != nil {
+				return err
+			} else if len(items) == 0 {
+				stats.Skipped++
+			}
+			for _, item := range items {
+				stats.Written++
+				if cj.opts.DryRun {
+					continue
+				} else if err := bw.Put(item); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err == nil {
+			err = bw.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		done, err := cj.pageDone(seg, res.LastEvaluatedKey, stats)
+		if done || err != nil {
+			return err
+		}
+	}
+}
+
+// pageDone adds the stats of a page and saves the checkpoint, reporting whether the segment is done.
+func (cj *copyJob) pageDone(seg int, last AttributeSet, stats CopyStats) (bool, error) {
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+	cj.cp.Segments[seg] = segmentState{LastKey: last, Done: len(last) == 0}
+	cj.cp.Stats.Scanned += stats.Scanned
+	cj.cp.Stats.Written += stats.Written
+	cj.cp.Stats.Skipped += stats.Skipped
+	cj.cp.Stats.Pages += stats.Pages
+	if len(cj.opts.Checkpoint) > 0 {
+		if err := saveCheckpoint(cj.opts.Checkpoint, cj.cp); err != nil {
+			return false, err
+		}
+	}
+	if cj.opts.Progress != nil {
+		cj.opts.Progress(cj.cp.Stats)
+	}
+	return len(last) == 0, nil
+}
+
+// VerifyCopy scans both tables of a copy, comparing the keys of the transformed source items to the keys in dst. The
+// keys are kept in memory.
+func (c *Client) VerifyCopy(src, dst string, transform TransformFunc, segments int) (VerifyResult, error) {
+	res := VerifyResult{}
+	desc, err := c.DescribeTable(dst)
+	if err != nil {
+		return res, err
+	}
+	if segments <= 0 {
+		segments = 1
+	}
+	keyOf := func(item AttributeSet) (string, AttributeSet) {
+		key := AttributeSet{}
+		for _, k := range desc.KeySchema {
+			key[k.Name] = item[k.Name]
+		}
+		b, _ := json.Marshal(key)
+		return string(b), key
+	}
+	mu := sync.Mutex{}
+	expected := map[string]bool{}
+	err = c.scanSegments(src, make([]segmentState, segments), func(seg int, scan func(ItemFunc) (QueryResponse, error)) error {
+		return scanAll(scan, func(item AttributeSet) error {
+			items := []AttributeSet{item}
+			if transform != nil {
+				var err error
+				if items, err = transform(item); err != nil {
+					return err
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, item := range items {
+				k, _ := keyOf(item)
+				expected[k] = true
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return res, err
+	}
+	res.Expected = int64(len(expected))
+	err = c.scanSegments(dst, make([]segmentState, segments), func(seg int, scan func(ItemFunc) (QueryResponse, error)) error {
+		return scanAll(scan, func(item AttributeSet) error {
+			k, key := keyOf(item)
+			mu.Lock()
+			defer mu.Unlock()
+			res.Found++
+			if expected[k] {
+				delete(expected, k)
+				return nil
+			}
+			res.ExtraCount++
+			if len(res.Extra) < maxVerifyKeys {
+				res.Extra = append(res.Extra, key)
+			}
+			return nil
+		})
+	})
+	for k := range expected {
+		res.MissingCount++
+		if len(res.Missing) < maxVerifyKeys {
+			key := AttributeSet{}
+			json.Unmarshal([]byte(k), &key)
+			res.Missing = append(res.Missing, key)
+		}
+	}
+	return res, err
+}
+
+// scanSegments scans table in parallel, one segment per state. Segments that are done are skipped, the others start
+// from their last key. fn is called for each segment with a function scanning its next page. Once a segment fails the
+// others stop before their next page, and the first error is returned once every segment stopped.
+func (c *Client) scanSegments(table string, states []segmentState, fn func(seg int, scan func(ItemFunc) (QueryResponse, error)) error) error {
+	wg := sync.WaitGroup{}
+	errs := make([]error, len(states))
+	var failed int32
+	for i, s := range states {
+		if s.Done {
+			continue
+		}
+		wg.Add(1)
+		go func(seg int, last AttributeSet) {
+			defer wg.Done()
+			errs[seg] = fn(seg, func(items ItemFunc) (QueryResponse, error) {
+				if atomic.LoadInt32(&failed) != 0 {
+					return QueryResponse{}, errScanStopped
+				}
+				res, err := c.StreamScan(ScanRequest{
+					TableName:         table,
+					Segment:           seg,
+					TotalSegments:     len(states),
+					ExclusiveStartKey: last,
+				}, items)
+				last = res.LastEvaluatedKey
+				return res, err
+			})
+			if errs[seg] != nil {
+				atomic.StoreInt32(&failed, 1)
+			}
+		}(i, s.LastKey)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil && err != errScanStopped {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanAll scans pages until the last one.
+func scanAll(scan func(ItemFunc) (QueryResponse, error), fn ItemFunc) error {
+	for {
+		res, err := scan(fn)
+		if err != nil || len(res.LastEvaluatedKey) == 0 {
+			return err
+		}
+	}
+}
+
+func loadCopyCheckpoint(filename string, cp *copyCheckpoint) error {
+	if len(filename) == 0 {
+		return nil
+	}
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	saved := copyCheckpoint{}
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return fmt.Errorf("Invalid checkpoint %s: %s", filename, err.Error())
+	}
+	if saved.Source != cp.Source || saved.Destination != cp.Destination || len(saved.Segments) != len(cp.Segments) {
+		return fmt.Errorf("Checkpoint %s is of a copy from %s to %s in %d segments", filename, saved.Source, saved.Destination, len(saved.Segments))
+	}
+	if saved.DryRun && !cp.DryRun {
+		return fmt.Errorf("Checkpoint %s is of a dry run, which didn't write anything", filename)
+	} else if !saved.DryRun && cp.DryRun {
+		return fmt.Errorf("Checkpoint %s is of a copy that isn't a dry run", filename)
+	}
+	*cp = saved
+	return nil
+}
+
+// saveCheckpoint writes v as JSON to filename, replacing it atomically.
+func saveCheckpoint(filename string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
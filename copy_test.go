@@ -0,0 +1,214 @@
+package dynamo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeTables serves Scan, BatchWriteItem and DescribeTable on in-memory tables keyed by "id", two items per page.
+type fakeTables struct {
+	mu       sync.Mutex
+	tables   map[string]map[string]AttributeSet
+	failScan string // Scans of this table fail.
+}
+
+func (f *fakeTables) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, _ := ioutil.ReadAll(r.Body)
+	switch strings.TrimPrefix(r.Header.Get("X-Amz-Target"), DynamoBaseEndpoint) {
+	case DescribeTableEndpoint:
+		req := BasicRequest{}
+		json.Unmarshal(b, &req)
+		json.NewEncoder(w).Encode(TableDescriptionWrapper{Table: TableDescription{TableName: req.TableName, KeySchema: []Key{{"id", TypeHashKey}}}})
+	case BatchWriteItemEndpoint:
+		req := BatchWriteRequest{}
+		json.Unmarshal(b, &req)
+		for table, items := range req.RequestItems {
+			for _, item := range items {
+				f.tables[table][item.PutRequest.Item["id"].S] = item.PutRequest.Item
+			}
+		}
+		w.Write([]byte(`{}`))
+	case ScanEndpoint:
+		req := ScanRequest{}
+		json.Unmarshal(b, &req)
+		if req.TableName == f.failScan {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#InternalServerError","message":"Failing"}`))
+			return
+		}
+		ids := []string{}
+		for id := range f.tables[req.TableName] {
+			if int(id[len(id)-1])%req.TotalSegments == req.Segment && id > req.ExclusiveStartKey["id"].S {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		res := QueryResponse{Items: []AttributeSet{}}
+		for _, id := range ids {
+			if len(res.Items) == 2 {
+				res.LastEvaluatedKey = AttributeSet{"id": {S: res.Items[1]["id"].S}}
+				break
+			}
+			res.Items = append(res.Items, f.tables[req.TableName][id])
+		}
+		res.Count = len(res.Items)
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+func newFakeTables(n int) *fakeTables {
+	f := &fakeTables{tables: map[string]map[string]AttributeSet{"src": {}, "dst": {}}}
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("item-%02d", i)
+		f.tables["src"][id] = AttributeSet{"id": {S: id}, "n": {N: fmt.Sprint(i)}}
+	}
+	return f
+}
+
+// splitOdd rekeys items and splits the ones with odd numbers in two.
+func splitOdd(item AttributeSet) ([]AttributeSet, error) {
+	id := item["id"].S
+	if item["n"].N == "0" {
+		return nil, nil
+	}
+	items := []AttributeSet{{"id": {S: "new-" + id}}}
+	if id[len(id)-1]%2 == 1 {
+		items = append(items, AttributeSet{"id": {S: "extra-" + id}})
+	}
+	return items, nil
+}
+
+func TestCopyTable(t *testing.T) {
+	f := newFakeTables(10)
+	c, s := newTestClient(f.ServeHTTP)
+	defer s.Close()
+	stats, err := c.CopyTable("src", "dst", splitOdd, CopyOptions{Segments: 3, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Scanned != 10 || stats.Written != 14 || stats.Skipped != 1 || len(f.tables["dst"]) != 0 {
+		t.Errorf("Unexpected dry run %+v", stats)
+	}
+	stats, err = c.CopyTable("src", "dst", splitOdd, CopyOptions{Segments: 3, Verify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Written != 14 || len(f.tables["dst"]) != 14 || !stats.Verify.OK() || stats.Verify.Found != 14 {
+		t.Errorf("Unexpected copy %+v, verified %+v", stats, stats.Verify)
+	}
+
+	f.tables["dst"]["stray"] = AttributeSet{"id": {S: "stray"}}
+	delete(f.tables["dst"], "new-item-04")
+	res, err := c.VerifyCopy("src", "dst", splitOdd, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK() || res.MissingCount != 1 || res.Missing[0]["id"].S != "new-item-04" || res.Extra[0]["id"].S != "stray" {
+		t.Errorf("Unexpected verification %+v", res)
+	}
+}
+
+func TestCopyTableResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	checkpoint := filepath.Join(dir, "checkpoint")
+	f := newFakeTables(9)
+	c, s := newTestClient(f.ServeHTTP)
+	defer s.Close()
+	opts := CopyOptions{Checkpoint: checkpoint, Progress: func(stats CopyStats) {
+		if stats.Pages == 2 {
+			f.failScan = "src"
+		}
+	}}
+	if stats, err := c.CopyTable("src", "dst", nil, opts); err == nil || stats.Written != 4 {
+		t.Fatalf("Expected the copy to fail after 4 items, got %v and %+v", err, stats)
+	}
+	f.failScan = ""
+	opts.Progress = nil
+	stats, err := c.CopyTable("src", "dst", nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Scanned != 9 || len(f.tables["dst"]) != 9 {
+		t.Errorf("Expected 9 items to be copied, got %+v", stats)
+	}
+	if _, err := os.Stat(checkpoint); !os.IsNotExist(err) {
+		t.Error("Expected the checkpoint to be removed")
+	}
+}
+
+func TestCopyTableRates(t *testing.T) {
+	f := newFakeTables(4)
+	limited := 0
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if strings.Contains(string(b), `"ReturnConsumedCapacity"`) {
+			limited++
+		}
+		r.Body = ioutil.NopCloser(strings.NewReader(string(b)))
+		f.ServeHTTP(w, r)
+	})
+	defer s.Close()
+	if _, err := c.CopyTable("src", "dst", nil, CopyOptions{ReadRate: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if limited == 0 || c.limiter != nil {
+		t.Errorf("Expected a limiter of the copy's own, %d limited requests and client limiter %v", limited, c.limiter)
+	}
+
+	shared := NewRateLimiter()
+	shared.SetRate("src", 10, 0)
+	shared.SetRate("dst", 0, 20)
+	c.SetRateLimiter(shared)
+	if _, err := c.CopyTable("src", "dst", nil, CopyOptions{ReadRate: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if read, _ := shared.Rate("src"); read != 10 {
+		t.Errorf("Copy changed the client's read rate to %v", read)
+	}
+	if _, write := shared.Rate("dst"); write != 20 {
+		t.Errorf("Copy changed the client's write rate to %v", write)
+	}
+	if c.limiter != shared {
+		t.Error("Copy replaced the client's limiter")
+	}
+}
+
+func TestCopyTableDryRunCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	checkpoint := filepath.Join(dir, "checkpoint")
+	f := newFakeTables(9)
+	c, s := newTestClient(f.ServeHTTP)
+	defer s.Close()
+	opts := CopyOptions{Checkpoint: checkpoint, DryRun: true, Progress: func(stats CopyStats) {
+		f.failScan = "src"
+	}}
+	if _, err := c.CopyTable("src", "dst", nil, opts); err == nil {
+		t.Fatal("Expected the dry run to fail")
+	}
+	f.failScan = ""
+	if _, err := c.CopyTable("src", "dst", nil, CopyOptions{Checkpoint: checkpoint}); err == nil || len(f.tables["dst"]) != 0 {
+		t.Errorf("Resumed a dry run with a copy: %v, copied %d items", err, len(f.tables["dst"]))
+	}
+	opts.Progress = nil
+	if stats, err := c.CopyTable("src", "dst", nil, opts); err != nil || stats.Scanned != 9 {
+		t.Errorf("Resumed dry run %+v, %v", stats, err)
+	}
+}
@@ -0,0 +1,404 @@
+package dynamo
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crowdmob/goamz/aws"
+	"github.com/crowdmob/goamz/cloudwatch"
+)
+
+const (
+	// Credentials are refreshed this long before they expire, so requests in flight don't fail.
+	credentialsRefreshMargin = 5 * time.Minute
+
+	DefaultMetadataEndpoint = "http://169.254.169.254"
+	DefaultSTSEndpoint      = "https://sts.amazonaws.com"
+)
+
+// Credentials are AWS security credentials. Temporary credentials have a session token and expiry time.
+type Credentials struct {
+	AccessKey string
+	SecretKey string
+	Token     string
+	Expires   time.Time // Zero if the credentials don't expire.
+}
+
+// Auth returns the credentials as used by the aws package.
+func (cr Credentials) Auth() aws.Auth {
+	return *aws.NewAuth(cr.AccessKey, cr.SecretKey, cr.Token, cr.Expires)
+}
+
+// expiring reports whether the credentials should be refreshed at now.
+func (cr Credentials) expiring(now time.Time) bool {
+	return !cr.Expires.IsZero() && now.Add(credentialsRefreshMargin).After(cr.Expires)
+}
+
+// CredentialsProvider retrieves credentials. The client calls Retrieve again before the credentials expire.
+type CredentialsProvider interface {
+	Retrieve() (Credentials, error)
+}
+
+// StaticProvider always returns the same credentials.
+type StaticProvider Credentials
+
+func (p StaticProvider) Retrieve() (Credentials, error) {
+	return Credentials(p), nil
+}
+
+// EnvProvider reads credentials from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN. AWS_ACCESS_KEY
+// and AWS_SECRET_KEY are used if the former aren't set.
+type EnvProvider struct{}
+
+func (EnvProvider) Retrieve() (Credentials, error) {
+	cr := Credentials{
+		AccessKey: firstEnv("AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY"),
+		SecretKey: firstEnv("AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY"),
+		Token:     os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if len(cr.AccessKey) == 0 || len(cr.SecretKey) == 0 {
+		return cr, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set")
+	}
+	return cr, nil
+}
+
+// SharedFileProvider reads credentials from a profile of the shared credentials file.
+type SharedFileProvider struct {
+	Filename string // Defaults to AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials.
+	Profile  string // Defaults to AWS_PROFILE or "default".
+}
+
+func (p SharedFileProvider) Retrieve() (Credentials, error) {
+	filename, profile := p.Filename, p.Profile
+	if len(filename) == 0 {
+		filename = os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	}
+	if len(filename) == 0 {
+		filename = filepath.Join(os.Getenv("HOME"), ".aws", "credentials")
+	}
+	if len(profile) == 0 {
+		profile = firstEnv("AWS_PROFILE", "AWS_DEFAULT_PROFILE")
+	}
+	if len(profile) == 0 {
+		profile = "default"
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return Credentials{}, err
+	}
+	defer f.Close()
+	cr, section, found := Credentials{}, "", false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' || line[0] == ';' {
+			continue
+		} else if line[0] == '[' && line[len(line)-1] == ']' {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			found = found || section == profile
+			continue
+		} else if section != profile {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			continue
+		}
+		switch key, val := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]); key {
+		case "aws_access_key_id":
+			cr.AccessKey = val
+		case "aws_secret_access_key":
+			cr.SecretKey = val
+		case "aws_session_token":
+			cr.Token = val
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return cr, err
+	} else if !found {
+		return cr, fmt.Errorf("Profile %q not found in %s", profile, filename)
+	} else if len(cr.AccessKey) == 0 || len(cr.SecretKey) == 0 {
+		return cr, fmt.Errorf("Profile %q in %s has no access key", profile, filename)
+	}
+	return cr, nil
+}
+
+// MetadataProvider retrieves the credentials of the IAM role of an EC2 instance from the instance metadata service.
+type MetadataProvider struct {
+	Endpoint string // Defaults to DefaultMetadataEndpoint, can point to a local server in tests.
+	Role     string // Defaults to the role attached to the instance.
+	Client   *http.Client
+}
+
+func (p MetadataProvider) Retrieve() (Credentials, error) {
+	endpoint, client := p.Endpoint, p.Client
+	if len(endpoint) == 0 {
+		endpoint = DefaultMetadataEndpoint
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	// Session tokens are required by IMDSv2. If they aren't supported, fall back to IMDSv1.
+	token := ""
+	if req, err := http.NewRequest("PUT", endpoint+"/latest/api/token", nil); err == nil {
+		req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+		if res, err := client.Do(req); err == nil {
+			if b, err := ioutil.ReadAll(res.Body); err == nil && res.StatusCode == http.StatusOK {
+				token = string(b)
+			}
+			res.Body.Close()
+		}
+	}
+	get := func(path string) ([]byte, error) {
+		req, err := http.NewRequest("GET", endpoint+"/latest/meta-data/iam/security-credentials/"+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(token) > 0 {
+			req.Header.Set("X-aws-ec2-metadata-token", token)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		b, err := ioutil.ReadAll(res.Body)
+		if err == nil && res.StatusCode != http.StatusOK {
+			err = fmt.Errorf("Instance metadata returned status code %d: %s", res.StatusCode, string(b))
+		}
+		return b, err
+	}
+	role := p.Role
+	if len(role) == 0 {
+		b, err := get("")
+		if err != nil {
+			return Credentials{}, err
+		}
+		role = strings.TrimSpace(strings.SplitN(string(b), "\n", 2)[0])
+		if len(role) == 0 {
+			return Credentials{}, errors.New("No IAM role attached to the instance")
+		}
+	}
+	b, err := get(role)
+	if err != nil {
+		return Credentials{}, err
+	}
+	res := struct {
+		Code            string
+		AccessKeyId     string
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}{}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return Credentials{}, err
+	} else if len(res.Code) > 0 && res.Code != "Success" {
+		return Credentials{}, fmt.Errorf("Could not get credentials of role %q: %s", role, res.Code)
+	}
+	return Credentials{AccessKey: res.AccessKeyId, SecretKey: res.SecretAccessKey, Token: res.Token, Expires: res.Expiration}, nil
+}
+
+// AssumeRoleProvider retrieves temporary credentials for a role with STS AssumeRole, signing the request with the
+// credentials of Source.
+type AssumeRoleProvider struct {
+	Source      CredentialsProvider
+	RoleARN     string
+	SessionName string        // Defaults to "dynamo".
+	ExternalID  string        // Only if the role requires it.
+	Duration    time.Duration // Defaults to an hour.
+	Region      aws.Region    // Used for signing, defaults to us-east-1.
+	Endpoint    string        // Defaults to DefaultSTSEndpoint.
+	Client      *http.Client
+}
+
+func (p AssumeRoleProvider) Retrieve() (Credentials, error) {
+	if p.Source == nil || len(p.RoleARN) == 0 {
+		return Credentials{}, errors.New("AssumeRoleProvider needs a source provider and role ARN")
+	}
+	source, err := p.Source.Retrieve()
+	if err != nil {
+		return Credentials{}, err
+	}
+	params := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"RoleArn":         {p.RoleARN},
+		"RoleSessionName": {p.SessionName},
+		"DurationSeconds": {strconv.Itoa(int(p.Duration.Seconds()))},
+	}
+	if len(p.SessionName) == 0 {
+		params.Set("RoleSessionName", "dynamo")
+	}
+	if p.Duration == 0 {
+		params.Set("DurationSeconds", "3600")
+	}
+	if len(p.ExternalID) > 0 {
+		params.Set("ExternalId", p.ExternalID)
+	}
+	endpoint, region, client := p.Endpoint, p.Region, p.Client
+	if len(endpoint) == 0 {
+		endpoint = DefaultSTSEndpoint
+	}
+	if len(region.Name) == 0 {
+		region = aws.USEast
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	body := params.Encode()
+	req, err := http.NewRequest("POST", endpoint+"/", strings.NewReader(body))
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	req.Header.Set("X-Amz-Date", time.Now().UTC().Format(aws.ISO8601BasicFormat))
+	if len(source.Token) > 0 {
+		req.Header.Set("X-Amz-Security-Token", source.Token)
+	}
+	aws.NewV4Signer(source.Auth(), "sts", region).Sign(req)
+	res, err := client.Do(req)
+	if err != nil {
+		return Credentials{}, err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return Credentials{}, err
+	} else if res.StatusCode != http.StatusOK {
+		return Credentials{}, fmt.Errorf("AssumeRole returned status code %d: %s", res.StatusCode, string(b))
+	}
+	result := struct {
+		AccessKeyId     string    `xml:"AssumeRoleResult>Credentials>AccessKeyId"`
+		SecretAccessKey string    `xml:"AssumeRoleResult>Credentials>SecretAccessKey"`
+		SessionToken    string    `xml:"AssumeRoleResult>Credentials>SessionToken"`
+		Expiration      time.Time `xml:"AssumeRoleResult>Credentials>Expiration"`
+	}{}
+	if err := xml.Unmarshal(b, &result); err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{
+		AccessKey: result.AccessKeyId,
+		SecretKey: result.SecretAccessKey,
+		Token:     result.SessionToken,
+		Expires:   result.Expiration,
+	}, nil
+}
+
+// ChainProvider returns the credentials of the first provider that has them.
+type ChainProvider []CredentialsProvider
+
+func (p ChainProvider) Retrieve() (Credentials, error) {
+	errs := []string{}
+	for _, provider := range p {
+		cr, err := provider.Retrieve()
+		if err == nil {
+			return cr, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return Credentials{}, errors.New("No credentials found: " + strings.Join(errs, "; "))
+}
+
+// DefaultProvider looks for credentials in the environment, then the shared credentials file, then the instance
+// metadata service.
+func DefaultProvider() CredentialsProvider {
+	return ChainProvider{EnvProvider{}, SharedFileProvider{}, MetadataProvider{}}
+}
+
+// NewClientWithCredentials returns a client that gets its credentials from p, and retrieves them again before they
+// expire.
+func NewClientWithCredentials(p CredentialsProvider, region aws.Region) (*Client, error) {
+	cr, err := p.Retrieve()
+	if err != nil {
+		return nil, err
+	}
+	c := NewClient(cr.Auth(), region)
+	c.creds = &credentialsCache{provider: p, current: cr, signer: c.signer, cw: c.cw}
+	return c, nil
+}
+
+// credentialsCache holds the credentials of a provider until they expire, with the signer and CloudWatch client using
+// them. It's shared by the copies of a client, so whichever copy refreshes the credentials, they all use the new ones.
+type credentialsCache struct {
+	mu       sync.Mutex
+	provider CredentialsProvider
+	current  Credentials
+	signer   *aws.V4Signer
+	cw       *cloudwatch.CloudWatch
+}
+
+// sign signs r with current credentials, refreshing them first if they are about to expire.
+func (c *Client) sign(r *http.Request) error {
+	if c.creds == nil {
+		c.signer.Sign(r)
+		return nil
+	}
+	signer, token, err := c.refreshCredentials()
+	if err != nil {
+		return err
+	}
+	if len(token) > 0 {
+		r.Header.Set("X-Amz-Security-Token", token)
+	}
+	signer.Sign(r)
+	return nil
+}
+
+// refreshCredentials returns the signer of the current credentials and their session token, retrieving new ones if
+// they're about to expire.
+func (c *Client) refreshCredentials() (*aws.V4Signer, string, error) {
+	c.creds.mu.Lock()
+	defer c.creds.mu.Unlock()
+	if c.creds.current.expiring(time.Now()) {
+		cr, err := c.creds.provider.Retrieve()
+		if err != nil {
+			// Keep using the old credentials while they're still valid.
+			if time.Now().Before(c.creds.current.Expires) {
+				return c.creds.signer, c.creds.current.Token, nil
+			}
+			return nil, "", fmt.Errorf("Could not refresh credentials: %s", err.Error())
+		}
+		cw, err := cloudwatch.NewCloudWatch(cr.Auth(), c.Region.CloudWatchServicepoint)
+		if err != nil {
+			return nil, "", err
+		}
+		c.creds.current = cr
+		c.creds.signer = aws.NewV4Signer(cr.Auth(), "dynamodb", c.Region)
+		c.creds.cw = cw
+	}
+	return c.creds.signer, c.creds.current.Token, nil
+}
+
+// cloudWatch returns the CloudWatch client for the current credentials.
+func (c *Client) cloudWatch() (*cloudwatch.CloudWatch, error) {
+	if c.creds == nil {
+		return c.cw, nil
+	}
+	if _, _, err := c.refreshCredentials(); err != nil {
+		return nil, err
+	}
+	c.creds.mu.Lock()
+	defer c.creds.mu.Unlock()
+	return c.creds.cw, nil
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); len(v) > 0 {
+			return v
+		}
+	}
+	return ""
+}
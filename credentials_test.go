@@ -0,0 +1,157 @@
+package dynamo
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crowdmob/goamz/aws"
+)
+
+func TestSharedFileProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dynamo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "credentials")
+	ioutil.WriteFile(filename, []byte(`
+[default]
+aws_access_key_id = default-key
+aws_secret_access_key = default-secret
+
+# Jobs profile.
+[jobs]
+aws_access_key_id=jobs-key
+aws_secret_access_key=jobs-secret
+aws_session_token=jobs-token
+`), 0600)
+	cr, err := SharedFileProvider{Filename: filename, Profile: "jobs"}.Retrieve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cr.AccessKey != "jobs-key" || cr.SecretKey != "jobs-secret" || cr.Token != "jobs-token" {
+		t.Errorf("Unexpected credentials %+v", cr)
+	}
+	if _, err := (SharedFileProvider{Filename: filename, Profile: "missing"}).Retrieve(); err == nil {
+		t.Error("Expected error for missing profile")
+	}
+}
+
+func TestMetadataProvider(t *testing.T) {
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/api/token":
+			w.Write([]byte("session"))
+		case "/latest/meta-data/iam/security-credentials/":
+			w.Write([]byte("worker\n"))
+		case "/latest/meta-data/iam/security-credentials/worker":
+			if r.Header.Get("X-aws-ec2-metadata-token") != "session" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"Code":"Success","AccessKeyId":"key","SecretAccessKey":"secret","Token":"token","Expiration":"` + expires.Format(time.RFC3339) + `"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+	cr, err := MetadataProvider{Endpoint: s.URL}.Retrieve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cr.AccessKey != "key" || cr.Token != "token" || !cr.Expires.Equal(expires) {
+		t.Errorf("Unexpected credentials %+v", cr)
+	}
+}
+
+type countingProvider struct {
+	calls   int
+	expires time.Duration
+}
+
+func (p *countingProvider) Retrieve() (Credentials, error) {
+	p.calls++
+	return Credentials{AccessKey: "key", SecretKey: "secret", Token: "token", Expires: time.Now().Add(p.expires)}, nil
+}
+
+func TestCredentialsRefresh(t *testing.T) {
+	var token string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("X-Amz-Security-Token")
+		w.Write([]byte(`{}`))
+	}))
+	defer s.Close()
+	region := aws.USEast
+	region.DynamoDBEndpoint = s.URL
+
+	// Credentials expiring within the refresh margin are retrieved again on every request.
+	p := &countingProvider{expires: time.Minute}
+	c, err := NewClientWithCredentials(p, region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.PutItem("things", keyDoc{"a"})
+	c.PutItem("things", keyDoc{"a"})
+	if p.calls != 3 || token != "token" {
+		t.Errorf("Expected 3 retrievals and the session token to be sent, got %d and %q", p.calls, token)
+	}
+
+	p = &countingProvider{expires: time.Hour}
+	c, _ = NewClientWithCredentials(p, region)
+	c.PutItem("things", keyDoc{"a"})
+	if p.calls != 1 {
+		t.Errorf("Expected credentials to be reused, retrieved %d times", p.calls)
+	}
+}
+
+// rotatingProvider hands out numbered credentials, the first of which are about to expire.
+type rotatingProvider struct {
+	calls int
+}
+
+func (p *rotatingProvider) Retrieve() (Credentials, error) {
+	p.calls++
+	expires := time.Now().Add(time.Hour)
+	if p.calls == 1 {
+		expires = time.Now().Add(time.Minute)
+	}
+	n := strconv.Itoa(p.calls)
+	return Credentials{AccessKey: "key" + n, SecretKey: "secret" + n, Token: "tok" + n, Expires: expires}, nil
+}
+
+func TestCredentialsRefreshCopies(t *testing.T) {
+	var auth, token string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, token = r.Header.Get("Authorization"), r.Header.Get("X-Amz-Security-Token")
+		w.Write([]byte(`{}`))
+	}))
+	defer s.Close()
+	region := aws.USEast
+	region.DynamoDBEndpoint = s.URL
+	c, err := NewClientWithCredentials(&rotatingProvider{}, region)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The copy refreshes the expiring credentials, which the original must use from then on.
+	cc := *c
+	cc.PutItem("things", keyDoc{"a"})
+	if !strings.Contains(auth, "Credential=key2") || token != "tok2" {
+		t.Fatalf("Copy signed with %q and %q", auth, token)
+	}
+	c.PutItem("things", keyDoc{"a"})
+	if !strings.Contains(auth, "Credential=key2") || token != "tok2" {
+		t.Errorf("Original signed with %q and %q", auth, token)
+	}
+	if cw, err := c.cloudWatch(); err != nil || cw.Service == nil || cw != cc.creds.cw {
+		t.Errorf("Original uses CloudWatch client %v, %v", cw, err)
+	}
+}
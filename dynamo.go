@@ -1,7 +1,6 @@
 package dynamo
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,7 +12,6 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/crowdmob/goamz/aws"
@@ -47,9 +45,29 @@ const (
 	BatchWriteItemEndpoint = "BatchWriteItem"
 	QueryEndpoint          = "Query"
 	ScanEndpoint           = "Scan"
-
-	IllegalChars        = "$%^" // TODO(joy): Find out what is legal for table names and attributes.
+	TransactWriteEndpoint  = "TransactWriteItems"
+	TransactGetEndpoint    = "TransactGetItems"
+
+	ExecuteStatementEndpoint      = "ExecuteStatement"
+	BatchExecuteStatementEndpoint = "BatchExecuteStatement"
+	ExecuteTransactionEndpoint    = "ExecuteTransaction"
+
+	UpdateTimeToLiveEndpoint   = "UpdateTimeToLive"
+	DescribeTimeToLiveEndpoint = "DescribeTimeToLive"
+
+	CreateBackupEndpoint              = "CreateBackup"
+	ListBackupsEndpoint               = "ListBackups"
+	DescribeBackupEndpoint            = "DescribeBackup"
+	DeleteBackupEndpoint              = "DeleteBackup"
+	RestoreTableFromBackupEndpoint    = "RestoreTableFromBackup"
+	DescribeContinuousBackupsEndpoint = "DescribeContinuousBackups"
+	UpdateContinuousBackupsEndpoint   = "UpdateContinuousBackups"
+	RestoreTableToPointInTimeEndpoint = "RestoreTableToPointInTime"
+
+	IllegalChars        = "$%^" // Deprecated: table and index names are checked by ValidateTableName.
 	omitEmptyTag        = "omitempty"
+	versionTag          = "version"
+	ttlTag              = "ttl"
 	ignoreTag           = "-"
 	numDigitsPrecision  = 38
 	minTableLength      = 3
@@ -57,7 +75,8 @@ const (
 	BatchWriteSizeLimit = 1000000
 	BatchWriteItemLimit = 25
 	BatchGetItemLimit   = 100
-	ItemSizeLimit       = 64000
+	ItemSizeLimit       = 400 * 1024 // Including attribute names, see ItemSize.
+	TransactItemLimit   = 100
 )
 
 var NumberRegex *regexp.Regexp
@@ -69,14 +88,25 @@ type Client struct {
 	Auth     aws.Auth
 	Region   aws.Region
 	Endpoint string
+	limiter  *RateLimiter
+
+	gzip          bool
+	capacityLevel string
+	onCapacity    CapacityFunc
+	middleware    []Middleware
+	tracer        Tracer
+	meter         Meter
+	creds         *credentialsCache // Only set when credentials come from a CredentialsProvider.
 }
 
 type Request struct {
-	req *http.Request
+	req      *http.Request
+	body     []byte
+	attempts int
 }
 
 func init() {
-	NumberRegex = regexp.MustCompile(`[0-9]+(\.[0-9]+(E?[-+]?[0-9]+)?)?`)
+	NumberRegex = regexp.MustCompile(`^-?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?$`)
 }
 
 func NewClient(auth aws.Auth, region aws.Region) *Client {
@@ -97,14 +127,34 @@ func NewClient(auth aws.Auth, region aws.Region) *Client {
 //  Test wheather you can set the content afterwards (you can set Content lenght, but not sure if the content is used in the signing)
 
 func (c *Client) NewRequest(endpoint string) (*Request, error) {
-	req, err := http.NewRequest("POST", c.Region.DynamoDBEndpoint, nil)
+	return c.newRequest(c.Region.DynamoDBEndpoint, DynamoBaseEndpoint+endpoint)
+}
+
+// NewTargetRequest returns a request of another API signed for DynamoDB, such as DynamoDB Streams: url is the
+// endpoint of the API and target the X-Amz-Target of the operation, i.e. "DynamoDBStreams_20120810.GetRecords".
+func (c *Client) NewTargetRequest(url, target string, data interface{}) (*Request, error) {
+	req, err := c.newRequest(url, target)
+	if err != nil {
+		return nil, err
+	}
+	return req, req.SetContent(data)
+}
+
+func (c *Client) newRequest(url, target string) (*Request, error) {
+	req, err := http.NewRequest("POST", url, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
 	req.Header.Set("X-Amz-Date", time.Now().UTC().Format(aws.ISO8601BasicFormat))
-	req.Header.Set("X-Amz-Target", DynamoBaseEndpoint+endpoint)
-	return &Request{req}, nil
+	req.Header.Set("X-Amz-Target", target)
+	// Asking for identity stops net/http from decompressing responses itself, which would break their checksums.
+	if c.gzip {
+		req.Header.Set("Accept-Encoding", "gzip")
+	} else {
+		req.Header.Set("Accept-Encoding", "identity")
+	}
+	return &Request{req: req}, nil
 }
 
 func (c *Client) NewRequestWithContent(endpoint string, data interface{}) (*Request, error) {
@@ -115,28 +165,55 @@ func (c *Client) NewRequestWithContent(endpoint string, data interface{}) (*Requ
 	return req, req.SetContent(data)
 }
 
+// Do sends the request through the middleware added with Use, then signs and sends it to DynamoDB.
 func (c *Client) Do(r *Request) (*http.Response, error) {
-	c.signer.Sign(r.req)
+	h := Handler(c.send)
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		h = c.middleware[i](h)
+	}
+	return h(r)
+}
+
+func (c *Client) send(r *Request) (*http.Response, error) {
+	r.attempts++
+	r.resetBody()
+	if err := c.sign(r.req); err != nil {
+		return nil, err
+	}
 	res, err := c.c.Do(r.req)
 	if err != nil {
 		return res, err
+	} else if err := verifyResponse(res); err != nil {
+		return res, err
 	} else if res.StatusCode != http.StatusOK {
-		if b, err := ioutil.ReadAll(res.Body); err != nil {
+		b, err := ioutil.ReadAll(res.Body)
+		if _, ok := err.(*ChecksumError); ok {
+			return res, err
+		} else if err != nil {
 			return res, fmt.Errorf("Could not read response, status code was %d: %s", res.StatusCode, err.Error())
-		} else {
+		}
+		e := &Error{StatusCode: res.StatusCode}
+		if err := json.Unmarshal(b, e); err != nil || len(e.Type) == 0 {
 			return res, fmt.Errorf("Status code %d returned: %s", res.StatusCode, string(b))
 		}
+		return res, e
 	}
 	return res, nil
 }
 
 func (c *Client) RawQuery(q Query) ([]AttributeSet, AttributeSet, error) {
+	if len(q.ReturnConsumedCapacity) == 0 {
+		q.ReturnConsumedCapacity = c.returnConsumed()
+	}
 	res := QueryResponse{}
 	err := c.makeRequest(QueryEndpoint, q, &res)
 	return res.Items, res.LastEvaluatedKey, err
 }
 
 func (c *Client) RawScan(s ScanRequest) ([]AttributeSet, AttributeSet, error) {
+	if len(s.ReturnConsumedCapacity) == 0 {
+		s.ReturnConsumedCapacity = c.returnConsumed()
+	}
 	res := QueryResponse{}
 	err := c.makeRequest(ScanEndpoint, s, &res)
 	return res.Items, res.LastEvaluatedKey, err
@@ -158,10 +235,14 @@ func (c *Client) BatchWrite(table string, items interface{}) (BatchResponse, err
 		attr, err := MarshalAttributes(item)
 		if err != nil {
 			return res, err
+		} else if err := checkItemSize(attr); err != nil {
+			err.(*ItemTooLargeError).Index = i
+			return res, err
 		}
 		reqItems[i].PutRequest = &PutRequest{Item: attr}
 	}
 	req.RequestItems = map[string][]RequestItem{table: reqItems}
+	req.ReturnConsumedCapacity = c.returnConsumed()
 	return res, c.makeRequest(BatchWriteItemEndpoint, req, &res)
 }
 
@@ -185,17 +266,17 @@ func (c *Client) BatchDelete(table string, items interface{}) (BatchResponse, er
 		reqItems[i].DeleteRequest = &DeleteRequest{Key: attr}
 	}
 	req.RequestItems = map[string][]RequestItem{table: reqItems}
+	req.ReturnConsumedCapacity = c.returnConsumed()
 	return res, c.makeRequest(BatchWriteItemEndpoint, req, &res)
 }
 
 func (r *Request) SetContentBytes(data []byte) {
-	r.req.Body = ioutil.NopCloser(bytes.NewBuffer(data))
-	r.req.ContentLength = int64(len(data))
+	r.body = data
+	r.resetBody()
 }
 
 func (r *Request) SetContentString(data string) {
-	r.req.Body = ioutil.NopCloser(bytes.NewBufferString(data))
-	r.req.ContentLength = int64(len(data))
+	r.SetContentBytes([]byte(data))
 }
 
 func (r *Request) SetContent(data interface{}) error {
@@ -206,26 +287,33 @@ func (r *Request) SetContent(data interface{}) error {
 	if err != nil {
 		return err
 	}
-	r.req.Body = ioutil.NopCloser(bytes.NewBuffer(b))
-	r.req.ContentLength = int64(len(b))
-	return err
+	r.SetContentBytes(b)
+	return nil
 }
 
+// PutItem stores doc in table. If doc has a field tagged `dynamo:",version"` the write only succeeds if the
+// stored version matches, and the field is incremented on success; otherwise ErrVersionConflict is returned.
 func (c *Client) PutItem(table string, doc interface{}) error {
 	item, err := MarshalAttributes(doc)
 	if err != nil {
 		return err
 	}
+	ver, err := getVersion(doc)
+	if err != nil {
+		return err
+	}
 	data := PutRequest{
-		BasicRequest: BasicRequest{TableName: table},
+		BasicRequest: BasicRequest{TableName: table, ReturnConsumedCapacity: c.returnConsumed()},
 		Item:         item,
 	}
-	r, err := c.NewRequestWithContent(PutItemEndpoint, data)
-	if err != nil {
+	if ver != nil {
+		data.Expected = ver.expected()
+		item[ver.name] = ver.next()
+	}
+	if err := checkItemSize(item); err != nil {
 		return err
 	}
-	_, err = c.Do(r)
-	return err
+	return ver.result(c.makeRequest(PutItemEndpoint, data, &UpdateResponse{}))
 }
 
 func (c *Client) UpdateItem(table string, matchDoc interface{}, updates interface{}, updateType string) error {
@@ -240,6 +328,10 @@ func (c *Client) UpdateItem(table string, matchDoc interface{}, updates interfac
 	if err != nil {
 		return err
 	}
+	ver, err := getVersion(updates)
+	if err != nil {
+		return err
+	}
 	updateAttr := map[string]AttributeUpdate{}
 	for a, val := range attr {
 		if _, ok := key[a]; !ok {
@@ -247,11 +339,16 @@ func (c *Client) UpdateItem(table string, matchDoc interface{}, updates interfac
 		}
 	}
 	req := Update{
-		TableName:        table,
-		Key:              key,
-		AttributeUpdates: updateAttr,
+		TableName:              table,
+		Key:                    key,
+		AttributeUpdates:       updateAttr,
+		ReturnConsumedCapacity: c.returnConsumed(),
 	}
-	return c.makeRequest(UpdateItemEndpoint, req, &UpdateResponse{})
+	if ver != nil {
+		req.Expected = ver.expected()
+		req.AttributeUpdates[ver.name] = AttributeUpdate{Value: ver.next(), Action: UpdateTypePut}
+	}
+	return ver.result(c.makeRequest(UpdateItemEndpoint, req, &UpdateResponse{}))
 }
 
 func (c *Client) UpdateItemRaw(table string, key AttributeSet, updates AttributeSet, updateType string) error {
@@ -260,19 +357,54 @@ func (c *Client) UpdateItemRaw(table string, key AttributeSet, updates Attribute
 		updateAttr[a] = AttributeUpdate{Value: val, Action: updateType}
 	}
 	req := Update{
-		TableName:        table,
-		Key:              key,
-		AttributeUpdates: updateAttr,
+		TableName:              table,
+		Key:                    key,
+		AttributeUpdates:       updateAttr,
+		ReturnConsumedCapacity: c.returnConsumed(),
 	}
 	return c.makeRequest(UpdateItemEndpoint, req, &UpdateResponse{})
 }
 
+// GetItemRaw returns the item with key, or nil if there is none.
+func (c *Client) GetItemRaw(table string, key AttributeSet, consistent bool) (AttributeSet, error) {
+	req := GetRequest{
+		TableName:              table,
+		Key:                    key,
+		ConsistentRead:         consistent,
+		ReturnConsumedCapacity: c.returnConsumed(),
+	}
+	res := GetResponse{}
+	err := c.makeRequest(GetItemEndpoint, req, &res)
+	return res.Item, err
+}
+
+// PutItemRaw creates or replaces item.
+func (c *Client) PutItemRaw(table string, item AttributeSet) error {
+	if err := checkItemSize(item); err != nil {
+		return err
+	}
+	data := PutRequest{
+		BasicRequest: BasicRequest{TableName: table, ReturnConsumedCapacity: c.returnConsumed()},
+		Item:         item,
+	}
+	return c.makeRequest(PutItemEndpoint, data, &UpdateResponse{})
+}
+
+// DeleteItemRaw deletes the item with key.
+func (c *Client) DeleteItemRaw(table string, key AttributeSet) error {
+	req := DeleteItemRequest{
+		BasicRequest: BasicRequest{TableName: table, ReturnConsumedCapacity: c.returnConsumed()},
+		Key:          key,
+	}
+	return c.makeRequest(DeleteItemEndpoint, req, &UpdateResponse{})
+}
+
 func (c *Client) CreateTableSimple(name, hashKeyName, hashKeyType, rangeKeyName, rangeKeyType string, read, write int) (TableDescription, error) {
 	res := TableDescriptionWrapper{}
 	if read == 0 || write == 0 {
 		return res.Description, errors.New("Read/Write throughput may not be 0")
-	} else if len(name) < 3 {
-		return res.Description, errors.New("Table name must be at least 3 characters")
+	} else if err := ValidateTableName(name); err != nil {
+		return res.Description, err
 	} else if !isValidType(hashKeyType) {
 		return res.Description, fmt.Errorf("%q is invalid attribute type", hashKeyType)
 	} else if len(rangeKeyName) > 0 && !isValidType(rangeKeyType) {
@@ -299,28 +431,62 @@ func (c *Client) DeleteTable(name string) (TableDescription, error) {
 
 func (c *Client) BatchGetRaw(table string, keys []AttributeSet, filter []string) ([]AttributeSet, []RequestItem, error) {
 	req := BatchGetRequest{
-		RequestItems: map[string]RequestItem{table: RequestItem{AttributesToGet: filter, Keys: keys}},
+		RequestItems:           map[string]RequestItem{table: RequestItem{AttributesToGet: filter, Keys: keys}},
+		ReturnConsumedCapacity: c.returnConsumed(),
 	}
 	res := BatchResponse{}
-	return res.Responses[table], res.UnprocessedItems[table], c.makeRequest(BatchGetItemEndpoint, req, &res)
+	err := c.makeRequest(BatchGetItemEndpoint, req, &res)
+	return res.Responses[table], res.UnprocessedItems[table], err
 }
 
+// DoAndUnmarshal sends the request and decodes the response into dst, sending it again if the response was corrupted.
 func (c *Client) DoAndUnmarshal(r *Request, dst interface{}) error {
-	res, err := c.Do(r)
-	if err != nil {
-		return err
+	for attempt := 1; ; attempt++ {
+		res, err := c.Do(r)
+		if err == nil {
+			err = unmarshalResponse(res.Body, dst)
+			res.Body.Close()
+		}
+		if _, ok := err.(*ChecksumError); !ok || attempt == maxChecksumAttempts {
+			return err
+		} else if s, ok := dst.(*itemStream); ok && s.items > 0 {
+			return err
+		}
 	}
-	return unmarshalResponse(res.Body, dst)
 }
 
 func (c *Client) makeRequest(endpoint string, data, dst interface{}) error {
+	if v, ok := data.(validator); ok {
+		if err := v.validate().err(); err != nil {
+			return err
+		}
+	}
+	if c.limiter != nil {
+		return c.limiter.request(endpoint, data, dst, c.doRequest)
+	}
+	return c.doRequest(endpoint, data, dst)
+}
+
+func (c *Client) doRequest(endpoint string, data, dst interface{}) error {
 	req, err := c.NewRequestWithContent(endpoint, data)
 	if err != nil {
 		return err
-	} else if dst != nil {
-		return c.DoAndUnmarshal(req, dst)
 	}
-	_, err = c.Do(req)
+	var obs *observation
+	if c.tracer != nil || c.meter != nil {
+		obs = c.observe(endpoint, data, req)
+	}
+	if dst == nil {
+		// The response is still read, so its checksum is verified and its body closed.
+		dst = &struct{}{}
+	}
+	err = c.DoAndUnmarshal(req, dst)
+	if cr, ok := dst.(capacityResponse); ok && err == nil && c.onCapacity != nil {
+		if consumed := cr.consumed(); len(consumed) > 0 {
+			c.onCapacity(endpoint, consumed)
+		}
+	}
+	obs.end(dst, err)
 	return err
 }
 
@@ -332,6 +498,24 @@ func (c *Client) ChangeThroughput(table string, read, write int) error {
 	return c.makeRequest(UpdateTableEndpoint, req, nil)
 }
 
+// EnableStream makes a table record its changes in a stream, whose records have the view viewType of the items
+// changed, i.e. StreamViewNewAndOldImages. See the streams package to read it.
+func (c *Client) EnableStream(table, viewType string) error {
+	req := updateStreamRequest{TableName: table, StreamSpecification: StreamSpecification{StreamEnabled: true, StreamViewType: viewType}}
+	return c.makeRequest(UpdateTableEndpoint, req, &TableDescriptionWrapper{})
+}
+
+// DisableStream stops a table from recording its changes. Its stream stays readable for 24 hours.
+func (c *Client) DisableStream(table string) error {
+	req := updateStreamRequest{TableName: table}
+	return c.makeRequest(UpdateTableEndpoint, req, &TableDescriptionWrapper{})
+}
+
+type updateStreamRequest struct {
+	TableName           string
+	StreamSpecification StreamSpecification
+}
+
 // ListTables returns a limit of 100 tables.
 func (c *Client) ListTables(start string, limit int) ([]string, string, error) {
 	req := ListTablesRequest{
@@ -339,59 +523,43 @@ func (c *Client) ListTables(start string, limit int) ([]string, string, error) {
 		Limit: limit,
 	}
 	res := ListTablesResponse{}
-	return res.TableNames, res.LastEvaluatedTableName, c.makeRequest(ListTablesEndpoint, req, &res)
+	err := c.makeRequest(ListTablesEndpoint, req, &res)
+	return res.TableNames, res.LastEvaluatedTableName, err
 }
 
+// AddAlarms alarms when the table consumes more than the given read and write units per second, notifying the
+// default SNS topic.
+// The alarms are named <table>-ReadAlarm and <table>-WriteAlarm, so ListAlarms doesn't return them, but
+// DeleteTableAlarms deletes them.
+// Deprecated: Use PutAlarms, which takes the SNS topic, period and statistic and also covers indexes and errors.
 func (c *Client) AddAlarms(table string, readThreshold, writeThreshold float64) error {
 	if readThreshold <= 0 && writeThreshold <= 0 {
 		return errors.New("Invalid threshold values, at least one should be greater than 0")
 	}
-	wg := sync.WaitGroup{}
-	var readAlarmErr, writeAlarmErr error
+	// Take the average every 5 minutes 4 times (for 20 minutes).
+	spec := AlarmSpec{
+		Actions:           []string{"arn:aws:sns:us-east-1:436204345238:dynamodb"},
+		Period:            60 * 5,
+		EvaluationPeriods: 4,
+	}
+	alarms := []Alarm{}
 	if readThreshold > 0 {
-		wg.Add(1)
-		readAlarm := cloudwatch.MetricAlarm{
-			AlarmName:          table + "-ReadAlarm",
-			ComparisonOperator: "GreaterThanThreshold",
-			Namespace:          "AWS/DynamoDB",
-			Statistic:          "Sum",
-			AlarmActions:       []cloudwatch.AlarmAction{{ARN: "arn:aws:sns:us-east-1:436204345238:dynamodb"}},
-			Period:             60 * 5, // Take the average every 5 minutes 4 times (for 20 minutes).
-			EvaluationPeriods:  4,
-			MetricName:         "ConsumedReadCapacityUnits",
-			Threshold:          readThreshold * 60 * 5 * 4,
-			Dimensions:         []cloudwatch.Dimension{{Name: "TableName", Value: table}},
-		}
-		go func() {
-			_, readAlarmErr = c.cw.PutMetricAlarm(&readAlarm)
-			wg.Done()
-		}()
+		alarms = append(alarms, Alarm{
+			Name:      table + "-ReadAlarm",
+			Table:     table,
+			Metric:    MetricConsumedReadCapacity,
+			Threshold: readThreshold * 60 * 5 * 4,
+		})
 	}
 	if writeThreshold > 0 {
-		wg.Add(1)
-		writeAlarm := cloudwatch.MetricAlarm{
-			AlarmName:          table + "-WriteAlarm",
-			ComparisonOperator: "GreaterThanThreshold",
-			Namespace:          "AWS/DynamoDB",
-			Statistic:          "Sum",
-			AlarmActions:       []cloudwatch.AlarmAction{{ARN: "arn:aws:sns:us-east-1:436204345238:dynamodb"}},
-			Period:             60 * 5, // Take the average every 5 minutes 4 times (for 20 minutes).
-			EvaluationPeriods:  4,
-			MetricName:         "ConsumedWriteCapacityUnits",
-			Threshold:          writeThreshold * 60 * 5 * 4,
-			Dimensions:         []cloudwatch.Dimension{{Name: "TableName", Value: table}},
-		}
-
-		go func() {
-			_, writeAlarmErr = c.cw.PutMetricAlarm(&writeAlarm)
-			wg.Done()
-		}()
+		alarms = append(alarms, Alarm{
+			Name:      table + "-WriteAlarm",
+			Table:     table,
+			Metric:    MetricConsumedWriteCapacity,
+			Threshold: writeThreshold * 60 * 5 * 4,
+		})
 	}
-	wg.Wait()
-	if readAlarmErr != nil {
-		return readAlarmErr
-	}
-	return writeAlarmErr
+	return c.PutAlarms(alarms, spec)
 }
 
 func isValidType(attrType string) bool {
@@ -404,20 +572,14 @@ func isValidType(attrType string) bool {
 
 func (c *Client) DescribeTable(table string) (TableDescription, error) {
 	td := TableDescriptionWrapper{}
-	r, err := c.NewRequest(DescribeTableEndpoint)
-	if err != nil {
-		return td.Table, err
-	}
-	r.SetContent(BasicRequest{TableName: table})
-	res, err := c.Do(r)
-	if err != nil {
-		return td.Table, err
-	}
-	err = unmarshalResponse(res.Body, &td)
+	err := c.makeRequest(DescribeTableEndpoint, BasicRequest{TableName: table}, &td)
 	return td.Table, err
 }
 
 func unmarshalResponse(data io.Reader, dst interface{}) error {
+	if s, ok := dst.(*itemStream); ok {
+		return s.decode(data)
+	}
 	b, err := ioutil.ReadAll(data)
 	if err != nil {
 		return err
@@ -427,6 +589,7 @@ func unmarshalResponse(data io.Reader, dst interface{}) error {
 
 // By default because Dynamo doesn't allow empty attributes, so empty arrays, pointers, string, etc. values are not stored.
 // Thus 'omitempty' (or the lack thereof) is only significant for pointers, maps, and structs.
+// time.Time fields tagged `dynamo:",ttl"` are stored as epoch seconds, the format of TTL attributes, see EnableTTL.
 func MarshalAttributes(i interface{}) (attr AttributeSet, err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -448,29 +611,17 @@ func MarshalAttributes(i interface{}) (attr AttributeSet, err error) {
 	}
 	attr = AttributeSet{}
 	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		name := f.Name
-		tag, forceType, omitempty := f.Tag.Get("dynamo"), "", false
-		if len(tag) > 0 {
-			if tag == ignoreTag {
-				continue
-			}
-			tagParts := strings.Split(tag, ",")
-			if len(tagParts[0]) > 0 {
-				name = tagParts[0]
-			}
-			for j := 1; j < len(tagParts); j++ {
-				switch tagParts[j] {
-				case omitEmptyTag:
-					omitempty = true
-				case TypeNumber, TypeString, TypeBinary, TypeBinarySet, TypeNumberSet, TypeStringSet:
-					forceType = tagParts[j]
-				}
-			}
+		tag := parseFieldTag(t.Field(i))
+		if tag.ignore {
+			continue
 		}
+		name, forceType := tag.name, tag.forceType
 		fv := v.Field(i)
+		if tag.ttl && isTime(fv) {
+			fv = ttlSeconds(fv)
+		}
 		if isEmptyValue(fv) {
-			if !omitempty {
+			if !tag.omitempty {
 				// TODO(joy): If omitempty not specified, the attribute for false boolean and zero numeric values *should* still be set.
 			}
 			continue
@@ -502,6 +653,44 @@ func MarshalAttributes(i interface{}) (attr AttributeSet, err error) {
 	return
 }
 
+type fieldTag struct {
+	name      string
+	forceType string
+	omitempty bool
+	ignore    bool
+	version   bool
+	ttl       bool
+}
+
+// parseFieldTag reads the `dynamo:"name,opts..."` tag of a struct field. The name defaults to the field name.
+func parseFieldTag(f reflect.StructField) fieldTag {
+	ft := fieldTag{name: f.Name}
+	tag := f.Tag.Get("dynamo")
+	if len(tag) == 0 {
+		return ft
+	} else if tag == ignoreTag {
+		ft.ignore = true
+		return ft
+	}
+	tagParts := strings.Split(tag, ",")
+	if len(tagParts[0]) > 0 {
+		ft.name = tagParts[0]
+	}
+	for j := 1; j < len(tagParts); j++ {
+		switch tagParts[j] {
+		case omitEmptyTag:
+			ft.omitempty = true
+		case versionTag:
+			ft.version = true
+		case ttlTag:
+			ft.ttl = true
+		case TypeNumber, TypeString, TypeBinary, TypeBinarySet, TypeNumberSet, TypeStringSet:
+			ft.forceType = tagParts[j]
+		}
+	}
+	return ft
+}
+
 // TODO: Figure out where to use Binary types.
 func getAttribute(v reflect.Value) AttributeVal {
 	switch v.Kind() {
@@ -1,9 +1,86 @@
 package dynamo
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/crowdmob/goamz/aws"
 )
 
+// newTestClient returns a client whose DynamoDB requests are served by handler.
+func newTestClient(handler http.HandlerFunc) (*Client, *httptest.Server) {
+	s := httptest.NewServer(handler)
+	region := aws.USEast
+	region.DynamoDBEndpoint = s.URL
+	return NewClient(aws.Auth{AccessKey: "key", SecretKey: "secret"}, region), s
+}
+
+type versionedDoc struct {
+	Id      string `dynamo:"id"`
+	Name    string `dynamo:"name"`
+	Version int    `dynamo:"version,version"`
+}
+
 func TestPutItem(t *testing.T) {
 
 }
+
+func TestPutItemVersion(t *testing.T) {
+	var got PutRequest
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		got = PutRequest{}
+		json.Unmarshal(b, &got)
+		if got.Item["version"].N == "3" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+	defer s.Close()
+
+	doc := &versionedDoc{Id: "a", Name: "first"}
+	if err := c.PutItem("things", doc); err != nil {
+		t.Fatal(err)
+	}
+	if e := got.Expected["version"]; e.Exists || e.Value != nil {
+		t.Errorf("Expected new item condition, got %+v", e)
+	}
+	if got.Item["version"].N != "1" || doc.Version != 1 {
+		t.Errorf("Expected version 1, stored %q and doc has %d", got.Item["version"].N, doc.Version)
+	}
+
+	if err := c.PutItem("things", doc); err != nil {
+		t.Fatal(err)
+	}
+	if e := got.Expected["version"]; !e.Exists || e.Value == nil || e.Value.N != "1" {
+		t.Errorf("Expected version 1 condition, got %+v", e)
+	}
+
+	if err := c.PutItem("things", doc); err != ErrVersionConflict {
+		t.Errorf("Expected ErrVersionConflict, got %v", err)
+	}
+	if doc.Version != 2 {
+		t.Errorf("Version should not change on conflict, was %d", doc.Version)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ResourceNotFoundException","message":"Requested resource not found"}`))
+	})
+	defer s.Close()
+	_, err := c.DescribeTable("missing")
+	if !IsErrorCode(err, ResourceNotFoundExcpetion) {
+		t.Errorf("Expected ResourceNotFoundException, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "Requested resource not found") {
+		t.Errorf("Error message missing from %q", err.Error())
+	}
+}
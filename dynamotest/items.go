@@ -0,0 +1,476 @@
+package dynamotest
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/poptip/dynamo"
+)
+
+// checkItem checks the values of an item are valid, i.e. not empty.
+func checkItem(item dynamo.AttributeSet) error {
+	for name, val := range item {
+		if !val.IsValid() {
+			return validationError("One or more parameter values were invalid: An AttributeValue may not contain an empty string or set: %s", name)
+		}
+	}
+	return nil
+}
+
+// checkExpected checks the legacy Expected conditions of a write against the stored item, nil if there's none.
+func checkExpected(item dynamo.AttributeSet, expected map[string]dynamo.ExpectedValue) error {
+	for name, e := range expected {
+		val, exists := item[name]
+		switch {
+		case e.Value != nil && !e.Exists:
+			return validationError("One or more parameter values were invalid: Value provided in ExpectedAttributeValue for attribute %s with Exists false", name)
+		case e.Value != nil:
+			if !exists || !equalValues(val, *e.Value) {
+				return conditionFailed()
+			}
+		case e.Exists:
+			return validationError("One or more parameter values were invalid: Value must be provided when Exists is true for attribute %s", name)
+		case exists:
+			return conditionFailed()
+		}
+	}
+	return nil
+}
+
+// returnValues returns the attributes a write returns for its ReturnValues.
+func returnValues(returnValues string, old, item dynamo.AttributeSet, updated map[string]bool) dynamo.AttributeSet {
+	var attrs dynamo.AttributeSet
+	switch returnValues {
+	case dynamo.ReturnAllOld, "ALL_OLD": // dynamo.ReturnAllOld is misspelled.
+		attrs = old
+	case dynamo.ReturnAllNew:
+		attrs = item
+	case dynamo.ReturnUpdatedOld, dynamo.ReturnUpdateNew:
+		src := old
+		if returnValues == dynamo.ReturnUpdateNew {
+			src = item
+		}
+		attrs = dynamo.AttributeSet{}
+		for name := range updated {
+			if val, ok := src[name]; ok {
+				attrs[name] = val
+			}
+		}
+	}
+	if len(attrs) == 0 {
+		return nil
+	}
+	return copyItem(attrs)
+}
+
+func (s *Server) putItem(body []byte) (interface{}, error) {
+	req := dynamo.PutRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	k, err := t.key(req.Item, false)
+	if err != nil {
+		return nil, err
+	} else if err := checkItem(req.Item); err != nil {
+		return nil, err
+	}
+	old := t.items[k]
+	if err := checkExpected(old, req.Expected); err != nil {
+		return nil, err
+	}
+	s.write(t, k, req.Item)
+	return dynamo.UpdateResponse{Attributes: returnValues(req.ReturnValues, old, nil, nil)}, nil
+}
+
+func (s *Server) getItem(body []byte) (interface{}, error) {
+	req := dynamo.GetRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	k, err := t.key(req.Key, true)
+	if err != nil {
+		return nil, err
+	}
+	item, ok := t.items[k]
+	if !ok {
+		return struct{}{}, nil
+	}
+	return struct{ Item dynamo.AttributeSet }{t.project(item, nil, nil, req.AttributesToGet)}, nil
+}
+
+func (s *Server) deleteItem(body []byte) (interface{}, error) {
+	req := dynamo.DeleteItemRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	k, err := t.key(req.Key, true)
+	if err != nil {
+		return nil, err
+	}
+	old := t.items[k]
+	if err := checkExpected(old, req.Expected); err != nil {
+		return nil, err
+	}
+	if old != nil {
+		s.write(t, k, nil)
+	}
+	return dynamo.UpdateResponse{Attributes: returnValues(req.ReturnValues, old, nil, nil)}, nil
+}
+
+func (s *Server) updateItem(body []byte) (interface{}, error) {
+	req := dynamo.Update{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	k, err := t.key(req.Key, true)
+	if err != nil {
+		return nil, err
+	}
+	old := t.items[k]
+	if err := checkExpected(old, req.Expected); err != nil {
+		return nil, err
+	}
+	item, updated, err := applyUpdates(old, req.Key, req.AttributeUpdates)
+	if err != nil {
+		return nil, err
+	}
+	if item != nil {
+		s.write(t, k, item)
+	}
+	return dynamo.UpdateResponse{Attributes: returnValues(req.ReturnValues, old, item, updated)}, nil
+}
+
+// applyUpdates returns the item old becomes with the updates, nil if it doesn't exist and only attributes are deleted,
+// along with the updated attributes.
+func applyUpdates(old, key dynamo.AttributeSet, updates map[string]dynamo.AttributeUpdate) (dynamo.AttributeSet, map[string]bool, error) {
+	item := copyItem(old)
+	if item == nil {
+		item = copyItem(key)
+	}
+	updated := map[string]bool{}
+	create := old != nil
+	for name, u := range updates {
+		if _, ok := key[name]; ok {
+			return nil, nil, validationError("One or more parameter values were invalid: Cannot update attribute %s. This attribute is part of the key", name)
+		}
+		updated[name] = true
+		switch u.Action {
+		case dynamo.UpdateTypePut, "":
+			if !u.Value.IsValid() {
+				return nil, nil, validationError("One or more parameter values were invalid: An AttributeValue may not contain an empty string or set: %s", name)
+			}
+			item[name], create = u.Value, true
+		case dynamo.UpdateTypeDelete:
+			if !u.Value.IsValid() {
+				delete(item, name)
+				continue
+			}
+			val, ok := item[name]
+			if !ok {
+				continue
+			} else if valueType(val) != valueType(u.Value) || len(setMembers(val)) == 0 {
+				return nil, nil, validationError("Type mismatch for DELETE on %s", name)
+			}
+			if val = removeMembers(val, u.Value); val.IsValid() {
+				item[name] = val
+			} else {
+				delete(item, name)
+			}
+		case dynamo.UpdateTypeAdd:
+			val, err := addValues(item[name], u.Value)
+			if err != nil {
+				return nil, nil, err
+			}
+			item[name], create = val, true
+		default:
+			return nil, nil, validationError("Unknown action %s on %s", u.Action, name)
+		}
+	}
+	if !create {
+		return nil, updated, nil
+	}
+	return item, updated, nil
+}
+
+// addValues adds numbers, or the members of a set to another.
+func addValues(val, add dynamo.AttributeVal) (dynamo.AttributeVal, error) {
+	if !val.IsValid() {
+		return add, nil
+	} else if valueType(val) != valueType(add) {
+		return val, validationError("Type mismatch for ADD")
+	}
+	switch valueType(val) {
+	case dynamo.TypeNumber:
+		sum, err := addNumbers(val.N, add.N)
+		return dynamo.AttributeVal{N: sum}, err
+	case dynamo.TypeStringSet, dynamo.TypeNumberSet, dynamo.TypeBinarySet:
+		members := setMembers(val)
+		val = dynamo.AttributeVal{SS: append([]string{}, val.SS...), NS: append([]string{}, val.NS...), BS: append([]string{}, val.BS...)}
+		for _, s := range add.SS {
+			if !members[valueString(dynamo.AttributeVal{S: s})] {
+				val.SS = append(val.SS, s)
+			}
+		}
+		for _, n := range add.NS {
+			if !members[valueString(dynamo.AttributeVal{N: n})] {
+				val.NS = append(val.NS, n)
+			}
+		}
+		for _, b := range add.BS {
+			if !members[valueString(dynamo.AttributeVal{B: b})] {
+				val.BS = append(val.BS, b)
+			}
+		}
+		return val, nil
+	}
+	return val, validationError("ADD only supports numbers and sets")
+}
+
+func addNumbers(a, b string) (string, error) {
+	ra, okA := new(big.Rat).SetString(a)
+	rb, okB := new(big.Rat).SetString(b)
+	if !okA || !okB {
+		return "", validationError("Invalid number %s or %s", a, b)
+	}
+	sum := ra.Add(ra, rb)
+	if sum.IsInt() {
+		return sum.RatString(), nil
+	}
+	return strings.TrimRight(sum.FloatString(38), "0"), nil
+}
+
+// removeMembers removes the members of del from a set.
+func removeMembers(val, del dynamo.AttributeVal) dynamo.AttributeVal {
+	members := setMembers(del)
+	res := dynamo.AttributeVal{}
+	for _, s := range val.SS {
+		if !members[valueString(dynamo.AttributeVal{S: s})] {
+			res.SS = append(res.SS, s)
+		}
+	}
+	for _, n := range val.NS {
+		if !members[valueString(dynamo.AttributeVal{N: n})] {
+			res.NS = append(res.NS, n)
+		}
+	}
+	for _, b := range val.BS {
+		if !members[valueString(dynamo.AttributeVal{B: b})] {
+			res.BS = append(res.BS, b)
+		}
+	}
+	return res
+}
+
+func (s *Server) batchWriteItem(body []byte) (interface{}, error) {
+	req := dynamo.BatchWriteRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	type write struct {
+		t    *table
+		k    string
+		item dynamo.AttributeSet
+	}
+	writes := []write{}
+	seen := map[string]bool{}
+	for name, items := range req.RequestItems {
+		t, err := s.table(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ri := range items {
+			w := write{t: t}
+			var err error
+			if ri.PutRequest != nil {
+				if w.k, err = t.key(ri.PutRequest.Item, false); err == nil {
+					err = checkItem(ri.PutRequest.Item)
+				}
+				w.item = ri.PutRequest.Item
+			} else if ri.DeleteRequest != nil {
+				w.k, err = t.key(ri.DeleteRequest.Key, true)
+			} else {
+				err = validationError("Request items must have a PutRequest or a DeleteRequest")
+			}
+			if err != nil {
+				return nil, err
+			} else if seen[name+"\x00"+w.k] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			}
+			seen[name+"\x00"+w.k] = true
+			writes = append(writes, w)
+		}
+	}
+	if len(writes) > dynamo.BatchWriteItemLimit {
+		return nil, validationError("Too many items requested for the BatchWriteItem call")
+	}
+	for _, w := range writes {
+		if _, ok := w.t.items[w.k]; ok || w.item != nil {
+			s.write(w.t, w.k, w.item)
+		}
+	}
+	return dynamo.BatchResponse{UnprocessedItems: map[string][]dynamo.RequestItem{}}, nil
+}
+
+func (s *Server) batchGetItem(body []byte) (interface{}, error) {
+	req := dynamo.BatchGetRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	res := struct {
+		Responses       map[string][]dynamo.AttributeSet
+		UnprocessedKeys map[string]dynamo.RequestItem
+	}{map[string][]dynamo.AttributeSet{}, map[string]dynamo.RequestItem{}}
+	n := 0
+	for name, ri := range req.RequestItems {
+		t, err := s.table(name)
+		if err != nil {
+			return nil, err
+		}
+		res.Responses[name] = []dynamo.AttributeSet{}
+		for _, key := range ri.Keys {
+			n++
+			k, err := t.key(key, true)
+			if err != nil {
+				return nil, err
+			} else if item, ok := t.items[k]; ok {
+				res.Responses[name] = append(res.Responses[name], t.project(item, nil, nil, ri.AttributesToGet))
+			}
+		}
+	}
+	if n > dynamo.BatchGetItemLimit {
+		return nil, validationError("Too many items requested for the BatchGetItem call")
+	}
+	return res, nil
+}
+
+// page evaluates the items ordered by less from the first one after start, at most limit or the server's page size.
+// It returns the items passing filter, projected, the number evaluated and the key to start the next page from if
+// any are left.
+func (s *Server) page(t *table, items []dynamo.AttributeSet, less func(a, b dynamo.AttributeSet) bool, start dynamo.AttributeSet, limit int, schema []dynamo.Key, filter func(dynamo.AttributeSet) bool, project func(dynamo.AttributeSet) dynamo.AttributeSet) ([]dynamo.AttributeSet, int, dynamo.AttributeSet) {
+	if len(start) > 0 {
+		i := 0
+		for i < len(items) && !less(start, items[i]) {
+			i++
+		}
+		items = items[i:]
+	}
+	if limit <= 0 {
+		limit = s.PageSize
+	}
+	var last dynamo.AttributeSet
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+		last = t.keyOf(items[limit-1], schema)
+	}
+	res := []dynamo.AttributeSet{}
+	for _, item := range items {
+		if filter(item) {
+			res = append(res, project(item))
+		}
+	}
+	return res, len(items), last
+}
+
+// Operators of key conditions on range keys.
+var keyOperators = map[string]bool{
+	dynamo.ConditionEqual: true, dynamo.ConditionLessThan: true, dynamo.ConditionLessThanOrEqual: true,
+	dynamo.ConditionGreaterThan: true, dynamo.ConditionGreaterThanOrEqual: true, dynamo.ConditionBeginsWith: true,
+	dynamo.ConditionBetween: true,
+}
+
+func (s *Server) query(body []byte) (interface{}, error) {
+	req := dynamo.Query{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	// ScanIndexForward is omitted by dynamo.Query when false, so it's true unless given as false.
+	forward := struct{ ScanIndexForward *bool }{}
+	json.Unmarshal(body, &forward)
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	schema, projection, err := t.index(req.IndexName)
+	if err != nil {
+		return nil, err
+	}
+	hashName, rangeName := keyNames(schema)
+	hash, ok := req.KeyConditions[hashName]
+	if !ok || hash.ComparisonOperator != dynamo.ConditionEqual || len(hash.AttributeValueList) != 1 {
+		return nil, validationError("Query condition missed key schema element: %s", hashName)
+	}
+	for name, cond := range req.KeyConditions {
+		if name != hashName && name != rangeName {
+			return nil, validationError("Query condition missed key schema element: %s is not a key", name)
+		} else if err := checkCondition(name, cond); err != nil {
+			return nil, err
+		} else if name == rangeName && !keyOperators[cond.ComparisonOperator] {
+			return nil, validationError("Unsupported operator on KeyCondition: %s", cond.ComparisonOperator)
+		}
+	}
+	less := t.order(rangeName, forward.ScanIndexForward == nil || *forward.ScanIndexForward)
+	items := t.sorted(schema, &hash.AttributeValueList[0], less)
+	matched, scanned, last := s.page(t, items, less, req.ExclusiveStartKey, req.Limit, schema, func(item dynamo.AttributeSet) bool {
+		return matchesAll(item, req.KeyConditions)
+	}, func(item dynamo.AttributeSet) dynamo.AttributeSet {
+		return t.project(item, schema, projection, req.AttributesToGet)
+	})
+	res := dynamo.QueryResponse{Count: len(matched), ScannedCount: scanned, Items: matched, LastEvaluatedKey: last}
+	if req.Select == dynamo.SelectCount {
+		res.Items = nil
+	}
+	return res, nil
+}
+
+func (s *Server) scan(body []byte) (interface{}, error) {
+	req := dynamo.ScanRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if req.TotalSegments > 1 && (req.Segment < 0 || req.Segment >= req.TotalSegments) {
+		return nil, validationError("Segment must be less than TotalSegments")
+	}
+	for name, cond := range req.ScanFilter {
+		if err := checkCondition(name, cond); err != nil {
+			return nil, err
+		}
+	}
+	less := t.order("", true)
+	items := []dynamo.AttributeSet{}
+	for _, item := range t.sorted(t.desc.KeySchema, nil, less) {
+		if t.segment(item, req.TotalSegments) == req.Segment || req.TotalSegments <= 1 {
+			items = append(items, item)
+		}
+	}
+	matched, scanned, last := s.page(t, items, less, req.ExclusiveStartKey, req.Limit, nil, func(item dynamo.AttributeSet) bool {
+		return matchesAll(item, req.ScanFilter)
+	}, func(item dynamo.AttributeSet) dynamo.AttributeSet {
+		return t.project(item, nil, nil, req.AttributesToGet)
+	})
+	res := dynamo.QueryResponse{Count: len(matched), ScannedCount: scanned, Items: matched, LastEvaluatedKey: last}
+	if req.Select == dynamo.SelectCount {
+		res.Items = nil
+	}
+	return res, nil
+}
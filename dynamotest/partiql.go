@@ -0,0 +1,802 @@
+package dynamotest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/poptip/dynamo"
+)
+
+// The PartiQL subset served:
+//
+//	SELECT * | a, b FROM "table"[."index"] [WHERE conditions]
+//	INSERT INTO "table" VALUE {'a': ?, 'b': 'literal'}
+//	UPDATE "table" SET a = ?, b = b + 1 REMOVE c WHERE key conditions [AND conditions]
+//	DELETE FROM "table" WHERE key conditions [AND conditions]
+//
+// Conditions are joined by AND, comparing an attribute with =, <>, !=, <, <=, >, >=, BETWEEN, IN, IS [NOT] MISSING,
+// or using begins_with, contains, attribute_exists and attribute_not_exists. Values are ?, 'strings', numbers and
+// <<sets>>. SELECTs with an equality on the hash key of the table or index are queries, others are scans. The
+// reads of batches and transactions, and every write, must have an equality on each key attribute of the table.
+
+// statement is a parsed PartiQL statement, its parameters bound.
+type statement struct {
+	verb         string // SELECT, INSERT, UPDATE or DELETE.
+	table, index string
+	names        []string // Projected by SELECT, all if nil.
+	where        []predicate
+	values       dynamo.AttributeSet // Of INSERT.
+	set          []assignment
+	remove       []string
+}
+
+type predicate struct {
+	name string
+	cond dynamo.Condition
+}
+
+// assignment is a SET of UPDATE: name = val, or name = from op val for arithmetic.
+type assignment struct {
+	name, from, op string
+	val            dynamo.AttributeVal
+}
+
+const (
+	tokEnd = iota
+	tokIdent
+	tokQuotedIdent // "name"
+	tokString      // 'string'
+	tokNumber
+	tokPunct
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+var punctuation = []string{"<<", ">>", "<=", ">=", "<>", "!=", "?", ",", "(", ")", "[", "]", "{", "}", ":", ".", "=", "<", ">", "+", "-", "*"}
+
+func lex(stmt string) ([]token, error) {
+	toks := []token{}
+	isDigit := func(i int) bool { return i < len(stmt) && stmt[i] >= '0' && stmt[i] <= '9' }
+	isLetter := func(i int) bool {
+		c := stmt[i]
+		return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	}
+	for i := 0; i < len(stmt); {
+		switch c := stmt[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			text := []byte{}
+			j := i + 1
+			for ; ; j++ {
+				if j >= len(stmt) {
+					return nil, validationError("Statement wasn't well formed: unterminated quote at position %d", i)
+				} else if stmt[j] == c && j+1 < len(stmt) && stmt[j+1] == c {
+					text = append(text, c)
+					j++
+				} else if stmt[j] == c {
+					break
+				} else {
+					text = append(text, stmt[j])
+				}
+			}
+			kind := tokString
+			if c == '"' {
+				kind = tokQuotedIdent
+			}
+			toks = append(toks, token{kind, string(text), i})
+			i = j + 1
+		case isDigit(i) || (c == '.' && isDigit(i+1)):
+			j := i
+			for isDigit(j) || (j < len(stmt) && stmt[j] == '.') {
+				j++
+			}
+			if j < len(stmt) && (stmt[j] == 'e' || stmt[j] == 'E') {
+				j++
+				if j < len(stmt) && (stmt[j] == '+' || stmt[j] == '-') {
+					j++
+				}
+				for isDigit(j) {
+					j++
+				}
+			}
+			toks = append(toks, token{tokNumber, stmt[i:j], i})
+			i = j
+		case isLetter(i):
+			j := i
+			for j < len(stmt) && (isLetter(j) || isDigit(j)) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, stmt[i:j], i})
+			i = j
+		default:
+			found := false
+			for _, p := range punctuation {
+				if strings.HasPrefix(stmt[i:], p) {
+					toks = append(toks, token{tokPunct, p, i})
+					i += len(p)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, validationError("Statement wasn't well formed: unexpected %q at position %d", c, i)
+			}
+		}
+	}
+	return append(toks, token{tokEnd, "", len(stmt)}), nil
+}
+
+// parser parses a statement, binding the values of its parameters in order.
+type parser struct {
+	toks   []token
+	i      int
+	params []dynamo.AttributeVal
+	bound  int
+}
+
+// parse parses a statement of the subset with its parameters.
+func parse(stmt string, params []dynamo.AttributeVal) (*statement, error) {
+	toks, err := lex(stmt)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, params: params}
+	st, err := p.statement()
+	if err != nil {
+		return nil, err
+	} else if p.peek().kind != tokEnd {
+		return nil, p.unexpected("the end of the statement")
+	} else if p.bound != len(params) {
+		return nil, validationError("Number of parameters in request and statement don't match")
+	}
+	return st, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEnd {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) unexpected(what string) error {
+	if t := p.peek(); t.kind != tokEnd {
+		return validationError("Statement wasn't well formed: expected %s at position %d, found %q", what, t.pos, t.text)
+	}
+	return validationError("Statement wasn't well formed: expected %s at the end", what)
+}
+
+func (p *parser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+func (p *parser) isPunct(punct string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.text == punct
+}
+
+// accept consumes the next token if it's the keyword or punctuation s.
+func (p *parser) accept(s string) bool {
+	if p.isKeyword(s) || p.isPunct(s) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return p.unexpected(s)
+	}
+	return nil
+}
+
+func (p *parser) name(what string) (string, error) {
+	if t := p.peek(); t.kind == tokIdent || t.kind == tokQuotedIdent {
+		return p.next().text, nil
+	}
+	return "", p.unexpected(what)
+}
+
+func (p *parser) statement() (*statement, error) {
+	if p.peek().kind != tokIdent {
+		return nil, p.unexpected("SELECT, INSERT, UPDATE or DELETE")
+	}
+	st := &statement{verb: strings.ToUpper(p.peek().text)}
+	var err error
+	switch st.verb {
+	case "SELECT":
+		p.next()
+		if !p.accept("*") {
+			for {
+				name, err := p.name("attribute")
+				if err != nil {
+					return nil, err
+				}
+				st.names = append(st.names, name)
+				if !p.accept(",") {
+					break
+				}
+			}
+		}
+		if err := p.expect("FROM"); err != nil {
+			return nil, err
+		}
+		err = p.target(st)
+	case "INSERT":
+		p.next()
+		if err := p.expect("INTO"); err != nil {
+			return nil, err
+		} else if err := p.target(st); err != nil {
+			return nil, err
+		} else if err := p.expect("VALUE"); err != nil {
+			return nil, err
+		}
+		return st, p.tuple(st)
+	case "UPDATE":
+		p.next()
+		if err := p.target(st); err != nil {
+			return nil, err
+		}
+		err = p.updates(st)
+	case "DELETE":
+		p.next()
+		if err := p.expect("FROM"); err != nil {
+			return nil, err
+		}
+		err = p.target(st)
+	default:
+		return nil, p.unexpected("SELECT, INSERT, UPDATE or DELETE")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.accept("WHERE") {
+		for {
+			pred, err := p.predicate()
+			if err != nil {
+				return nil, err
+			}
+			st.where = append(st.where, pred)
+			if !p.accept("AND") {
+				break
+			}
+		}
+	}
+	return st, nil
+}
+
+// target parses a table and, for SELECT, an optional index.
+func (p *parser) target(st *statement) error {
+	var err error
+	if st.table, err = p.name("table"); err != nil {
+		return err
+	}
+	if st.verb == "SELECT" && p.accept(".") {
+		st.index, err = p.name("index")
+	}
+	return err
+}
+
+// tuple parses the item inserted.
+func (p *parser) tuple(st *statement) error {
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	st.values = dynamo.AttributeSet{}
+	for {
+		if p.peek().kind != tokString {
+			return p.unexpected("attribute name")
+		}
+		name := p.next().text
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		val, err := p.value()
+		if err != nil {
+			return err
+		}
+		st.values[name] = val
+		if !p.accept(",") {
+			break
+		}
+	}
+	return p.expect("}")
+}
+
+// updates parses the SET and REMOVE clauses of UPDATE.
+func (p *parser) updates(st *statement) error {
+	for {
+		switch {
+		case p.accept("SET"):
+			for {
+				a := assignment{}
+				var err error
+				if a.name, err = p.name("attribute"); err != nil {
+					return err
+				} else if err := p.expect("="); err != nil {
+					return err
+				}
+				if t := p.peek(); t.kind == tokIdent || t.kind == tokQuotedIdent {
+					a.from = p.next().text
+					if !p.isPunct("+") && !p.isPunct("-") {
+						return p.unexpected("+ or -")
+					}
+					a.op = p.next().text
+				}
+				if a.val, err = p.value(); err != nil {
+					return err
+				}
+				st.set = append(st.set, a)
+				if !p.accept(",") {
+					break
+				}
+			}
+		case p.accept("REMOVE"):
+			for {
+				name, err := p.name("attribute")
+				if err != nil {
+					return err
+				}
+				st.remove = append(st.remove, name)
+				if !p.accept(",") {
+					break
+				}
+			}
+		default:
+			if len(st.set) == 0 && len(st.remove) == 0 {
+				return p.unexpected("SET or REMOVE")
+			}
+			return nil
+		}
+	}
+}
+
+var comparisons = map[string]string{
+	"=": dynamo.ConditionEqual, "<>": dynamo.ConditionNotEqual, "!=": dynamo.ConditionNotEqual,
+	"<": dynamo.ConditionLessThan, "<=": dynamo.ConditionLessThanOrEqual, ">": dynamo.ConditionGreaterThan,
+	">=": dynamo.ConditionGreaterThanOrEqual,
+}
+
+var functions = map[string]string{
+	"begins_with": dynamo.ConditionBeginsWith, "contains": dynamo.ConditionContains,
+	"attribute_exists": dynamo.ConditionAttributeExists, "attribute_not_exists": dynamo.ConditionAttributeNotExists,
+}
+
+func (p *parser) predicate() (predicate, error) {
+	pred := predicate{}
+	var err error
+	if t := p.peek(); t.kind == tokIdent && p.toks[p.i+1].kind == tokPunct && p.toks[p.i+1].text == "(" {
+		op, ok := functions[strings.ToLower(t.text)]
+		if !ok {
+			return pred, validationError("Statement wasn't well formed: unsupported function %s", t.text)
+		}
+		p.next()
+		p.next()
+		pred.cond.ComparisonOperator = op
+		if pred.name, err = p.name("attribute"); err != nil {
+			return pred, err
+		}
+		if operatorArgs[op] > 0 {
+			if err := p.expect(","); err != nil {
+				return pred, err
+			}
+			if err := p.values(&pred.cond, 1); err != nil {
+				return pred, err
+			}
+		}
+		return pred, p.expect(")")
+	}
+	if pred.name, err = p.name("attribute or function"); err != nil {
+		return pred, err
+	}
+	switch t := p.peek(); {
+	case t.kind == tokPunct && len(comparisons[t.text]) > 0:
+		p.next()
+		pred.cond.ComparisonOperator = comparisons[t.text]
+		err = p.values(&pred.cond, 1)
+	case p.accept("BETWEEN"):
+		pred.cond.ComparisonOperator = dynamo.ConditionBetween
+		if err = p.values(&pred.cond, 1); err == nil {
+			if err = p.expect("AND"); err == nil {
+				err = p.values(&pred.cond, 1)
+			}
+		}
+	case p.accept("IN"):
+		pred.cond.ComparisonOperator = dynamo.ConditionIn
+		end := ")"
+		if p.accept("[") {
+			end = "]"
+		} else if err := p.expect("("); err != nil {
+			return pred, err
+		}
+		for err == nil {
+			if err = p.values(&pred.cond, 1); err == nil && !p.accept(",") {
+				break
+			}
+		}
+		if err == nil {
+			err = p.expect(end)
+		}
+	case p.accept("IS"):
+		pred.cond.ComparisonOperator = dynamo.ConditionAttributeNotExists
+		if p.accept("NOT") {
+			pred.cond.ComparisonOperator = dynamo.ConditionAttributeExists
+		}
+		err = p.expect("MISSING")
+	default:
+		err = p.unexpected("operator")
+	}
+	return pred, err
+}
+
+// values parses n values, appending them to the values of cond.
+func (p *parser) values(cond *dynamo.Condition, n int) error {
+	for i := 0; i < n; i++ {
+		val, err := p.value()
+		if err != nil {
+			return err
+		}
+		cond.AttributeValueList = append(cond.AttributeValueList, val)
+	}
+	return nil
+}
+
+// value parses a parameter, a string, a number or a set.
+func (p *parser) value() (dynamo.AttributeVal, error) {
+	if p.accept("?") {
+		if p.bound >= len(p.params) {
+			return dynamo.AttributeVal{}, validationError("Number of parameters in request and statement don't match")
+		}
+		p.bound++
+		return p.params[p.bound-1], nil
+	} else if !p.accept("<<") {
+		return p.scalar()
+	}
+	set := dynamo.AttributeVal{}
+	for {
+		val, err := p.scalar()
+		if err != nil {
+			return set, err
+		} else if len(val.N) > 0 && len(set.SS) == 0 {
+			set.NS = append(set.NS, val.N)
+		} else if len(val.N) == 0 && len(set.NS) == 0 {
+			set.SS = append(set.SS, val.S)
+		} else {
+			return set, validationError("Statement wasn't well formed: sets can't mix strings and numbers")
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	return set, p.expect(">>")
+}
+
+func (p *parser) scalar() (dynamo.AttributeVal, error) {
+	sign := ""
+	if p.accept("-") {
+		sign = "-"
+	}
+	switch t := p.peek(); {
+	case t.kind == tokNumber:
+		return dynamo.AttributeVal{N: sign + p.next().text}, nil
+	case t.kind == tokString && len(sign) == 0:
+		return dynamo.AttributeVal{S: p.next().text}, nil
+	}
+	return dynamo.AttributeVal{}, p.unexpected("value")
+}
+
+func matchesWhere(item dynamo.AttributeSet, where []predicate) bool {
+	for _, p := range where {
+		if !matches(item, p.name, p.cond) {
+			return false
+		}
+	}
+	return true
+}
+
+// keyOfWhere returns the string of the key equalities of where identify, and the other predicates. Each key attribute
+// must have one.
+func (t *table) keyOfWhere(where []predicate) (string, []predicate, error) {
+	key := dynamo.AttributeSet{}
+	rest := []predicate{}
+	for _, p := range where {
+		_, found := key[p.name]
+		if !found && p.cond.ComparisonOperator == dynamo.ConditionEqual && t.isKey(p.name) {
+			key[p.name] = p.cond.AttributeValueList[0]
+		} else {
+			rest = append(rest, p)
+		}
+	}
+	if len(key) != len(t.desc.KeySchema) {
+		return "", nil, validationError("Where clause does not contain a mandatory equality on all key attributes")
+	}
+	k, err := t.key(key, true)
+	return k, rest, err
+}
+
+// selectItems runs a SELECT as a query or a scan, returning a page of items.
+func (s *Server) selectItems(st *statement, limit int, nextToken string) (interface{}, error) {
+	t, err := s.table(st.table)
+	if err != nil {
+		return nil, err
+	}
+	schema, projection, err := t.index(st.index)
+	if err != nil {
+		return nil, err
+	}
+	var start dynamo.AttributeSet
+	if len(nextToken) > 0 {
+		b, err := base64.StdEncoding.DecodeString(nextToken)
+		if err != nil || json.Unmarshal(b, &start) != nil {
+			return nil, validationError("Invalid NextToken")
+		}
+	}
+	hashName, rangeName := keyNames(schema)
+	var hash *dynamo.AttributeVal
+	less := t.order("", true)
+	for _, p := range st.where {
+		if p.name == hashName && p.cond.ComparisonOperator == dynamo.ConditionEqual {
+			hash, less = &p.cond.AttributeValueList[0], t.order(rangeName, true)
+			break
+		}
+	}
+	items, _, last := s.page(t, t.sorted(schema, hash, less), less, start, limit, schema, func(item dynamo.AttributeSet) bool {
+		return matchesWhere(item, st.where)
+	}, func(item dynamo.AttributeSet) dynamo.AttributeSet {
+		return t.project(item, schema, projection, st.names)
+	})
+	res := dynamo.ExecuteStatementResponse{Items: items, LastEvaluatedKey: last}
+	if last != nil {
+		b, _ := json.Marshal(last)
+		res.NextToken = base64.StdEncoding.EncodeToString(b)
+	}
+	return res, nil
+}
+
+// getStatement runs a SELECT of a batch or a transaction, which reads one item by key. It returns nil if there's none.
+func (s *Server) getStatement(st *statement) (dynamo.AttributeSet, error) {
+	t, err := s.table(st.table)
+	if err != nil {
+		return nil, err
+	} else if len(st.index) > 0 {
+		return nil, validationError("Reads of batches and transactions can't use indexes")
+	}
+	k, rest, err := t.keyOfWhere(st.where)
+	if err != nil {
+		return nil, err
+	} else if item, ok := t.items[k]; ok && matchesWhere(item, rest) {
+		return t.project(item, nil, nil, st.names), nil
+	}
+	return nil, nil
+}
+
+// change is a write planned against the current items, see apply.
+type change struct {
+	t         *table
+	k         string
+	old, item dynamo.AttributeSet // item is nil for deletes.
+}
+
+// plan plans the write of an INSERT, UPDATE or DELETE.
+func (s *Server) plan(st *statement) (*change, error) {
+	t, err := s.table(st.table)
+	if err != nil {
+		return nil, err
+	}
+	c := &change{t: t}
+	if st.verb == "INSERT" {
+		if c.k, err = t.key(st.values, false); err != nil {
+			return nil, err
+		} else if err := checkItem(st.values); err != nil {
+			return nil, err
+		} else if _, ok := t.items[c.k]; ok {
+			return nil, &apiError{code: "DuplicateItemException", msg: "Duplicate primary key exists in table"}
+		}
+		c.item = st.values
+		return c, nil
+	}
+	var rest []predicate
+	if c.k, rest, err = t.keyOfWhere(st.where); err != nil {
+		return nil, err
+	}
+	c.old = t.items[c.k]
+	if st.verb == "DELETE" {
+		if len(rest) > 0 && (c.old == nil || !matchesWhere(c.old, rest)) {
+			return nil, conditionFailed()
+		}
+		return c, nil
+	} else if c.old == nil || !matchesWhere(c.old, rest) {
+		return nil, conditionFailed()
+	}
+	c.item = copyItem(c.old)
+	for _, a := range st.set {
+		val := a.val
+		if t.isKey(a.name) {
+			return nil, validationError("Cannot update attribute %s. This attribute is part of the key", a.name)
+		} else if len(a.op) > 0 {
+			from, ok := c.old[a.from]
+			if !ok || valueType(from) != dynamo.TypeNumber || valueType(val) != dynamo.TypeNumber {
+				return nil, validationError("Arithmetic on %s requires numbers", a.from)
+			}
+			if a.op == "-" {
+				val.N = "-" + val.N
+				if strings.HasPrefix(val.N, "--") {
+					val.N = val.N[2:]
+				}
+			}
+			if val.N, err = addNumbers(from.N, val.N); err != nil {
+				return nil, err
+			}
+		}
+		c.item[a.name] = val
+	}
+	for _, name := range st.remove {
+		if t.isKey(name) {
+			return nil, validationError("Cannot update attribute %s. This attribute is part of the key", name)
+		}
+		delete(c.item, name)
+	}
+	return c, checkItem(c.item)
+}
+
+func (s *Server) apply(c *change) {
+	if c.item != nil || c.old != nil {
+		s.write(c.t, c.k, c.item)
+	}
+}
+
+func (s *Server) executeStatement(body []byte) (interface{}, error) {
+	req := dynamo.ExecuteStatementRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	st, err := parse(req.Statement, req.Parameters)
+	if err != nil {
+		return nil, err
+	} else if st.verb == "SELECT" {
+		return s.selectItems(st, req.Limit, req.NextToken)
+	}
+	c, err := s.plan(st)
+	if err != nil {
+		return nil, err
+	}
+	s.apply(c)
+	return dynamo.ExecuteStatementResponse{Items: []dynamo.AttributeSet{}}, nil
+}
+
+// errorCode returns the code of the error of a statement of a batch, or of the reason of a cancelled transaction.
+func errorCode(e *apiError) string {
+	if e.code == dynamo.ValidationException {
+		return "ValidationError"
+	}
+	return strings.TrimSuffix(e.code, "Exception")
+}
+
+// parseAll parses the statements of a batch or a transaction, which must all be reads or all writes.
+func parseAll(stmts []dynamo.ParameterizedStatement) ([]*statement, []error, error) {
+	parsed := make([]*statement, len(stmts))
+	errs := make([]error, len(stmts))
+	reads := 0
+	for i, stmt := range stmts {
+		if parsed[i], errs[i] = parse(stmt.Statement, stmt.Parameters); errs[i] == nil && parsed[i].verb == "SELECT" {
+			reads++
+		}
+	}
+	if reads > 0 && reads < len(stmts) {
+		return nil, nil, validationError("Statements must be all reads or all writes")
+	}
+	return parsed, errs, nil
+}
+
+func (s *Server) batchExecuteStatement(body []byte) (interface{}, error) {
+	req := dynamo.BatchExecuteStatementRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	} else if len(req.Statements) == 0 || len(req.Statements) > dynamo.BatchWriteItemLimit {
+		return nil, validationError("Batches must have between 1 and %d statements", dynamo.BatchWriteItemLimit)
+	}
+	stmts := make([]dynamo.ParameterizedStatement, len(req.Statements))
+	for i, stmt := range req.Statements {
+		stmts[i] = dynamo.ParameterizedStatement{Statement: stmt.Statement, Parameters: stmt.Parameters}
+	}
+	parsed, errs, err := parseAll(stmts)
+	if err != nil {
+		return nil, err
+	}
+	res := dynamo.BatchExecuteStatementResponse{Responses: make([]dynamo.BatchStatementResponse, len(parsed))}
+	for i, st := range parsed {
+		err := errs[i]
+		if err == nil {
+			res.Responses[i].TableName = st.table
+			if st.verb == "SELECT" {
+				res.Responses[i].Item, err = s.getStatement(st)
+			} else {
+				var c *change
+				if c, err = s.plan(st); err == nil {
+					s.apply(c)
+				}
+			}
+		}
+		if e, ok := err.(*apiError); ok {
+			res.Responses[i].Error = &dynamo.BatchStatementError{Code: errorCode(e), Message: e.msg}
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *Server) executeTransaction(body []byte) (interface{}, error) {
+	req := dynamo.ExecuteTransactionRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	} else if len(req.TransactStatements) == 0 || len(req.TransactStatements) > dynamo.TransactItemLimit {
+		return nil, validationError("Transactions must have between 1 and %d statements", dynamo.TransactItemLimit)
+	}
+	parsed, errs, err := parseAll(req.TransactStatements)
+	if err != nil {
+		return nil, err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	res := dynamo.ExecuteTransactionResponse{}
+	if parsed[0].verb == "SELECT" {
+		for _, st := range parsed {
+			item, err := s.getStatement(st)
+			if err != nil {
+				return nil, err
+			}
+			res.Responses = append(res.Responses, struct{ Item dynamo.AttributeSet }{item})
+		}
+		return res, nil
+	}
+	changes := make([]*change, len(parsed))
+	reasons := make([]dynamo.CancellationReason, len(parsed))
+	codes := make([]string, len(parsed))
+	cancelled := false
+	seen := map[string]bool{}
+	for i, st := range parsed {
+		reasons[i].Code = "None"
+		c, err := s.plan(st)
+		if e, ok := err.(*apiError); ok && (e.code == dynamo.ConditionalCheckFailedException || e.code == "DuplicateItemException") {
+			reasons[i] = dynamo.CancellationReason{Code: errorCode(e), Message: e.msg}
+			cancelled = true
+		} else if err != nil {
+			return nil, err
+		} else if seen[st.table+"\x00"+c.k] {
+			return nil, validationError("Transaction request cannot include multiple operations on one item")
+		} else {
+			seen[st.table+"\x00"+c.k] = true
+			changes[i] = c
+		}
+		codes[i] = reasons[i].Code
+	}
+	if cancelled {
+		return nil, &apiError{
+			code:    dynamo.TransactionCanceledException,
+			msg:     fmt.Sprintf("Transaction cancelled, please refer cancellation reasons for specific reasons [%s]", strings.Join(codes, ", ")),
+			reasons: reasons,
+		}
+	}
+	for _, c := range changes {
+		s.apply(c)
+	}
+	return res, nil
+}
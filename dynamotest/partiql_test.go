@@ -0,0 +1,129 @@
+package dynamotest
+
+import (
+	"testing"
+
+	"github.com/poptip/dynamo"
+)
+
+type thing struct {
+	ID    string   `dynamo:"id"`
+	N     int      `dynamo:"n"`
+	Count int      `dynamo:"count"`
+	Tags  []string `dynamo:"tags"`
+}
+
+func TestExecuteStatement(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.PageSize = 2
+	c := newTestTable(t, s)
+	for i := 1; i <= 5; i++ {
+		if _, _, err := c.ExecuteStatement(`INSERT INTO "things" VALUE {'id': ?, 'n': ?, 'count': 0, 'tags': <<'x', 'y'>>}`, "", "a", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := c.ExecuteStatement(`INSERT INTO things VALUE {'id': 'a', 'n': 1}`, ""); !dynamo.IsErrorCode(err, "DuplicateItemException") {
+		t.Errorf("Inserted a duplicate: %v", err)
+	}
+	if _, _, err := c.ExecuteStatement(`UPDATE things SET count = count + ?, label = 'it''s' WHERE id = 'a' AND n = 2 AND count = 0`, "", 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.ExecuteStatement(`UPDATE things REMOVE tags WHERE id = 'a' AND n = 2 AND count = 0`, ""); !dynamo.IsErrorCode(err, dynamo.ConditionalCheckFailedException) {
+		t.Errorf("Updated despite a false condition: %v", err)
+	}
+	if _, _, err := c.ExecuteStatement(`DELETE FROM things WHERE id = 'a' AND n = 5`, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	things := []thing{}
+	if err := c.ExecuteStatementInto(`SELECT * FROM "things" WHERE id = ? AND n BETWEEN 2 AND 4 AND contains(tags, 'x')`, &things, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if len(things) != 3 || things[0].N != 2 || things[0].Count != 3 || things[2].N != 4 || len(things[2].Tags) != 2 {
+		t.Errorf("Selected %+v", things)
+	}
+	items, next, err := c.ExecuteStatement(`SELECT n FROM things WHERE n IN [1, 4] AND label IS MISSING`, "")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(items) != 1 || len(items[0]) != 1 || next == "" {
+		t.Errorf("Selected %v, next token %q", items, next)
+	}
+
+	for stmt, msg := range map[string]string{
+		`SELECT * FROM things WHERE id == 'a'`:    `Statement wasn't well formed: expected value at position 31, found "="`,
+		`DELETE FROM things WHERE id = 'a'`:       "Where clause does not contain a mandatory equality on all key attributes",
+		`UPDATE things SET n = 1 WHERE id = 'a' `: "Where clause does not contain a mandatory equality on all key attributes",
+		`SELECT * FROM things."byName"`:           "The table does not have the specified index: byName",
+	} {
+		_, _, err := c.ExecuteStatement(stmt, "")
+		if e, ok := err.(*dynamo.Error); !ok || e.Message != msg {
+			t.Errorf("%s: unexpected error %v", stmt, err)
+		}
+	}
+}
+
+func TestBatchExecuteStatement(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := newTestTable(t, s)
+	res, err := c.BatchExecuteStatement([]dynamo.Statement{
+		{Statement: `INSERT INTO things VALUE {'id': 'a', 'n': 1}`},
+		{Statement: `INSERT INTO things VALUE {'id': 'a', 'n': 1}`},
+		{Statement: `DELETE FROM things WHERE id = ?`, Params: []interface{}{"a"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if res[0].Error != nil || res[1].Error.Code != "DuplicateItem" || res[2].Error.Code != "ValidationError" {
+		t.Errorf("Unexpected responses %+v", res)
+	}
+	res, err = c.BatchExecuteStatement([]dynamo.Statement{
+		{Statement: `SELECT * FROM things WHERE id = 'a' AND n = 1`},
+		{Statement: `SELECT * FROM things WHERE id = 'a' AND n = 2`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if res[0].Item["id"].S != "a" || res[0].TableName != "things" || res[1].Item != nil {
+		t.Errorf("Unexpected responses %+v", res)
+	}
+	if _, err := c.BatchExecuteStatement([]dynamo.Statement{
+		{Statement: `SELECT * FROM things WHERE id = 'a' AND n = 1`},
+		{Statement: `DELETE FROM things WHERE id = 'a' AND n = 1`},
+	}); !dynamo.IsErrorCode(err, dynamo.ValidationException) {
+		t.Errorf("Mixed reads and writes: %v", err)
+	}
+}
+
+func TestExecuteTransaction(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := newTestTable(t, s)
+	if _, err := c.ExecuteTransaction([]dynamo.Statement{
+		{Statement: `INSERT INTO things VALUE {'id': 'a', 'n': 1, 'count': 1}`},
+		{Statement: `INSERT INTO things VALUE {'id': 'a', 'n': 2, 'count': 1}`},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.ExecuteTransaction([]dynamo.Statement{
+		{Statement: `UPDATE things SET count = count - 1 WHERE id = 'a' AND n = 1`},
+		{Statement: `UPDATE things SET count = 5 WHERE id = 'a' AND n = 2 AND count > 1`},
+	})
+	if e, ok := err.(*dynamo.TxCanceledError); !ok || e.Reasons[0].Code != "None" || e.Reasons[1].Code != "ConditionalCheckFailed" {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	items, err := c.ExecuteTransaction([]dynamo.Statement{
+		{Statement: `SELECT count FROM things WHERE id = 'a' AND n = 1`},
+		{Statement: `SELECT * FROM things WHERE id = 'a' AND n = 3`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(items) != 2 || items[0]["count"].N != "1" || items[1] != nil {
+		t.Errorf("Cancelled transaction wrote, read %v", items)
+	}
+	if _, err := c.ExecuteTransaction([]dynamo.Statement{
+		{Statement: `DELETE FROM things WHERE id = 'a' AND n = 1`},
+		{Statement: `DELETE FROM things WHERE id = 'a' AND n = 1`},
+	}); !dynamo.IsErrorCode(err, dynamo.ValidationException) {
+		t.Errorf("Wrote an item twice: %v", err)
+	}
+}
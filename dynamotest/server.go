@@ -0,0 +1,424 @@
+// Package dynamotest serves an in-memory DynamoDB for tests, the way net/http/httptest serves HTTP:
+//
+//	s := dynamotest.NewServer()
+//	defer s.Close()
+//	c := s.Client()
+//
+// It implements the table operations and the item operations of the 20120810 API with their Expected,
+// AttributeUpdates, KeyConditions and ScanFilter parameters, as well as a subset of PartiQL, see ExecuteStatement.
+// Tables are active as soon as they're created. Requests aren't authenticated, and capacity isn't metered.
+//
+// Items expire on the server's clock, which tests can move with SetNow and Advance. As DynamoDB takes a while to
+// delete expired items, they're only deleted by Expire:
+//
+//	c.EnableTTL("sessions", "expires")
+//	s.Advance(time.Hour)
+//	s.Expire()
+//
+// Tables with a stream enabled, see dynamo.Client.EnableStream, record their changes with the view of their stream
+// type, readable with the streams package from the client of the server. Streams have one open shard, which
+// RotateShard closes to start a child of it. Records are kept until the server is closed.
+package dynamotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crowdmob/goamz/aws"
+	"github.com/poptip/dynamo"
+)
+
+// Server is an in-memory DynamoDB. Its methods are safe for concurrent use.
+type Server struct {
+	URL string // Of the form http://ipaddr:port, with no trailing slash.
+
+	// PageSize is the number of items evaluated per page by Query, Scan and PartiQL SELECT requests without a limit,
+	// where DynamoDB stops at 1MB. There's no limit if 0.
+	PageSize int
+
+	hs     *httptest.Server
+	mu     sync.Mutex
+	tables map[string]*table
+	now    time.Time // Of the clock if it was set, see Now.
+
+	streams []*stream // In the order they were enabled.
+	seq     int64     // Of the last stream record.
+	shards  int       // Started, numbering shard IDs.
+}
+
+// NewServer starts a server with no tables. It must be closed with Close.
+func NewServer() *Server {
+	s := &Server{tables: map[string]*table{}}
+	s.hs = httptest.NewServer(s)
+	s.URL = s.hs.URL
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.hs.Close()
+}
+
+// Now returns the time of the server's clock: the current time until it's set or advanced, after which it stands
+// still.
+func (s *Server) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clock()
+}
+
+// SetNow sets the server's clock.
+func (s *Server) SetNow(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = t
+}
+
+// Advance moves the server's clock forward by d.
+func (s *Server) Advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.clock().Add(d)
+}
+
+func (s *Server) clock() time.Time {
+	if s.now.IsZero() {
+		return time.Now()
+	}
+	return s.now
+}
+
+// Expire deletes the items whose TTL has passed on the server's clock from the tables with TTL enabled, returning
+// the number deleted.
+func (s *Server) Expire() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock()
+	n := 0
+	for _, t := range s.tables {
+		if t.ttl.TimeToLiveStatus != dynamo.TTLEnabled {
+			continue
+		}
+		for k, item := range t.items {
+			if dynamo.Expired(item, t.ttl.AttributeName, now) {
+				s.writeAs(t, k, nil, ttlIdentity)
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Region returns a region whose DynamoDB endpoint is the server.
+func (s *Server) Region() aws.Region {
+	region := aws.USEast
+	region.DynamoDBEndpoint = s.URL
+	return region
+}
+
+// Client returns a client of the server.
+func (s *Server) Client() *dynamo.Client {
+	return dynamo.NewClient(aws.Auth{AccessKey: "key", SecretKey: "secret"}, s.Region())
+}
+
+// handlers decode the body of a request and return the response, called with the server locked.
+var handlers map[string]func(s *Server, body []byte) (interface{}, error)
+
+func init() {
+	handlers = map[string]func(s *Server, body []byte) (interface{}, error){
+		dynamo.CreateTableEndpoint:           (*Server).createTable,
+		dynamo.DescribeTableEndpoint:         (*Server).describeTable,
+		dynamo.UpdateTableEndpoint:           (*Server).updateTable,
+		dynamo.DeleteTableEndpoint:           (*Server).deleteTable,
+		dynamo.ListTablesEndpoint:            (*Server).listTables,
+		dynamo.PutItemEndpoint:               (*Server).putItem,
+		dynamo.GetItemEndpoint:               (*Server).getItem,
+		dynamo.UpdateItemEndpoint:            (*Server).updateItem,
+		dynamo.DeleteItemEndpoint:            (*Server).deleteItem,
+		dynamo.BatchWriteItemEndpoint:        (*Server).batchWriteItem,
+		dynamo.BatchGetItemEndpoint:          (*Server).batchGetItem,
+		dynamo.QueryEndpoint:                 (*Server).query,
+		dynamo.ScanEndpoint:                  (*Server).scan,
+		dynamo.ExecuteStatementEndpoint:      (*Server).executeStatement,
+		dynamo.BatchExecuteStatementEndpoint: (*Server).batchExecuteStatement,
+		dynamo.ExecuteTransactionEndpoint:    (*Server).executeTransaction,
+		dynamo.UpdateTimeToLiveEndpoint:      (*Server).updateTimeToLive,
+		dynamo.DescribeTimeToLiveEndpoint:    (*Server).describeTimeToLive,
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	target := r.Header.Get("X-Amz-Target")
+	var handler func(s *Server, body []byte) (interface{}, error)
+	ok := false
+	if strings.HasPrefix(target, dynamo.DynamoBaseEndpoint) {
+		handler, ok = handlers[strings.TrimPrefix(target, dynamo.DynamoBaseEndpoint)]
+	} else if strings.HasPrefix(target, streamsBaseEndpoint) {
+		handler, ok = streamHandlers[strings.TrimPrefix(target, streamsBaseEndpoint)]
+	}
+	var res interface{}
+	if !ok {
+		err = &apiError{code: "UnknownOperationException", msg: "Unknown operation " + target}
+	} else {
+		s.mu.Lock()
+		res, err = handler(s, body)
+		s.mu.Unlock()
+	}
+	status := http.StatusOK
+	if e, ok := err.(*apiError); ok {
+		status, res = http.StatusBadRequest, e.body()
+	} else if err != nil {
+		status, res = http.StatusInternalServerError, (&apiError{code: "InternalServerError", msg: err.Error()}).body()
+	}
+	b, _ := json.Marshal(res)
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.Header().Set("X-Amz-Crc32", strconv.FormatUint(uint64(crc32.ChecksumIEEE(b)), 10))
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// apiError is an error returned to clients as a DynamoDB error.
+type apiError struct {
+	code    string // Without namespace, i.e. "ValidationException".
+	msg     string
+	reasons []dynamo.CancellationReason // Of TransactionCanceledException.
+}
+
+func (e *apiError) Error() string {
+	return e.code + ": " + e.msg
+}
+
+// body is the JSON DynamoDB returns for the error. It isn't a dynamo.Error, whose StatusCode the client sets itself.
+func (e *apiError) body() interface{} {
+	return struct {
+		Type                string                      `json:"__type"`
+		Message             string                      `json:"message"`
+		CancellationReasons []dynamo.CancellationReason `json:",omitempty"`
+	}{"com.amazonaws.dynamodb.v20120810#" + e.code, e.msg, e.reasons}
+}
+
+func validationError(format string, args ...interface{}) *apiError {
+	return &apiError{code: dynamo.ValidationException, msg: fmt.Sprintf(format, args...)}
+}
+
+func conditionFailed() *apiError {
+	return &apiError{code: dynamo.ConditionalCheckFailedException, msg: "The conditional request failed"}
+}
+
+func decode(body []byte, req interface{}) error {
+	if err := json.Unmarshal(body, req); err != nil {
+		return &apiError{code: "SerializationException", msg: err.Error()}
+	}
+	return nil
+}
+
+// table returns a table by name, or a ResourceNotFoundException.
+func (s *Server) table(name string) (*table, error) {
+	t, ok := s.tables[name]
+	if !ok {
+		return nil, &apiError{code: dynamo.ResourceNotFoundExcpetion, msg: "Requested resource not found: Table: " + name + " not found"}
+	}
+	return t, nil
+}
+
+// createTableRequest is dynamo.TableRequest with global secondary indexes.
+type createTableRequest struct {
+	dynamo.TableRequest
+	GlobalSecondaryIndexes []dynamo.GlobalSecondaryIndex
+}
+
+func (s *Server) createTable(body []byte) (interface{}, error) {
+	req := createTableRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	if _, ok := s.tables[req.TableName]; ok {
+		return nil, &apiError{code: "ResourceInUseException", msg: "Table already exists: " + req.TableName}
+	} else if err := dynamo.ValidateTableName(req.TableName); err != nil {
+		return nil, validationError("%s", err)
+	}
+	desc := dynamo.TableDescription{
+		TableName:              req.TableName,
+		TableStatus:            "ACTIVE",
+		CreationDateTime:       float64(s.clock().Unix()),
+		AttributeDefinitions:   req.AttributeDefinitions,
+		KeySchema:              req.KeySchema,
+		LocalSecondaryIndexes:  req.LocalSecondaryIndexes,
+		GlobalSecondaryIndexes: req.GlobalSecondaryIndexes,
+		ProvisionedThroughput:  req.ProvisionedThroughput,
+	}
+	for i := range desc.GlobalSecondaryIndexes {
+		desc.GlobalSecondaryIndexes[i].IndexStatus = "ACTIVE"
+	}
+	t := &table{desc: desc, items: map[string]dynamo.AttributeSet{}, ttl: dynamo.TimeToLiveDescription{TimeToLiveStatus: dynamo.TTLDisabled}}
+	if err := t.checkSchema(); err != nil {
+		return nil, err
+	}
+	if spec := req.StreamSpecification; spec != nil && spec.StreamEnabled {
+		if err := s.enableStream(t, spec.StreamViewType); err != nil {
+			return nil, err
+		}
+	}
+	s.tables[req.TableName] = t
+	return dynamo.TableDescriptionWrapper{Description: t.describe()}, nil
+}
+
+func (s *Server) describeTable(body []byte) (interface{}, error) {
+	req := dynamo.BasicRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	return struct{ Table dynamo.TableDescription }{t.describe()}, nil
+}
+
+func (s *Server) updateTable(body []byte) (interface{}, error) {
+	req := dynamo.TableRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if req.ProvisionedThroughput.ReadUnits > 0 {
+		t.desc.ProvisionedThroughput.ReadUnits = req.ProvisionedThroughput.ReadUnits
+	}
+	if req.ProvisionedThroughput.WriteUnits > 0 {
+		t.desc.ProvisionedThroughput.WriteUnits = req.ProvisionedThroughput.WriteUnits
+	}
+	if spec := req.StreamSpecification; spec != nil && spec.StreamEnabled {
+		err = s.enableStream(t, spec.StreamViewType)
+	} else if spec != nil {
+		err = s.disableStream(t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return struct{ TableDescription dynamo.TableDescription }{t.describe()}, nil
+}
+
+func (s *Server) deleteTable(body []byte) (interface{}, error) {
+	req := dynamo.BasicRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if t.stream != nil {
+		s.disableStream(t)
+	}
+	delete(s.tables, req.TableName)
+	desc := t.describe()
+	desc.TableStatus = "DELETING"
+	return struct{ TableDescription dynamo.TableDescription }{desc}, nil
+}
+
+func (s *Server) listTables(body []byte) (interface{}, error) {
+	req := dynamo.ListTablesRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 100
+	}
+	names := []string{}
+	for name := range s.tables {
+		if name > req.ExclusiveStartTableName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	res := dynamo.ListTablesResponse{TableNames: names}
+	if len(names) > req.Limit {
+		res.TableNames = names[:req.Limit]
+		res.LastEvaluatedTableName = names[req.Limit-1]
+	}
+	return res, nil
+}
+
+func (s *Server) updateTimeToLive(body []byte) (interface{}, error) {
+	req := dynamo.UpdateTimeToLiveRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	spec := req.TimeToLiveSpecification
+	enabled := t.ttl.TimeToLiveStatus == dynamo.TTLEnabled
+	switch {
+	case len(spec.AttributeName) == 0:
+		return nil, validationError("TimeToLiveSpecification.AttributeName must be given")
+	case spec.Enabled && enabled:
+		return nil, validationError("TimeToLive is already enabled on %s", t.ttl.AttributeName)
+	case !spec.Enabled && !enabled:
+		return nil, validationError("TimeToLive is already disabled")
+	case !spec.Enabled && spec.AttributeName != t.ttl.AttributeName:
+		return nil, validationError("TimeToLive is active on a different AttributeName: current AttributeName is %s", t.ttl.AttributeName)
+	}
+	t.ttl = dynamo.TimeToLiveDescription{TimeToLiveStatus: dynamo.TTLDisabled}
+	if spec.Enabled {
+		t.ttl = dynamo.TimeToLiveDescription{AttributeName: spec.AttributeName, TimeToLiveStatus: dynamo.TTLEnabled}
+	}
+	return dynamo.UpdateTimeToLiveResponse{TimeToLiveSpecification: spec}, nil
+}
+
+func (s *Server) describeTimeToLive(body []byte) (interface{}, error) {
+	req := dynamo.BasicRequest{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	t, err := s.table(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	return dynamo.DescribeTimeToLiveResponse{TimeToLiveDescription: t.ttl}, nil
+}
+
+// write stores item under key k, or deletes it if item is nil. Every write goes through it, adding its record to the
+// stream of the table.
+func (s *Server) write(t *table, k string, item dynamo.AttributeSet) {
+	s.writeAs(t, k, item, nil)
+}
+
+// writeAs writes with the user identity of the stream record, set for deletions by DynamoDB itself.
+func (s *Server) writeAs(t *table, k string, item dynamo.AttributeSet, identity *userIdentity) {
+	s.record(t, t.items[k], item, identity)
+	if item == nil {
+		delete(t.items, k)
+		return
+	}
+	t.items[k] = copyItem(item)
+}
+
+func copyItem(item dynamo.AttributeSet) dynamo.AttributeSet {
+	if item == nil {
+		return nil
+	}
+	c := make(dynamo.AttributeSet, len(item))
+	for name, val := range item {
+		c[name] = val
+	}
+	return c
+}
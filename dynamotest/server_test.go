@@ -0,0 +1,144 @@
+package dynamotest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/poptip/dynamo"
+)
+
+func newTestTable(t *testing.T, s *Server) *dynamo.Client {
+	c := s.Client()
+	if _, err := c.CreateTableSimple("things", "id", dynamo.TypeString, "n", dynamo.TypeNumber, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestItems(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := newTestTable(t, s)
+	key := dynamo.AttributeSet{"id": {S: "a"}, "n": {N: "1"}}
+	if err := c.PutItemRaw("things", dynamo.AttributeSet{"id": {S: "a"}, "n": {N: "1"}, "count": {N: "1.5"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateItemRaw("things", key, dynamo.AttributeSet{"count": {N: "2"}, "tags": {SS: []string{"x"}}}, dynamo.UpdateTypeAdd); err != nil {
+		t.Fatal(err)
+	}
+	item, err := c.GetItemRaw("things", key, true)
+	if err != nil {
+		t.Fatal(err)
+	} else if item["count"].N != "3.5" || len(item["tags"].SS) != 1 {
+		t.Errorf("Unexpected item %+v", item)
+	}
+
+	r, _ := c.NewRequestWithContent(dynamo.PutItemEndpoint, dynamo.PutRequest{
+		BasicRequest: dynamo.BasicRequest{TableName: "things"},
+		Item:         key,
+		Expected:     map[string]dynamo.ExpectedValue{"id": {Exists: false}},
+	})
+	if err := c.DoAndUnmarshal(r, &dynamo.UpdateResponse{}); !dynamo.IsErrorCode(err, dynamo.ConditionalCheckFailedException) {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if err := c.PutItemRaw("things", dynamo.AttributeSet{"id": {S: "a"}}); !dynamo.IsErrorCode(err, dynamo.ValidationException) {
+		t.Errorf("Put an item without its range key: %v", err)
+	}
+	if err := c.PutItemRaw("nothing", key); !dynamo.IsErrorCode(err, dynamo.ResourceNotFoundExcpetion) {
+		t.Errorf("Put an item in a missing table: %v", err)
+	} else if err.(*dynamo.Error).StatusCode != http.StatusBadRequest {
+		t.Errorf("Unexpected status code of %v", err)
+	}
+
+	if err := c.DeleteItemRaw("things", key); err != nil {
+		t.Fatal(err)
+	}
+	if item, err := c.GetItemRaw("things", key, true); err != nil || item != nil {
+		t.Errorf("Got deleted item %+v, %v", item, err)
+	}
+}
+
+func TestQueryScan(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.PageSize = 2
+	c := newTestTable(t, s)
+	for _, item := range []dynamo.AttributeSet{
+		{"id": {S: "a"}, "n": {N: "10"}},
+		{"id": {S: "a"}, "n": {N: "9"}, "odd": {S: "y"}},
+		{"id": {S: "a"}, "n": {N: "2"}},
+		{"id": {S: "a"}, "n": {N: "1"}, "odd": {S: "y"}},
+		{"id": {S: "b"}, "n": {N: "3"}, "odd": {S: "y"}},
+	} {
+		if err := c.PutItemRaw("things", item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := dynamo.Query{TableName: "things", ScanIndexForward: true, KeyConditions: map[string]dynamo.Condition{
+		"id": {AttributeValueList: []dynamo.AttributeVal{{S: "a"}}, ComparisonOperator: dynamo.ConditionEqual},
+		"n":  {AttributeValueList: []dynamo.AttributeVal{{N: "1"}}, ComparisonOperator: dynamo.ConditionGreaterThan},
+	}}
+	ns := []string{}
+	for pages := 0; ; pages++ {
+		items, last, err := c.RawQuery(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range items {
+			ns = append(ns, item["n"].N)
+		}
+		if last == nil {
+			break
+		} else if pages > 3 {
+			t.Fatal("Query didn't end")
+		}
+		q.ExclusiveStartKey = last
+	}
+	if len(ns) != 3 || ns[0] != "2" || ns[1] != "9" || ns[2] != "10" {
+		t.Errorf("Queried %v", ns)
+	}
+
+	scan := dynamo.ScanRequest{TableName: "things", ScanFilter: map[string]dynamo.Condition{
+		"odd": {ComparisonOperator: dynamo.ConditionAttributeExists},
+	}}
+	n := 0
+	for {
+		items, last, err := c.RawScan(scan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n += len(items)
+		if last == nil {
+			break
+		}
+		scan.ExclusiveStartKey = last
+	}
+	if n != 3 {
+		t.Errorf("Scanned %d odd items", n)
+	}
+}
+
+func TestListTables(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := s.Client()
+	for _, name := range []string{"ccc", "aaa", "bbb"} {
+		if _, err := c.CreateTableSimple(name, "id", dynamo.TypeString, "", "", 1, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, last, err := c.ListTables("", 2)
+	if err != nil || len(names) != 2 || names[1] != "bbb" || last != "bbb" {
+		t.Fatalf("Listed %v, %q, %v", names, last, err)
+	}
+	if names, last, _ = c.ListTables(last, 2); len(names) != 1 || names[0] != "ccc" || last != "" {
+		t.Errorf("Listed %v, %q", names, last)
+	}
+	if _, err := c.DeleteTable("aaa"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DescribeTable("aaa"); !dynamo.IsErrorCode(err, dynamo.ResourceNotFoundExcpetion) {
+		t.Errorf("Described deleted table: %v", err)
+	}
+}
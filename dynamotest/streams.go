@@ -0,0 +1,427 @@
+package dynamotest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/poptip/dynamo"
+)
+
+// The streams package isn't imported, as its tests use the server: the types below have the JSON of its own.
+
+const (
+	streamsBaseEndpoint = "DynamoDBStreams_20120810."
+
+	streamEnabled  = "ENABLED"
+	streamDisabled = "DISABLED"
+
+	iteratorLifetime = 15 * time.Minute
+	maxRecords       = 1000
+)
+
+// ttlIdentity is the user identity of the records of deletions by Expire.
+var ttlIdentity = &userIdentity{PrincipalId: "dynamodb.amazonaws.com", Type: "Service"}
+
+// stream is a stream of the changes of a table, made of shards of which only the last may be open.
+type stream struct {
+	desc   streamDescription // Without shards.
+	shards []*shard
+}
+
+type shard struct {
+	id, parent string
+	start, end int64 // Sequence numbers, end is 0 while the shard is open.
+	records    []streamRecord
+}
+
+type streamSummary struct {
+	StreamArn   string
+	StreamLabel string
+	TableName   string
+}
+
+type streamDescription struct {
+	StreamArn               string
+	StreamLabel             string
+	StreamStatus            string
+	StreamViewType          string
+	CreationRequestDateTime float64
+	TableName               string
+	KeySchema               []dynamo.Key
+	Shards                  []shardDescription
+	LastEvaluatedShardId    string `json:",omitempty"`
+}
+
+type shardDescription struct {
+	ShardId             string
+	ParentShardId       string `json:",omitempty"`
+	SequenceNumberRange sequenceNumberRange
+}
+
+type sequenceNumberRange struct {
+	StartingSequenceNumber string `json:",omitempty"`
+	EndingSequenceNumber   string `json:",omitempty"`
+}
+
+type streamRecord struct {
+	EventID      string        `json:"eventID"`
+	EventName    string        `json:"eventName"`
+	EventVersion string        `json:"eventVersion"`
+	EventSource  string        `json:"eventSource"`
+	AwsRegion    string        `json:"awsRegion"`
+	Dynamodb     recordChange  `json:"dynamodb"`
+	UserIdentity *userIdentity `json:"userIdentity,omitempty"`
+}
+
+type recordChange struct {
+	ApproximateCreationDateTime float64
+	Keys                        dynamo.AttributeSet
+	NewImage                    dynamo.AttributeSet `json:",omitempty"`
+	OldImage                    dynamo.AttributeSet `json:",omitempty"`
+	SequenceNumber              string
+	SizeBytes                   int64
+	StreamViewType              string
+}
+
+type userIdentity struct {
+	PrincipalId string
+	Type        string
+}
+
+// streamHandlers are the handlers of DynamoDB Streams requests.
+var streamHandlers map[string]func(s *Server, body []byte) (interface{}, error)
+
+func init() {
+	streamHandlers = map[string]func(s *Server, body []byte) (interface{}, error){
+		"ListStreams":      (*Server).listStreams,
+		"DescribeStream":   (*Server).describeStream,
+		"GetShardIterator": (*Server).getShardIterator,
+		"GetRecords":       (*Server).getRecords,
+	}
+}
+
+func sequenceNumber(n int64) string {
+	return fmt.Sprintf("%021d", n)
+}
+
+// RotateShard closes the open shard of the stream of a table and starts a child of it, as DynamoDB does every few
+// hours, so tests can check consumers read shards in order.
+func (s *Server) RotateShard(table string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tables[table]
+	if !ok {
+		return fmt.Errorf("No table %s", table)
+	} else if t.stream == nil {
+		return fmt.Errorf("Table %s has no stream enabled", table)
+	}
+	open := t.stream.shards[len(t.stream.shards)-1]
+	s.closeShard(open)
+	s.startShard(t.stream, open.id)
+	return nil
+}
+
+// enableStream starts a new stream of the changes of a table.
+func (s *Server) enableStream(t *table, viewType string) error {
+	switch viewType {
+	case dynamo.StreamViewKeysOnly, dynamo.StreamViewNewImage, dynamo.StreamViewOldImage, dynamo.StreamViewNewAndOldImages:
+	default:
+		return validationError("Invalid StreamViewType: %s", viewType)
+	}
+	if t.stream != nil {
+		return validationError("Table already has an enabled stream: %s", t.stream.desc.StreamArn)
+	}
+	now := s.clock().UTC()
+	label := now.Format("2006-01-02T15:04:05.000")
+	for s.findStream(streamArn(t.desc.TableName, label)) != nil {
+		now = now.Add(time.Millisecond)
+		label = now.Format("2006-01-02T15:04:05.000")
+	}
+	st := &stream{desc: streamDescription{
+		StreamArn:               streamArn(t.desc.TableName, label),
+		StreamLabel:             label,
+		StreamStatus:            streamEnabled,
+		StreamViewType:          viewType,
+		CreationRequestDateTime: float64(now.Unix()),
+		TableName:               t.desc.TableName,
+		KeySchema:               t.desc.KeySchema,
+	}}
+	s.startShard(st, "")
+	s.streams = append(s.streams, st)
+	t.stream = st
+	t.desc.StreamSpecification = &dynamo.StreamSpecification{StreamEnabled: true, StreamViewType: viewType}
+	t.desc.LatestStreamArn, t.desc.LatestStreamLabel = st.desc.StreamArn, label
+	return nil
+}
+
+// disableStream disables the stream of a table, closing its shard. It stays readable.
+func (s *Server) disableStream(t *table) error {
+	if t.stream == nil {
+		return validationError("Table %s has no enabled stream", t.desc.TableName)
+	}
+	t.stream.desc.StreamStatus = streamDisabled
+	s.closeShard(t.stream.shards[len(t.stream.shards)-1])
+	t.stream = nil
+	t.desc.StreamSpecification = &dynamo.StreamSpecification{StreamEnabled: false}
+	return nil
+}
+
+func streamArn(table, label string) string {
+	return "arn:aws:dynamodb:us-east-1:000000000000:table/" + table + "/stream/" + label
+}
+
+func (s *Server) startShard(st *stream, parent string) {
+	s.shards++
+	st.shards = append(st.shards, &shard{
+		id:     fmt.Sprintf("shardId-%020d-%08d", s.clock().UnixNano()/int64(time.Millisecond), s.shards),
+		parent: parent,
+		start:  s.seq + 1,
+	})
+}
+
+func (s *Server) closeShard(sh *shard) {
+	sh.end = s.seq
+	if sh.end < sh.start {
+		sh.end = sh.start // An empty shard.
+	}
+}
+
+func (s *Server) findStream(arn string) *stream {
+	for _, st := range s.streams {
+		if st.desc.StreamArn == arn {
+			return st
+		}
+	}
+	return nil
+}
+
+func (s *Server) streamOf(arn string) (*stream, error) {
+	if st := s.findStream(arn); st != nil {
+		return st, nil
+	}
+	return nil, &apiError{code: dynamo.ResourceNotFoundExcpetion, msg: "Requested resource not found: Stream: " + arn + " not found"}
+}
+
+func (st *stream) shard(id string) (*shard, error) {
+	for _, sh := range st.shards {
+		if sh.id == id {
+			return sh, nil
+		}
+	}
+	return nil, &apiError{code: dynamo.ResourceNotFoundExcpetion, msg: "Requested resource not found: Shard: " + id + " not found"}
+}
+
+// record appends the record of a change of an item from old to item to the stream of the table, if it has one. Items
+// written unchanged have no record.
+func (s *Server) record(t *table, old, item dynamo.AttributeSet, identity *userIdentity) {
+	if t.stream == nil || (old == nil && item == nil) || equalItems(old, item) {
+		return
+	}
+	view := t.stream.desc.StreamViewType
+	s.seq++
+	change := recordChange{
+		ApproximateCreationDateTime: float64(s.clock().Unix()),
+		SequenceNumber:              sequenceNumber(s.seq),
+		StreamViewType:              view,
+	}
+	r := streamRecord{
+		EventID:      fmt.Sprintf("%032x", s.seq),
+		EventVersion: "1.1",
+		EventSource:  "aws:dynamodb",
+		AwsRegion:    "us-east-1",
+		UserIdentity: identity,
+	}
+	switch {
+	case old == nil:
+		r.EventName, change.Keys = "INSERT", t.keyOf(item, nil)
+	case item == nil:
+		r.EventName, change.Keys = "REMOVE", t.keyOf(old, nil)
+	default:
+		r.EventName, change.Keys = "MODIFY", t.keyOf(item, nil)
+	}
+	if view == dynamo.StreamViewNewImage || view == dynamo.StreamViewNewAndOldImages {
+		change.NewImage = copyItem(item)
+	}
+	if view == dynamo.StreamViewOldImage || view == dynamo.StreamViewNewAndOldImages {
+		change.OldImage = copyItem(old)
+	}
+	change.SizeBytes = int64(dynamo.ItemSize(change.Keys) + dynamo.ItemSize(change.NewImage) + dynamo.ItemSize(change.OldImage))
+	r.Dynamodb = change
+	sh := t.stream.shards[len(t.stream.shards)-1]
+	sh.records = append(sh.records, r)
+}
+
+func equalItems(a, b dynamo.AttributeSet) bool {
+	if a == nil || b == nil || len(a) != len(b) {
+		return false
+	}
+	for name, val := range a {
+		if other, ok := b[name]; !ok || !equalValues(val, other) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) listStreams(body []byte) (interface{}, error) {
+	req := struct {
+		TableName               string
+		ExclusiveStartStreamArn string
+		Limit                   int
+	}{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 100
+	}
+	res := struct {
+		Streams                []streamSummary
+		LastEvaluatedStreamArn string `json:",omitempty"`
+	}{Streams: []streamSummary{}}
+	started := len(req.ExclusiveStartStreamArn) == 0
+	for _, st := range s.streams {
+		if !started {
+			started = st.desc.StreamArn == req.ExclusiveStartStreamArn
+			continue
+		} else if len(req.TableName) > 0 && st.desc.TableName != req.TableName {
+			continue
+		} else if len(res.Streams) == req.Limit {
+			res.LastEvaluatedStreamArn = res.Streams[len(res.Streams)-1].StreamArn
+			break
+		}
+		res.Streams = append(res.Streams, streamSummary{st.desc.StreamArn, st.desc.StreamLabel, st.desc.TableName})
+	}
+	return res, nil
+}
+
+func (s *Server) describeStream(body []byte) (interface{}, error) {
+	req := struct {
+		StreamArn             string
+		ExclusiveStartShardId string
+		Limit                 int
+	}{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	st, err := s.streamOf(req.StreamArn)
+	if err != nil {
+		return nil, err
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 100
+	}
+	desc := st.desc
+	desc.Shards = []shardDescription{}
+	started := len(req.ExclusiveStartShardId) == 0
+	for _, sh := range st.shards {
+		if !started {
+			started = sh.id == req.ExclusiveStartShardId
+			continue
+		} else if len(desc.Shards) == req.Limit {
+			desc.LastEvaluatedShardId = desc.Shards[len(desc.Shards)-1].ShardId
+			break
+		}
+		d := shardDescription{ShardId: sh.id, ParentShardId: sh.parent}
+		d.SequenceNumberRange.StartingSequenceNumber = sequenceNumber(sh.start)
+		if sh.end > 0 {
+			d.SequenceNumberRange.EndingSequenceNumber = sequenceNumber(sh.end)
+		}
+		desc.Shards = append(desc.Shards, d)
+	}
+	return struct{ StreamDescription streamDescription }{desc}, nil
+}
+
+func (s *Server) getShardIterator(body []byte) (interface{}, error) {
+	req := struct {
+		StreamArn         string
+		ShardId           string
+		ShardIteratorType string
+		SequenceNumber    string
+	}{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	st, err := s.streamOf(req.StreamArn)
+	if err != nil {
+		return nil, err
+	}
+	sh, err := st.shard(req.ShardId)
+	if err != nil {
+		return nil, err
+	}
+	i := 0
+	switch req.ShardIteratorType {
+	case "TRIM_HORIZON":
+	case "LATEST":
+		i = len(sh.records)
+	case "AT_SEQUENCE_NUMBER", "AFTER_SEQUENCE_NUMBER":
+		seq, err := strconv.ParseInt(req.SequenceNumber, 10, 64)
+		if err != nil || seq < sh.start || (sh.end > 0 && seq > sh.end) {
+			return nil, validationError("Invalid SequenceNumber %s for shard %s", req.SequenceNumber, sh.id)
+		}
+		after := req.ShardIteratorType == "AFTER_SEQUENCE_NUMBER"
+		for i < len(sh.records) {
+			n, _ := strconv.ParseInt(sh.records[i].Dynamodb.SequenceNumber, 10, 64)
+			if n > seq || (n == seq && !after) {
+				break
+			}
+			i++
+		}
+	default:
+		return nil, validationError("Invalid ShardIteratorType: %s", req.ShardIteratorType)
+	}
+	return struct{ ShardIterator string }{s.iterator(st, sh, i)}, nil
+}
+
+// iterator returns an iterator of the records of a shard from index i. It expires after 15 minutes on the server's
+// clock.
+func (s *Server) iterator(st *stream, sh *shard, i int) string {
+	it := fmt.Sprintf("%s|%s|%d|%d", st.desc.StreamArn, sh.id, i, s.clock().UnixNano())
+	return base64.URLEncoding.EncodeToString([]byte(it))
+}
+
+func (s *Server) getRecords(body []byte) (interface{}, error) {
+	req := struct {
+		ShardIterator string
+		Limit         int
+	}{}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	b, err := base64.URLEncoding.DecodeString(req.ShardIterator)
+	parts := strings.Split(string(b), "|")
+	if err != nil || len(parts) != 4 {
+		return nil, validationError("Invalid ShardIterator")
+	}
+	i, _ := strconv.Atoi(parts[2])
+	issued, _ := strconv.ParseInt(parts[3], 10, 64)
+	if s.clock().Sub(time.Unix(0, issued)) > iteratorLifetime {
+		return nil, &apiError{code: "ExpiredIteratorException", msg: "Iterator expired"}
+	}
+	st, err := s.streamOf(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	sh, err := st.shard(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if req.Limit <= 0 || req.Limit > maxRecords {
+		req.Limit = maxRecords
+	}
+	res := struct {
+		Records           []streamRecord
+		NextShardIterator string `json:",omitempty"`
+	}{Records: []streamRecord{}}
+	for ; i < len(sh.records) && len(res.Records) < req.Limit; i++ {
+		res.Records = append(res.Records, sh.records[i])
+	}
+	if sh.end == 0 || i < len(sh.records) {
+		res.NextShardIterator = s.iterator(st, sh, i)
+	}
+	return res, nil
+}
@@ -0,0 +1,149 @@
+package dynamotest
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/poptip/dynamo"
+	"github.com/poptip/dynamo/streams"
+)
+
+// readStream returns the records of every shard of the latest stream of a table.
+func readStream(t *testing.T, s *Server, table string) []streams.Record {
+	c := s.Client()
+	desc, err := c.DescribeTable(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := streams.New(c)
+	stream, err := sc.DescribeStream(desc.LatestStreamArn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := []streams.Record{}
+	for _, shard := range streams.Lineage(stream.Shards) {
+		it, err := sc.GetShardIterator(stream.StreamArn, shard.ShardId, streams.TrimHorizon, "")
+		for err == nil && len(it) > 0 {
+			var page []streams.Record
+			if page, it, err = sc.GetRecords(it, 2); len(page) == 0 && shard.Open() {
+				break
+			}
+			records = append(records, page...)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return records
+}
+
+func TestStreamViews(t *testing.T) {
+	for _, view := range []string{dynamo.StreamViewKeysOnly, dynamo.StreamViewNewImage, dynamo.StreamViewOldImage, dynamo.StreamViewNewAndOldImages} {
+		s := NewServer()
+		defer s.Close()
+		c := newTestTable(t, s)
+		if err := c.EnableStream("things", view); err != nil {
+			t.Fatal(err)
+		}
+		key := dynamo.AttributeSet{"id": {S: "a"}, "n": {N: "1"}}
+		c.PutItemRaw("things", dynamo.AttributeSet{"id": {S: "a"}, "n": {N: "1"}, "count": {N: "1"}})
+		c.PutItemRaw("things", dynamo.AttributeSet{"id": {S: "a"}, "n": {N: "1"}, "count": {N: "1"}}) // Unchanged.
+		c.UpdateItemRaw("things", key, dynamo.AttributeSet{"count": {N: "1"}}, dynamo.UpdateTypeAdd)
+		c.DeleteItemRaw("things", key)
+		c.BatchWriteRaw("things", []dynamo.RequestItem{
+			{PutRequest: &dynamo.PutRequest{Item: dynamo.AttributeSet{"id": {S: "b"}, "n": {N: "2"}}}},
+			{DeleteRequest: &dynamo.DeleteRequest{Key: key}}, // Missing.
+		})
+
+		records := readStream(t, s, "things")
+		events := ""
+		for _, r := range records {
+			events += r.EventName[:1]
+			if r.Dynamodb.StreamViewType != view || len(r.Dynamodb.Keys) != 2 {
+				t.Errorf("%s record %+v", view, r)
+			}
+		}
+		if events != "IMRI" {
+			t.Fatalf("%s events %s", view, events)
+		}
+		modify := records[1].Dynamodb
+		hasNew, hasOld := modify.NewImage["count"].N == "2", modify.OldImage["count"].N == "1"
+		if wantNew := view == dynamo.StreamViewNewImage || view == dynamo.StreamViewNewAndOldImages; hasNew != wantNew {
+			t.Errorf("%s new image %v", view, modify.NewImage)
+		}
+		if wantOld := view == dynamo.StreamViewOldImage || view == dynamo.StreamViewNewAndOldImages; hasOld != wantOld {
+			t.Errorf("%s old image %v", view, modify.OldImage)
+		}
+	}
+}
+
+func TestStreamShards(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := newTestTable(t, s)
+	if err := s.RotateShard("things"); err == nil {
+		t.Error("Rotated the shard of a table without stream")
+	}
+	if err := c.EnableStream("things", dynamo.StreamViewNewImage); err != nil {
+		t.Fatal(err)
+	} else if err := c.EnableStream("things", dynamo.StreamViewNewImage); !dynamo.IsErrorCode(err, dynamo.ValidationException) {
+		t.Errorf("Enabled an enabled stream: %v", err)
+	}
+	c.PutItemRaw("things", dynamo.AttributeSet{"id": {S: "a"}, "n": {N: "1"}})
+	if err := s.RotateShard("things"); err != nil {
+		t.Fatal(err)
+	}
+	c.PutItemRaw("things", dynamo.AttributeSet{"id": {S: "a"}, "n": {N: "2"}})
+	c.PutItemRaw("things", dynamo.AttributeSet{"id": {S: "a"}, "n": {N: "3"}})
+
+	sc := streams.New(c)
+	list, err := sc.ListStreams("things")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("Listed %+v, %v", list, err)
+	}
+	desc, err := sc.DescribeStream(list[0].StreamArn)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(desc.Shards) != 2 || desc.Shards[0].Open() || desc.Shards[1].ParentShardId != desc.Shards[0].ShardId || desc.StreamStatus != streams.StreamEnabled {
+		t.Fatalf("Described %+v", desc)
+	}
+	child := desc.Shards[1].ShardId
+	records := readStream(t, s, "things")
+	if len(records) != 3 {
+		t.Fatalf("Read %+v", records)
+	}
+	it, err := sc.GetShardIterator(desc.StreamArn, child, streams.AfterSequenceNumber, records[1].Dynamodb.SequenceNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page, _, err := sc.GetRecords(it, 0); err != nil || len(page) != 1 || page[0].Dynamodb.NewImage["n"].N != "3" {
+		t.Errorf("Read %+v, %v", page, err)
+	}
+	if it, err = sc.GetShardIterator(desc.StreamArn, child, streams.Latest, ""); err != nil {
+		t.Fatal(err)
+	}
+	s.Advance(time.Hour)
+	if _, _, err := sc.GetRecords(it, 0); !dynamo.IsErrorCode(err, streams.ExpiredIteratorException) {
+		t.Errorf("Read an expired iterator: %v", err)
+	}
+
+	expires := strconv.FormatInt(s.Now().Unix(), 10)
+	c.PutItemRaw("things", dynamo.AttributeSet{"id": {S: "b"}, "n": {N: "1"}, "expires": {N: expires}})
+	if err := c.EnableTTL("things", "expires"); err != nil {
+		t.Fatal(err)
+	} else if n := s.Expire(); n != 1 {
+		t.Errorf("Expired %d items", n)
+	}
+	if err := c.DisableStream("things"); err != nil {
+		t.Fatal(err)
+	}
+	c.PutItemRaw("things", dynamo.AttributeSet{"id": {S: "a"}, "n": {N: "4"}})
+	records = readStream(t, s, "things")
+	if len(records) != 5 || records[3].Expired() || !records[4].Expired() {
+		t.Errorf("Read %+v", records)
+	}
+	if desc, _ = sc.DescribeStream(desc.StreamArn); desc.StreamStatus != streams.StreamDisabled || desc.Shards[1].Open() {
+		t.Errorf("Described %+v", desc)
+	}
+}
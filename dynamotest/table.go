@@ -0,0 +1,390 @@
+package dynamotest
+
+import (
+	"bytes"
+	"hash/crc32"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/poptip/dynamo"
+)
+
+// table is a table and its items, keyed by the string of their primary key, see key.
+type table struct {
+	desc   dynamo.TableDescription
+	items  map[string]dynamo.AttributeSet
+	ttl    dynamo.TimeToLiveDescription
+	stream *stream // Enabled, nil if none.
+}
+
+func (t *table) describe() dynamo.TableDescription {
+	desc := t.desc
+	desc.ItemCount = len(t.items)
+	desc.TableSizeBytes = 0
+	for _, item := range t.items {
+		desc.TableSizeBytes += int64(dynamo.ItemSize(item))
+	}
+	return desc
+}
+
+// checkSchema checks the key schemas of the table and its indexes only use defined attributes.
+func (t *table) checkSchema() error {
+	types := t.types()
+	schemas := [][]dynamo.Key{t.desc.KeySchema}
+	for _, idx := range t.desc.LocalSecondaryIndexes {
+		schemas = append(schemas, idx.KeySchema)
+	}
+	for _, idx := range t.desc.GlobalSecondaryIndexes {
+		schemas = append(schemas, idx.KeySchema)
+	}
+	for _, schema := range schemas {
+		if hash, _ := keyNames(schema); len(hash) == 0 {
+			return validationError("No hash key in key schema")
+		}
+		for _, k := range schema {
+			if _, ok := types[k.Name]; !ok {
+				return validationError("One or more parameter values were invalid: Key attribute %s isn't defined", k.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (t *table) types() map[string]string {
+	types := map[string]string{}
+	for _, def := range t.desc.AttributeDefinitions {
+		types[def.Name] = def.Type
+	}
+	return types
+}
+
+// keyNames returns the hash and range keys of a schema.
+func keyNames(schema []dynamo.Key) (hash, rng string) {
+	for _, k := range schema {
+		if k.Type == dynamo.TypeHashKey {
+			hash = k.Name
+		} else {
+			rng = k.Name
+		}
+	}
+	return hash, rng
+}
+
+// index returns the key schema and projection of an index of the table, or of the table if name is empty.
+func (t *table) index(name string) ([]dynamo.Key, *dynamo.IndexProjection, error) {
+	if len(name) == 0 {
+		return t.desc.KeySchema, nil, nil
+	}
+	for _, idx := range t.desc.LocalSecondaryIndexes {
+		if idx.IndexName == name {
+			return idx.KeySchema, &idx.Projection, nil
+		}
+	}
+	for _, idx := range t.desc.GlobalSecondaryIndexes {
+		if idx.IndexName == name {
+			return idx.KeySchema, &idx.Projection, nil
+		}
+	}
+	return nil, nil, validationError("The table does not have the specified index: %s", name)
+}
+
+// key checks item has the key attributes of the table with their defined types, returning the string identifying it.
+// If exact, item must have no other attributes, as for the keys of GetItem or DeleteItem.
+func (t *table) key(item dynamo.AttributeSet, exact bool) (string, error) {
+	types := t.types()
+	parts := []string{}
+	for _, k := range t.desc.KeySchema {
+		val, ok := item[k.Name]
+		if !ok {
+			return "", validationError("One or more parameter values were invalid: Missing the key %s in the item", k.Name)
+		} else if typ := valueType(val); typ != types[k.Name] {
+			return "", validationError("One or more parameter values were invalid: Type mismatch for key %s expected: %s actual: %s", k.Name, types[k.Name], typ)
+		}
+		parts = append(parts, valueString(val))
+	}
+	if exact && len(item) != len(t.desc.KeySchema) {
+		return "", validationError("The provided key element does not match the schema")
+	}
+	return strings.Join(parts, "\x00"), nil
+}
+
+// keyOf returns the attributes of item that are keys of the table or of schema.
+func (t *table) keyOf(item dynamo.AttributeSet, schema []dynamo.Key) dynamo.AttributeSet {
+	key := dynamo.AttributeSet{}
+	for _, k := range append(append([]dynamo.Key{}, t.desc.KeySchema...), schema...) {
+		if val, ok := item[k.Name]; ok {
+			key[k.Name] = val
+		}
+	}
+	return key
+}
+
+func (t *table) isKey(name string) bool {
+	for _, k := range t.desc.KeySchema {
+		if k.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// project returns the attributes of item an index projects, all of them if projection is nil, restricted to names if
+// any are given.
+func (t *table) project(item dynamo.AttributeSet, schema []dynamo.Key, projection *dynamo.IndexProjection, names []string) dynamo.AttributeSet {
+	if projection != nil && projection.ProjectionType != dynamo.ProjectionAll {
+		projected := t.keyOf(item, schema)
+		for _, name := range projection.NonKeyAttributes {
+			if val, ok := item[name]; ok {
+				projected[name] = val
+			}
+		}
+		item = projected
+	}
+	if len(names) == 0 {
+		return copyItem(item)
+	}
+	projected := dynamo.AttributeSet{}
+	for _, name := range names {
+		if val, ok := item[name]; ok {
+			projected[name] = val
+		}
+	}
+	return projected
+}
+
+// sorted returns the items of the table in the order of less, only those with the hash key of schema equal to hash
+// if it's not nil. Items without the keys of schema aren't in its index.
+func (t *table) sorted(schema []dynamo.Key, hash *dynamo.AttributeVal, less func(a, b dynamo.AttributeSet) bool) []dynamo.AttributeSet {
+	hashName, rangeName := keyNames(schema)
+	items := []dynamo.AttributeSet{}
+	for _, item := range t.items {
+		if _, ok := item[hashName]; !ok {
+			continue
+		} else if _, ok := item[rangeName]; len(rangeName) > 0 && !ok {
+			continue
+		} else if hash != nil && !equalValues(item[hashName], *hash) {
+			continue
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return less(items[i], items[j]) })
+	return items
+}
+
+// order returns the order of the items of queries on the range key rangeName, descending if !forward, or of scans if
+// rangeName is empty. Items with the same range key are ordered by their primary key.
+func (t *table) order(rangeName string, forward bool) func(a, b dynamo.AttributeSet) bool {
+	return func(a, b dynamo.AttributeSet) bool {
+		if len(rangeName) > 0 {
+			if c, _ := compareValues(a[rangeName], b[rangeName]); c != 0 {
+				return (c < 0) == forward
+			}
+		}
+		ka, kb := keyString(t.keyOf(a, nil)), keyString(t.keyOf(b, nil))
+		if ka == kb {
+			return false
+		}
+		return (ka < kb) == forward
+	}
+}
+
+// segment returns the segment of a parallel scan an item belongs to.
+func (t *table) segment(item dynamo.AttributeSet, total int) int {
+	if total <= 1 {
+		return 0
+	}
+	hash, _ := keyNames(t.desc.KeySchema)
+	return int(crc32.ChecksumIEEE([]byte(valueString(item[hash]))) % uint32(total))
+}
+
+func valueType(val dynamo.AttributeVal) string {
+	switch {
+	case len(val.N) > 0:
+		return dynamo.TypeNumber
+	case len(val.B) > 0:
+		return dynamo.TypeBinary
+	case len(val.SS) > 0:
+		return dynamo.TypeStringSet
+	case len(val.NS) > 0:
+		return dynamo.TypeNumberSet
+	case len(val.BS) > 0:
+		return dynamo.TypeBinarySet
+	}
+	return dynamo.TypeString
+}
+
+// valueString returns a string identifying a scalar value, the same for numbers that are equal.
+func valueString(val dynamo.AttributeVal) string {
+	switch {
+	case len(val.N) > 0:
+		if r, ok := new(big.Rat).SetString(val.N); ok {
+			return "N" + r.RatString()
+		}
+		return "N" + val.N
+	case len(val.B) > 0:
+		return "B" + val.B
+	}
+	return "S" + val.S
+}
+
+// keyString returns a string identifying a key.
+func keyString(key dynamo.AttributeSet) string {
+	names := make([]string, 0, len(key))
+	for name := range key {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b := &bytes.Buffer{}
+	for _, name := range names {
+		b.WriteString(name + "\x00" + valueString(key[name]) + "\x00")
+	}
+	return b.String()
+}
+
+// compareValues compares scalars of the same type: numbers by value, strings and binaries byte by byte. ok is false
+// if they can't be compared.
+func compareValues(a, b dynamo.AttributeVal) (c int, ok bool) {
+	switch ta, tb := valueType(a), valueType(b); {
+	case ta != tb:
+		return 0, false
+	case ta == dynamo.TypeNumber:
+		ra, okA := new(big.Rat).SetString(a.N)
+		rb, okB := new(big.Rat).SetString(b.N)
+		if !okA || !okB {
+			return 0, false
+		}
+		return ra.Cmp(rb), true
+	case ta == dynamo.TypeString:
+		return strings.Compare(a.S, b.S), true
+	case ta == dynamo.TypeBinary:
+		return strings.Compare(a.B, b.B), true
+	}
+	return 0, false
+}
+
+// equalValues reports whether values are equal, sets regardless of order.
+func equalValues(a, b dynamo.AttributeVal) bool {
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	} else if valueType(a) != valueType(b) {
+		return false
+	}
+	as, bs := setMembers(a), setMembers(b)
+	if len(as) != len(bs) {
+		return false
+	}
+	for m := range as {
+		if !bs[m] {
+			return false
+		}
+	}
+	return true
+}
+
+// setMembers returns the strings identifying the members of a set.
+func setMembers(val dynamo.AttributeVal) map[string]bool {
+	members := map[string]bool{}
+	for _, s := range val.SS {
+		members[valueString(dynamo.AttributeVal{S: s})] = true
+	}
+	for _, n := range val.NS {
+		members[valueString(dynamo.AttributeVal{N: n})] = true
+	}
+	for _, b := range val.BS {
+		members[valueString(dynamo.AttributeVal{B: b})] = true
+	}
+	return members
+}
+
+// Number of values of the comparison operators, -1 for any number but zero.
+var operatorArgs = map[string]int{
+	dynamo.ConditionEqual: 1, dynamo.ConditionNotEqual: 1, dynamo.ConditionLessThan: 1,
+	dynamo.ConditionLessThanOrEqual: 1, dynamo.ConditionGreaterThan: 1, dynamo.ConditionGreaterThanOrEqual: 1,
+	dynamo.ConditionBeginsWith: 1, dynamo.ConditionBetween: 2, dynamo.ConditionContains: 1,
+	dynamo.ConditionNotContains: 1, dynamo.ConditionAttributeExists: 0, dynamo.ConditionAttributeNotExists: 0,
+	dynamo.ConditionIn: -1,
+}
+
+func checkCondition(name string, cond dynamo.Condition) error {
+	n, ok := operatorArgs[cond.ComparisonOperator]
+	if !ok {
+		return validationError("Unknown comparison operator %s", cond.ComparisonOperator)
+	} else if (n >= 0 && len(cond.AttributeValueList) != n) || (n < 0 && len(cond.AttributeValueList) == 0) {
+		return validationError("One or more parameter values were invalid: Invalid number of argument(s) for the %s ComparisonOperator on %s", cond.ComparisonOperator, name)
+	}
+	return nil
+}
+
+// matches reports whether the attribute name of item satisfies cond, which must have been checked.
+func matches(item dynamo.AttributeSet, name string, cond dynamo.Condition) bool {
+	val, exists := item[name]
+	args := cond.AttributeValueList
+	compare := func(arg dynamo.AttributeVal) (int, bool) {
+		if !exists {
+			return 0, false
+		}
+		return compareValues(val, arg)
+	}
+	switch cond.ComparisonOperator {
+	case dynamo.ConditionEqual:
+		return exists && equalValues(val, args[0])
+	case dynamo.ConditionNotEqual:
+		return !exists || !equalValues(val, args[0])
+	case dynamo.ConditionLessThan:
+		c, ok := compare(args[0])
+		return ok && c < 0
+	case dynamo.ConditionLessThanOrEqual:
+		c, ok := compare(args[0])
+		return ok && c <= 0
+	case dynamo.ConditionGreaterThan:
+		c, ok := compare(args[0])
+		return ok && c > 0
+	case dynamo.ConditionGreaterThanOrEqual:
+		c, ok := compare(args[0])
+		return ok && c >= 0
+	case dynamo.ConditionBetween:
+		low, okLow := compare(args[0])
+		high, okHigh := compare(args[1])
+		return okLow && okHigh && low >= 0 && high <= 0
+	case dynamo.ConditionBeginsWith:
+		return exists && ((len(val.S) > 0 && len(args[0].S) > 0 && strings.HasPrefix(val.S, args[0].S)) ||
+			(len(val.B) > 0 && len(args[0].B) > 0 && strings.HasPrefix(val.B, args[0].B)))
+	case dynamo.ConditionContains:
+		return exists && contains(val, args[0])
+	case dynamo.ConditionNotContains:
+		return exists && !contains(val, args[0])
+	case dynamo.ConditionAttributeExists:
+		return exists
+	case dynamo.ConditionAttributeNotExists:
+		return !exists
+	case dynamo.ConditionIn:
+		for _, arg := range args {
+			if exists && equalValues(val, arg) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// contains reports whether a string has a substring or a set has a member.
+func contains(val, arg dynamo.AttributeVal) bool {
+	switch {
+	case len(val.S) > 0:
+		return len(arg.S) > 0 && strings.Contains(val.S, arg.S)
+	case len(val.B) > 0:
+		return len(arg.B) > 0 && strings.Contains(val.B, arg.B)
+	}
+	return setMembers(val)[valueString(arg)]
+}
+
+// matchesAll reports whether item satisfies every condition.
+func matchesAll(item dynamo.AttributeSet, conds map[string]dynamo.Condition) bool {
+	for name, cond := range conds {
+		if !matches(item, name, cond) {
+			return false
+		}
+	}
+	return true
+}
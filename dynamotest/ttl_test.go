@@ -0,0 +1,58 @@
+package dynamotest
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/poptip/dynamo"
+)
+
+func TestExpire(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := newTestTable(t, s)
+	now := time.Unix(1500000000, 0)
+	s.SetNow(now)
+	for i, ttl := range []time.Duration{time.Minute, time.Hour} {
+		item := dynamo.AttributeSet{"id": {S: "a"}, "n": {N: strconv.Itoa(i + 1)}, "expires": {N: strconv.FormatInt(now.Add(ttl).Unix(), 10)}}
+		if err := c.PutItemRaw("things", item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.DisableTTL("things", "expires"); !dynamo.IsErrorCode(err, dynamo.ValidationException) {
+		t.Errorf("Disabled a disabled TTL: %v", err)
+	}
+	if err := c.EnableTTL("things", "expires"); err != nil {
+		t.Fatal(err)
+	}
+	if desc, err := c.DescribeTTL("things"); err != nil || desc.AttributeName != "expires" || desc.TimeToLiveStatus != dynamo.TTLEnabled {
+		t.Errorf("Described %+v, %v", desc, err)
+	}
+
+	s.Advance(10 * time.Minute)
+	if !s.Now().Equal(now.Add(10 * time.Minute)) {
+		t.Errorf("Clock is at %v", s.Now())
+	}
+	items, _, err := c.RawScan(dynamo.ScanRequest{TableName: "things"})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(items) != 2 || len(dynamo.FilterExpired(items, "expires", s.Now())) != 1 {
+		t.Errorf("Scanned %v", items)
+	}
+	if n := s.Expire(); n != 1 {
+		t.Errorf("Expired %d items", n)
+	}
+	if items, _, _ = c.RawScan(dynamo.ScanRequest{TableName: "things"}); len(items) != 1 || items[0]["n"].N != "2" {
+		t.Errorf("Scanned %v", items)
+	}
+
+	if err := c.DisableTTL("things", "expires"); err != nil {
+		t.Fatal(err)
+	}
+	s.Advance(time.Hour)
+	if n := s.Expire(); n != 0 {
+		t.Errorf("Expired %d items with TTL disabled", n)
+	}
+}
@@ -0,0 +1,321 @@
+// Package export dumps DynamoDB tables to JSON Lines, CSV or DynamoDB-JSON.
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/poptip/dynamo"
+)
+
+const (
+	maxThrottleRetries = 5
+	throttleBackoff    = 500 * time.Millisecond
+)
+
+// Options configure an export. The zero value writes JSON Lines from a single, unlimited scan.
+type Options struct {
+	Format       Format   // Defaults to JSONLines.
+	Columns      []Column // Required for CSV.
+	Compress     bool     // Gzip the output.
+	Segments     int      // Scanned in parallel, defaults to 1.
+	PageSize     int      // Items per Scan request, by default as many as fit in DynamoDB's 1MB limit.
+	ReadFraction float64  // Of the table's provisioned read throughput to use, unlimited if 0. Leaves the client's own rate limiter alone.
+
+	// Checkpoint is a file recording the progress of the export after every page, with the length of the output
+	// written up to it. If it exists when the export starts, the export resumes where it stopped, writing after
+	// that length: ToFile truncates the file to it, dropping what was written after the checkpoint. It's removed
+	// once the export completes.
+	Checkpoint string
+
+	Progress func(Progress) // Called after every page, never concurrently.
+}
+
+// Progress of an export.
+type Progress struct {
+	Table        string
+	Items        int64
+	Pages        int64
+	Segments     int
+	SegmentsDone int
+	Elapsed      time.Duration
+}
+
+type checkpoint struct {
+	Table    string
+	Format   Format
+	Items    int64
+	Pages    int64
+	Offset   int64 // Bytes of output written up to the checkpoint.
+	Segments []segment
+}
+
+type segment struct {
+	LastKey dynamo.AttributeSet `json:",omitempty"`
+	Done    bool
+}
+
+// Table scans table and writes its items to w. When resuming from a checkpoint, w must continue the output at the
+// checkpoint's offset, as ToFile does.
+func Table(c *dynamo.Client, table string, w io.Writer, opts Options) (Progress, error) {
+	if len(opts.Format) == 0 {
+		opts.Format = JSONLines
+	}
+	if opts.Segments <= 0 {
+		opts.Segments = 1
+	}
+	e := &exporter{c: c, table: table, opts: opts, start: time.Now()}
+	resumed, err := e.load()
+	if err != nil {
+		return Progress{Table: table}, err
+	}
+	if opts.ReadFraction > 0 {
+		l, err := c.ThroughputLimiter(table, opts.ReadFraction)
+		if err != nil {
+			return e.progress(), err
+		}
+		e.c = c.WithRateLimiter(l)
+	}
+	e.out = &countingWriter{w: w, n: e.cp.Offset}
+	if e.enc, err = newEncoder(e.out, opts.Format, opts.Columns, opts.Compress); err != nil {
+		return e.progress(), err
+	}
+	if !resumed {
+		if err := e.enc.header(); err != nil {
+			return e.progress(), err
+		}
+	}
+	wg := sync.WaitGroup{}
+	for i := range e.cp.Segments {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := e.scan(i); err != nil {
+				e.fail(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := e.enc.close(); err != nil && e.err == nil {
+		e.err = err
+	}
+	if e.err == nil && len(opts.Checkpoint) > 0 {
+		e.err = os.Remove(opts.Checkpoint)
+	}
+	return e.progress(), e.err
+}
+
+// ToFile exports table to filename. If the checkpoint, by default filename with ".checkpoint" appended, exists the
+// export resumes after what the file had at the checkpoint, otherwise the file is replaced.
+func ToFile(c *dynamo.Client, table, filename string, opts Options) (Progress, error) {
+	if len(opts.Checkpoint) == 0 {
+		opts.Checkpoint = filename + ".checkpoint"
+	}
+	cp, err := readCheckpoint(opts.Checkpoint)
+	if err != nil {
+		return Progress{Table: table}, err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if cp != nil {
+		flags = os.O_WRONLY
+	}
+	f, err := os.OpenFile(filename, flags, 0644)
+	if err != nil {
+		return Progress{Table: table}, err
+	}
+	if cp != nil {
+		if err := resumeAt(f, cp.Offset); err != nil {
+			f.Close()
+			return Progress{Table: table}, err
+		}
+	}
+	p, err := Table(c, table, f, opts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return p, err
+}
+
+// resumeAt drops what was written to f after offset, and moves to it.
+func resumeAt(f *os.File, offset int64) error {
+	if info, err := f.Stat(); err != nil {
+		return err
+	} else if info.Size() < offset {
+		return fmt.Errorf("%s has %d bytes, less than the %d of its checkpoint", f.Name(), info.Size(), offset)
+	}
+	if err := f.Truncate(offset); err != nil {
+		return err
+	}
+	_, err := f.Seek(offset, io.SeekStart)
+	return err
+}
+
+// readCheckpoint reads a checkpoint, nil if it doesn't exist.
+func readCheckpoint(filename string) (*checkpoint, error) {
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	cp := &checkpoint{}
+	if err := json.Unmarshal(b, cp); err != nil {
+		return nil, fmt.Errorf("Invalid checkpoint %s: %s", filename, err.Error())
+	}
+	return cp, nil
+}
+
+// countingWriter counts the bytes written to w, from n.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	return n, err
+}
+
+type exporter struct {
+	c     *dynamo.Client
+	table string
+	opts  Options
+	start time.Time
+
+	mu  sync.Mutex // Guards everything below.
+	out *countingWriter
+	enc encoder
+	cp  checkpoint
+	err error
+}
+
+// load reads the checkpoint, reporting whether the export is being resumed.
+func (e *exporter) load() (bool, error) {
+	e.cp = checkpoint{Table: e.table, Format: e.opts.Format, Segments: make([]segment, e.opts.Segments)}
+	if len(e.opts.Checkpoint) == 0 {
+		return false, nil
+	}
+	cp, err := readCheckpoint(e.opts.Checkpoint)
+	if err != nil || cp == nil {
+		return false, err
+	}
+	if cp.Table != e.table || cp.Format != e.opts.Format || len(cp.Segments) != e.opts.Segments {
+		return false, fmt.Errorf("Checkpoint %s is of an export of %s as %s in %d segments", e.opts.Checkpoint, cp.Table, cp.Format, len(cp.Segments))
+	}
+	e.cp = *cp
+	return true, nil
+}
+
+func (e *exporter) scan(seg int) error {
+	e.mu.Lock()
+	s := e.cp.Segments[seg]
+	e.mu.Unlock()
+	for !s.Done {
+		req := dynamo.ScanRequest{
+			TableName:         e.table,
+			Segment:           seg,
+			TotalSegments:     len(e.cp.Segments),
+			ExclusiveStartKey: s.LastKey,
+			Limit:             e.opts.PageSize,
+		}
+		items, lastKey, err := e.page(req)
+		if err != nil {
+			return err
+		}
+		s = segment{LastKey: lastKey, Done: len(lastKey) == 0}
+		if err := e.pageDone(seg, s, items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// page scans a single page, waiting and trying again if the table's throughput is exceeded. Its items are held until
+// the page is written with pageDone.
+func (e *exporter) page(req dynamo.ScanRequest) ([]dynamo.AttributeSet, dynamo.AttributeSet, error) {
+	for i := 0; ; i++ {
+		items, lastKey, err := e.c.RawScan(req)
+		throttled := dynamo.IsErrorCode(err, dynamo.ProvisionedThroughputExceededException) ||
+			dynamo.IsErrorCode(err, dynamo.ThrottlingException)
+		if !throttled || i == maxThrottleRetries {
+			return items, lastKey, err
+		}
+		time.Sleep(throttleBackoff << uint(i))
+	}
+}
+
+// pageDone writes the items of a page of a segment and saves the checkpoint after them, so the output up to the
+// checkpoint's offset has exactly the pages it records.
+func (e *exporter) pageDone(seg int, s segment, items []dynamo.AttributeSet) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return e.err
+	}
+	for _, item := range items {
+		if err := e.enc.encode(item); err != nil {
+			return err
+		}
+	}
+	if err := e.enc.flush(); err != nil {
+		return err
+	}
+	e.cp.Segments[seg] = s
+	e.cp.Items += int64(len(items))
+	e.cp.Pages++
+	e.cp.Offset = e.out.n
+	if len(e.opts.Checkpoint) > 0 {
+		b, err := json.Marshal(e.cp)
+		if err != nil {
+			return err
+		}
+		tmp := e.opts.Checkpoint + ".tmp"
+		if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, e.opts.Checkpoint); err != nil {
+			return err
+		}
+	}
+	if e.opts.Progress != nil {
+		e.opts.Progress(e.progressLocked())
+	}
+	return nil
+}
+
+// fail stops the export with err, unless it already failed.
+func (e *exporter) fail(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *exporter) progress() Progress {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.progressLocked()
+}
+
+func (e *exporter) progressLocked() Progress {
+	p := Progress{
+		Table:    e.table,
+		Items:    e.cp.Items,
+		Pages:    e.cp.Pages,
+		Segments: len(e.cp.Segments),
+		Elapsed:  time.Since(e.start),
+	}
+	for _, s := range e.cp.Segments {
+		if s.Done {
+			p.SegmentsDone++
+		}
+	}
+	return p
+}
@@ -0,0 +1,212 @@
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/crowdmob/goamz/aws"
+	"github.com/poptip/dynamo"
+)
+
+// scanServer serves scans of n items split in segments, two items per page. Pages starting after failAfter fail
+// while it's set.
+type scanServer struct {
+	n         int
+	mu        sync.Mutex
+	failAfter string
+}
+
+func (s *scanServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := dynamo.ScanRequest{}
+	json.NewDecoder(r.Body).Decode(&req)
+	start := req.ExclusiveStartKey["id"].S
+	s.mu.Lock()
+	fail := len(s.failAfter) > 0 && start == s.failAfter
+	s.mu.Unlock()
+	if fail {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ValidationException","message":"Failing"}`))
+		return
+	}
+	res := dynamo.QueryResponse{Items: []dynamo.AttributeSet{}}
+	for i := 0; i < s.n; i++ {
+		id := fmt.Sprintf("item-%02d", i)
+		if i%req.TotalSegments != req.Segment || id <= start {
+			continue
+		}
+		if len(res.Items) == 2 {
+			res.LastEvaluatedKey = dynamo.AttributeSet{"id": {S: res.Items[1]["id"].S}}
+			break
+		}
+		res.Items = append(res.Items, dynamo.AttributeSet{
+			"id":    {S: id},
+			"count": {N: fmt.Sprint(i)},
+			"tags":  {SS: []string{"a", "b"}},
+		})
+	}
+	res.Count = len(res.Items)
+	json.NewEncoder(w).Encode(res)
+}
+
+func newTestClient(s *scanServer) (*dynamo.Client, *httptest.Server) {
+	ts := httptest.NewServer(s)
+	region := aws.USEast
+	region.DynamoDBEndpoint = ts.URL
+	return dynamo.NewClient(aws.Auth{AccessKey: "key", SecretKey: "secret"}, region), ts
+}
+
+func TestExportJSONLines(t *testing.T) {
+	c, ts := newTestClient(&scanServer{n: 9})
+	defer ts.Close()
+	b := &strings.Builder{}
+	progress := 0
+	p, err := Table(c, "things", b, Options{Segments: 3, Progress: func(Progress) { progress++ }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Items != 9 || p.SegmentsDone != 3 || int64(progress) != p.Pages {
+		t.Errorf("Unexpected progress %+v after %d reports", p, progress)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	sort.Strings(lines)
+	if len(lines) != 9 || lines[0] != `{"count":0,"id":"item-00","tags":["a","b"]}` {
+		t.Errorf("Unexpected output %v", lines)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	c, ts := newTestClient(&scanServer{n: 3})
+	defer ts.Close()
+	b := &strings.Builder{}
+	columns := []Column{{Header: "ID", Attribute: "id"}, {Attribute: "tags"}, {Attribute: "missing"}}
+	if _, err := Table(c, "things", b, Options{Format: CSV, Columns: columns}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "ID,tags,missing\nitem-00,a;b,\nitem-01,a;b,\nitem-02,a;b,\n"
+	if b.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, b.String())
+	}
+	if _, err := Table(c, "things", b, Options{Format: CSV}); err == nil {
+		t.Error("Expected error exporting CSV without columns")
+	}
+}
+
+func TestExportResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "things.json.gz")
+	s := &scanServer{n: 7, failAfter: "item-03"}
+	c, ts := newTestClient(s)
+	defer ts.Close()
+	opts := Options{Format: DynamoJSON, Compress: true}
+	if p, err := ToFile(c, "things", filename, opts); err == nil || p.Items != 4 {
+		t.Fatalf("Expected the export to fail after 4 items, got %v and %+v", err, p)
+	}
+	if _, err := os.Stat(filename + ".checkpoint"); err != nil {
+		t.Fatal(err)
+	}
+	s.failAfter = ""
+	p, err := ToFile(c, "things", filename, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Items != 7 {
+		t.Errorf("Expected 7 items, got %+v", p)
+	}
+	if _, err := os.Stat(filename + ".checkpoint"); !os.IsNotExist(err) {
+		t.Error("Expected the checkpoint to be removed")
+	}
+	f, _ := os.Open(filename)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for sc := bufio.NewScanner(zr); sc.Scan(); {
+		line := struct{ Item dynamo.AttributeSet }{}
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, line.Item["id"].S)
+	}
+	if len(ids) != 7 || ids[6] != "item-06" {
+		t.Errorf("Unexpected items %v", ids)
+	}
+}
+
+func TestExportResumeSegments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, compress := range []bool{false, true} {
+		filename := filepath.Join(dir, fmt.Sprintf("things-%v.json", compress))
+		s := &scanServer{n: 12, failAfter: "item-04"}
+		c, ts := newTestClient(s)
+		opts := Options{Segments: 3, Compress: compress}
+		if _, err := ToFile(c, "things", filename, opts); err == nil {
+			t.Fatal("Expected the export to fail")
+		}
+		// The export crashed while writing.
+		f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte{0x1f, 0x8b, '{', '"', 'i', 'd'})
+		f.Close()
+
+		s.failAfter = ""
+		p, err := ToFile(c, "things", filename, opts)
+		ts.Close()
+		if err != nil {
+			t.Fatal(err)
+		} else if p.Items != 12 {
+			t.Errorf("Expected 12 items, got %+v", p)
+		}
+		f, _ = os.Open(filename)
+		r := io.Reader(f)
+		if compress {
+			if r, err = gzip.NewReader(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ids := map[string]int{}
+		sc := bufio.NewScanner(r)
+		for sc.Scan() {
+			item := map[string]interface{}{}
+			if err := json.Unmarshal(sc.Bytes(), &item); err != nil {
+				t.Fatalf("Invalid line %q: %v", sc.Text(), err)
+			}
+			ids[fmt.Sprint(item["id"])]++
+		}
+		if err := sc.Err(); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		if len(ids) != 12 {
+			t.Errorf("Expected 12 items, got %v", ids)
+		}
+		for id, n := range ids {
+			if n != 1 {
+				t.Errorf("%s was written %d times", id, n)
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package export
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/poptip/dynamo"
+)
+
+// Format is how items are written.
+type Format string
+
+const (
+	// JSONLines writes every item as a plain JSON object on its own line, numbers as JSON numbers and sets as arrays.
+	JSONLines Format = "jsonl"
+	// CSV writes the attributes picked by Options.Columns, one item per row. Sets are joined with SetSeparator.
+	CSV Format = "csv"
+	// DynamoJSON writes every item with its DynamoDB types on its own line, as {"Item":{"id":{"S":"a"}}}.
+	DynamoJSON Format = "dynamodb-json"
+)
+
+// SetSeparator joins the values of sets in CSV cells.
+const SetSeparator = ";"
+
+// Column maps an attribute to a CSV column.
+type Column struct {
+	Header    string // Defaults to the attribute name.
+	Attribute string
+}
+
+// PlainItem converts an item to plain JSON values: strings and binaries to strings, numbers to json.Number and sets
+// to slices of those.
+func PlainItem(item dynamo.AttributeSet) map[string]interface{} {
+	plain := make(map[string]interface{}, len(item))
+	for name, val := range item {
+		plain[name] = PlainValue(val)
+	}
+	return plain
+}
+
+// PlainValue converts a single value like PlainItem.
+func PlainValue(val dynamo.AttributeVal) interface{} {
+	switch {
+	case len(val.N) > 0:
+		return json.Number(val.N)
+	case len(val.B) > 0:
+		return val.B
+	case len(val.SS) > 0:
+		return val.SS
+	case len(val.BS) > 0:
+		return val.BS
+	case len(val.NS) > 0:
+		nums := make([]json.Number, len(val.NS))
+		for i, n := range val.NS {
+			nums[i] = json.Number(n)
+		}
+		return nums
+	}
+	return val.S
+}
+
+// cellValue is the text of a value in a CSV cell.
+func cellValue(val dynamo.AttributeVal) string {
+	switch {
+	case len(val.N) > 0:
+		return val.N
+	case len(val.B) > 0:
+		return val.B
+	case len(val.SS) > 0:
+		return strings.Join(val.SS, SetSeparator)
+	case len(val.NS) > 0:
+		return strings.Join(val.NS, SetSeparator)
+	case len(val.BS) > 0:
+		return strings.Join(val.BS, SetSeparator)
+	}
+	return val.S
+}
+
+// encoder writes items in a format. It isn't safe for concurrent use.
+type encoder interface {
+	header() error
+	encode(item dynamo.AttributeSet) error
+	flush() error // Writes everything encoded so far to the underlying writer, ending the gzip member if compressed.
+	close() error
+}
+
+func newEncoder(w io.Writer, format Format, columns []Column, compress bool) (encoder, error) {
+	var zw *gzipMembers
+	if compress {
+		zw = &gzipMembers{zw: gzip.NewWriter(w), w: w}
+		w = zw
+	}
+	switch format {
+	case JSONLines, DynamoJSON:
+		return &jsonEncoder{enc: json.NewEncoder(w), zw: zw, dynamo: format == DynamoJSON}, nil
+	case CSV:
+		if len(columns) == 0 {
+			return nil, errors.New("CSV export needs columns")
+		}
+		return &csvEncoder{w: csv.NewWriter(w), zw: zw, columns: columns}, nil
+	}
+	return nil, fmt.Errorf("Unknown export format %q", format)
+}
+
+type jsonEncoder struct {
+	enc    *json.Encoder
+	zw     *gzipMembers
+	dynamo bool
+}
+
+func (e *jsonEncoder) header() error {
+	return nil
+}
+
+func (e *jsonEncoder) encode(item dynamo.AttributeSet) error {
+	if e.dynamo {
+		return e.enc.Encode(struct{ Item dynamo.AttributeSet }{item})
+	}
+	return e.enc.Encode(PlainItem(item))
+}
+
+func (e *jsonEncoder) flush() error {
+	return flushGzip(e.zw)
+}
+
+func (e *jsonEncoder) close() error {
+	return closeGzip(e.zw)
+}
+
+type csvEncoder struct {
+	w       *csv.Writer
+	zw      *gzipMembers
+	columns []Column
+	row     []string
+}
+
+func (e *csvEncoder) header() error {
+	row := make([]string, len(e.columns))
+	for i, col := range e.columns {
+		row[i] = col.Header
+		if len(row[i]) == 0 {
+			row[i] = col.Attribute
+		}
+	}
+	return e.w.Write(row)
+}
+
+func (e *csvEncoder) encode(item dynamo.AttributeSet) error {
+	if e.row == nil {
+		e.row = make([]string, len(e.columns))
+	}
+	for i, col := range e.columns {
+		e.row[i] = cellValue(item[col.Attribute])
+	}
+	return e.w.Write(e.row)
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	if err := e.w.Error(); err != nil {
+		return err
+	}
+	return flushGzip(e.zw)
+}
+
+func (e *csvEncoder) close() error {
+	e.w.Flush()
+	if err := e.w.Error(); err != nil {
+		return err
+	}
+	return closeGzip(e.zw)
+}
+
+// gzipMembers compresses to w as a series of gzip members, which gzip readers read as one stream, so the output
+// can be cut after any member.
+type gzipMembers struct {
+	zw      *gzip.Writer
+	w       io.Writer
+	pending bool // Whether the current member has data.
+	members int  // Ended.
+}
+
+func (g *gzipMembers) Write(b []byte) (int, error) {
+	g.pending = true
+	return g.zw.Write(b)
+}
+
+// flushGzip ends the current gzip member, if anything was written to it, and starts the next.
+func flushGzip(g *gzipMembers) error {
+	if g == nil || !g.pending {
+		return nil
+	} else if err := g.zw.Close(); err != nil {
+		return err
+	}
+	g.zw.Reset(g.w)
+	g.pending = false
+	g.members++
+	return nil
+}
+
+// closeGzip ends the last member, writing an empty one if there's none so the output is valid gzip.
+func closeGzip(g *gzipMembers) error {
+	if g == nil || (!g.pending && g.members > 0) {
+		return nil
+	}
+	g.members++
+	return g.zw.Close()
+}
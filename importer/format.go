@@ -0,0 +1,234 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/poptip/dynamo"
+	"github.com/poptip/dynamo/export"
+)
+
+const maxLineSize = 1024 * 1024 // Lines longer than this can't be imported, well over DynamoDB's item size limit.
+
+// rowError is a row that can't be converted to an item. The row is rejected, and the import goes on.
+type rowError struct {
+	err error
+}
+
+func (e rowError) Error() string {
+	return e.err.Error()
+}
+
+// rowReader reads the rows of an input, returning the raw row with the item.
+type rowReader interface {
+	next() (raw string, item dynamo.AttributeSet, err error)
+}
+
+func newRowReader(r io.Reader, opts Options) (rowReader, error) {
+	switch opts.Format {
+	case export.JSONLines, export.DynamoJSON:
+		s := bufio.NewScanner(r)
+		s.Buffer(make([]byte, 64*1024), maxLineSize)
+		return &jsonReader{s: s, dynamo: opts.Format == export.DynamoJSON}, nil
+	case export.CSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		header, err := cr.Read()
+		if err != nil {
+			return nil, fmt.Errorf("Could not read CSV header: %s", err.Error())
+		}
+		for _, name := range header {
+			if t, ok := opts.Types[name]; ok && !validTypes[t] {
+				return nil, fmt.Errorf("Invalid type %q for column %s", t, name)
+			}
+		}
+		return &csvReader{r: cr, header: header, types: opts.Types}, nil
+	}
+	return nil, fmt.Errorf("Unknown import format %q", opts.Format)
+}
+
+var validTypes = map[string]bool{
+	dynamo.TypeString: true, dynamo.TypeNumber: true, dynamo.TypeBinary: true,
+	dynamo.TypeStringSet: true, dynamo.TypeNumberSet: true, dynamo.TypeBinarySet: true,
+}
+
+type jsonReader struct {
+	s      *bufio.Scanner
+	dynamo bool
+}
+
+func (r *jsonReader) next() (string, dynamo.AttributeSet, error) {
+	for r.s.Scan() {
+		line := bytes.TrimSpace(r.s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		raw := string(line)
+		var item dynamo.AttributeSet
+		var err error
+		if r.dynamo {
+			item, err = dynamoItem(line)
+		} else {
+			item, err = plainItem(line)
+		}
+		if err != nil {
+			return raw, nil, rowError{err}
+		}
+		return raw, item, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return "", nil, err
+	}
+	return "", nil, io.EOF
+}
+
+// ParseItem converts a line of JSON Lines or DynamoDB-JSON to an item, the way rows are converted when importing.
+func ParseItem(line []byte, format export.Format) (dynamo.AttributeSet, error) {
+	switch format {
+	case export.JSONLines:
+		return plainItem(line)
+	case export.DynamoJSON:
+		return dynamoItem(line)
+	}
+	return nil, fmt.Errorf("Items can't be parsed from %q", format)
+}
+
+// dynamoItem decodes a line of DynamoDB-JSON, either {"Item":{...}} as written by export or a bare item.
+func dynamoItem(line []byte) (dynamo.AttributeSet, error) {
+	wrapped := struct{ Item dynamo.AttributeSet }{}
+	if err := json.Unmarshal(line, &wrapped); err != nil {
+		return nil, err
+	} else if wrapped.Item != nil {
+		return wrapped.Item, nil
+	}
+	item := dynamo.AttributeSet{}
+	return item, json.Unmarshal(line, &item)
+}
+
+// plainItem converts a plain JSON object: strings to S, numbers to N, booleans to "1" or "0" like MarshalAttributes,
+// arrays of strings or numbers to sets and anything else to S as JSON. Nulls are left out, empty arrays are errors.
+func plainItem(line []byte) (dynamo.AttributeSet, error) {
+	obj := map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	item := make(dynamo.AttributeSet, len(obj))
+	for name, v := range obj {
+		val, err := plainValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("Attribute %s: %s", name, err.Error())
+		} else if val.IsValid() {
+			item[name] = val
+		}
+	}
+	return item, nil
+}
+
+func plainValue(v interface{}) (dynamo.AttributeVal, error) {
+	switch v := v.(type) {
+	case nil:
+		return dynamo.AttributeVal{}, nil
+	case string:
+		return dynamo.AttributeVal{S: v}, nil
+	case json.Number:
+		return dynamo.AttributeVal{N: v.String()}, nil
+	case bool:
+		if v {
+			return dynamo.AttributeVal{S: "1"}, nil
+		}
+		return dynamo.AttributeVal{S: "0"}, nil
+	case []interface{}:
+		if len(v) == 0 {
+			return dynamo.AttributeVal{}, errors.New("Empty arrays can't be stored as sets")
+		} else if val, ok := setValue(v); ok {
+			return val, nil
+		}
+	}
+	b, err := json.Marshal(v)
+	return dynamo.AttributeVal{S: string(b)}, err
+}
+
+// setValue converts an array of only strings or only numbers to a set, dropping duplicates.
+func setValue(arr []interface{}) (dynamo.AttributeVal, bool) {
+	val := dynamo.AttributeVal{}
+	seen := map[string]bool{}
+	for _, e := range arr {
+		switch e := e.(type) {
+		case string:
+			if !seen[e] {
+				val.SS = append(val.SS, e)
+			}
+			seen[e] = true
+		case json.Number:
+			if !seen[e.String()] {
+				val.NS = append(val.NS, e.String())
+			}
+			seen[e.String()] = true
+		default:
+			return val, false
+		}
+	}
+	return val, len(val.SS) == 0 || len(val.NS) == 0
+}
+
+type csvReader struct {
+	r      *csv.Reader
+	header []string
+	types  map[string]string
+}
+
+func (r *csvReader) next() (string, dynamo.AttributeSet, error) {
+	record, err := r.r.Read()
+	if err == io.EOF {
+		return "", nil, err
+	} else if perr, ok := err.(*csv.ParseError); ok {
+		return "", nil, rowError{perr}
+	} else if err != nil {
+		return "", nil, err
+	}
+	raw := csvLine(record)
+	if len(record) > len(r.header) {
+		return raw, nil, rowError{errors.New("Row has more fields than the header")}
+	}
+	item := dynamo.AttributeSet{}
+	for i, cell := range record {
+		if len(cell) == 0 {
+			continue
+		}
+		name := r.header[i]
+		switch t := r.types[name]; t {
+		case dynamo.TypeNumber:
+			if !dynamo.NumberRegex.MatchString(cell) {
+				return raw, nil, rowError{fmt.Errorf("Column %s: %q is not a number", name, cell)}
+			}
+			item[name] = dynamo.AttributeVal{N: cell}
+		case dynamo.TypeBinary:
+			item[name] = dynamo.AttributeVal{B: cell}
+		case dynamo.TypeStringSet:
+			item[name] = dynamo.AttributeVal{SS: strings.Split(cell, export.SetSeparator)}
+		case dynamo.TypeNumberSet:
+			item[name] = dynamo.AttributeVal{NS: strings.Split(cell, export.SetSeparator)}
+		case dynamo.TypeBinarySet:
+			item[name] = dynamo.AttributeVal{BS: strings.Split(cell, export.SetSeparator)}
+		default:
+			item[name] = dynamo.AttributeVal{S: cell}
+		}
+	}
+	return raw, item, nil
+}
+
+func csvLine(record []string) string {
+	b := &bytes.Buffer{}
+	w := csv.NewWriter(b)
+	w.Write(record)
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,274 @@
+// Package importer loads JSON Lines, CSV or DynamoDB-JSON into DynamoDB tables, the reverse of package export.
+package importer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/poptip/dynamo"
+	"github.com/poptip/dynamo/export"
+)
+
+// Options configure an import. The zero value reads JSON Lines and writes as fast as the table allows.
+type Options struct {
+	Format        export.Format     // Defaults to export.JSONLines.
+	Types         map[string]string // CSV column types, i.e. dynamo.TypeNumber. Columns default to strings.
+	WriteFraction float64           // Of the table's provisioned write throughput to use, unlimited if 0. Leaves the client's own rate limiter alone.
+
+	// Checkpoint is a file recording the number of rows imported after every batch. If it exists when the import
+	// starts, the rows before it are skipped. It's removed once the import completes.
+	Checkpoint string
+
+	// DeadLetter is a file rejected rows are appended to as JSON, with the row number and the reason. Rejected rows
+	// are only counted if it's empty.
+	DeadLetter string
+
+	Progress func(Progress) // Called after every batch.
+}
+
+// Progress of an import.
+type Progress struct {
+	Table    string
+	Rows     int64 // Read from the input, including skipped and rejected rows.
+	Written  int64
+	Rejected int64
+	Elapsed  time.Duration
+}
+
+// Rejection is a row written to the dead-letter file.
+type Rejection struct {
+	Row   int64
+	Error string
+	Data  string
+}
+
+type checkpoint struct {
+	Table    string
+	Rows     int64
+	Written  int64
+	Rejected int64
+}
+
+type row struct {
+	n    int64
+	raw  string
+	item dynamo.AttributeSet
+}
+
+// Table reads rows from r, which may be gzipped, and writes them to table.
+func Table(c *dynamo.Client, table string, r io.Reader, opts Options) (Progress, error) {
+	if len(opts.Format) == 0 {
+		opts.Format = export.JSONLines
+	}
+	im := &importer{c: c, opts: opts, bw: c.NewBatchWriter(table), start: time.Now()}
+	im.cp.Table = table
+	if err := im.load(); err != nil {
+		return im.progress(), err
+	}
+	defer im.closeDeadLetter()
+	if opts.WriteFraction > 0 {
+		l, err := c.ThroughputLimiter(table, opts.WriteFraction)
+		if err != nil {
+			return im.progress(), err
+		}
+		im.c = c.WithRateLimiter(l)
+		im.bw = im.c.NewBatchWriter(table)
+	}
+	r, err := decompress(r)
+	if err != nil {
+		return im.progress(), err
+	}
+	rr, err := newRowReader(r, opts)
+	if err != nil {
+		return im.progress(), err
+	}
+	skip, n := im.cp.Rows, int64(0)
+	for {
+		raw, item, err := rr.next()
+		if err == io.EOF {
+			break
+		}
+		if n++; n <= skip {
+			continue
+		}
+		if _, ok := err.(rowError); ok {
+			if err := im.reject(row{n, raw, item}, err); err != nil {
+				return im.progress(), err
+			}
+			continue
+		} else if err != nil {
+			return im.progress(), err
+		}
+		if err := im.add(row{n, raw, item}); err != nil {
+			return im.progress(), err
+		}
+	}
+	if err := im.flush(n); err != nil {
+		return im.progress(), err
+	}
+	if len(opts.Checkpoint) > 0 {
+		if err := os.Remove(opts.Checkpoint); err != nil && !os.IsNotExist(err) {
+			return im.progress(), err
+		}
+	}
+	return im.progress(), nil
+}
+
+// File imports filename into table.
+func File(c *dynamo.Client, table, filename string, opts Options) (Progress, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return Progress{Table: table}, err
+	}
+	defer f.Close()
+	return Table(c, table, f, opts)
+}
+
+// decompress returns a reader of the decompressed input if it's gzipped.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+type importer struct {
+	c          *dynamo.Client
+	opts       Options
+	bw         *dynamo.BatchWriter
+	start      time.Time
+	cp         checkpoint
+	batch      []row
+	deadLetter *os.File
+}
+
+func (im *importer) load() error {
+	if len(im.opts.Checkpoint) == 0 {
+		return nil
+	}
+	b, err := ioutil.ReadFile(im.opts.Checkpoint)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	cp := checkpoint{}
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return fmt.Errorf("Invalid checkpoint %s: %s", im.opts.Checkpoint, err.Error())
+	} else if cp.Table != im.cp.Table {
+		return fmt.Errorf("Checkpoint %s is of an import into %s", im.opts.Checkpoint, cp.Table)
+	}
+	im.cp = cp
+	return nil
+}
+
+// add buffers a row, writing the batch once it's full.
+func (im *importer) add(r row) error {
+	if len(r.item) == 0 {
+		return im.reject(r, errors.New("Row has no attributes"))
+	}
+	if err := im.bw.Put(r.item); err != nil {
+		// The batch is flushed explicitly, so Put only fails on invalid items.
+		return im.reject(r, err)
+	}
+	im.batch = append(im.batch, r)
+	if len(im.batch) < dynamo.BatchWriteItemLimit {
+		return nil
+	}
+	return im.flush(r.n)
+}
+
+// flush writes the buffered rows, then records that the rows up to n are done. If the batch is invalid, its rows are
+// written one at a time to find the invalid ones.
+func (im *importer) flush(n int64) error {
+	written := im.bw.Written()
+	err := im.bw.Flush()
+	if invalid(err) {
+		for _, r := range im.batch {
+			if err := im.bw.Put(r.item); err != nil {
+				if err := im.reject(r, err); err != nil {
+					return err
+				}
+			} else if err := im.bw.Flush(); invalid(err) {
+				if err := im.reject(r, err); err != nil {
+					return err
+				}
+			} else if err != nil {
+				return err
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+	im.batch = im.batch[:0]
+	im.cp.Written += im.bw.Written() - written
+	im.cp.Rows = n
+	if len(im.opts.Checkpoint) > 0 {
+		b, err := json.Marshal(im.cp)
+		if err != nil {
+			return err
+		}
+		tmp := im.opts.Checkpoint + ".tmp"
+		if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, im.opts.Checkpoint); err != nil {
+			return err
+		}
+	}
+	if im.opts.Progress != nil {
+		im.opts.Progress(im.progress())
+	}
+	return nil
+}
+
+// invalid reports whether err rejects the items of a batch, either from DynamoDB or from the client's validation.
+func invalid(err error) bool {
+	_, ok := err.(dynamo.ValidationErrors)
+	return ok || dynamo.IsErrorCode(err, dynamo.ValidationException)
+}
+
+// reject counts a row that can't be imported and appends it to the dead-letter file.
+func (im *importer) reject(r row, reason error) error {
+	im.cp.Rejected++
+	if len(im.opts.DeadLetter) == 0 {
+		return nil
+	}
+	if im.deadLetter == nil {
+		f, err := os.OpenFile(im.opts.DeadLetter, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		im.deadLetter = f
+	}
+	b, err := json.Marshal(Rejection{Row: r.n, Error: reason.Error(), Data: r.raw})
+	if err != nil {
+		return err
+	}
+	_, err = im.deadLetter.Write(append(b, '\n'))
+	return err
+}
+
+func (im *importer) closeDeadLetter() {
+	if im.deadLetter != nil {
+		im.deadLetter.Close()
+	}
+}
+
+func (im *importer) progress() Progress {
+	return Progress{
+		Table:    im.cp.Table,
+		Rows:     im.cp.Rows,
+		Written:  im.cp.Written,
+		Rejected: im.cp.Rejected,
+		Elapsed:  time.Since(im.start),
+	}
+}
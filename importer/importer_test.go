@@ -0,0 +1,185 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/crowdmob/goamz/aws"
+	"github.com/poptip/dynamo"
+	"github.com/poptip/dynamo/export"
+)
+
+// writeServer stores batch writes. Batches with an item with a "bad" attribute are invalid, and batches fail while
+// failing is set.
+type writeServer struct {
+	mu      sync.Mutex
+	items   map[string]dynamo.AttributeSet
+	failing bool
+}
+
+func (s *writeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#InternalServerError","message":"Failing"}`))
+		return
+	}
+	req := dynamo.BatchWriteRequest{}
+	json.NewDecoder(r.Body).Decode(&req)
+	for _, item := range req.RequestItems["things"] {
+		if _, ok := item.PutRequest.Item["bad"]; ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazon.coral.validate#ValidationException","message":"Bad item"}`))
+			return
+		}
+	}
+	for _, item := range req.RequestItems["things"] {
+		s.items[item.PutRequest.Item["id"].S] = item.PutRequest.Item
+	}
+	w.Write([]byte(`{}`))
+}
+
+func newTestClient(s *writeServer) (*dynamo.Client, *httptest.Server) {
+	s.items = map[string]dynamo.AttributeSet{}
+	ts := httptest.NewServer(s)
+	region := aws.USEast
+	region.DynamoDBEndpoint = ts.URL
+	return dynamo.NewClient(aws.Auth{AccessKey: "key", SecretKey: "secret"}, region), ts
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestImportJSONLines(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	s := &writeServer{}
+	c, ts := newTestClient(s)
+	defer ts.Close()
+	lines := []string{}
+	for i := 0; i < 30; i++ {
+		lines = append(lines, fmt.Sprintf(`{"id":"item-%d","count":%d,"tags":["a","b","a"],"active":true,"meta":{"k":1},"gone":null}`, i, i))
+	}
+	lines[3] = `{"id":`
+	lines[7] = `{"id":"item-7","bad":1}`
+	lines[12] = `{}`
+	lines[20] = `{"id":"item-20","tags":[]}`
+	deadLetter := filepath.Join(dir, "rejected.json")
+	p, err := Table(c, "things", strings.NewReader(strings.Join(lines, "\n")), Options{DeadLetter: deadLetter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Rows != 30 || p.Written != 26 || p.Rejected != 4 || len(s.items) != 26 {
+		t.Errorf("Unexpected progress %+v with %d items", p, len(s.items))
+	}
+	item := s.items["item-0"]
+	if item["count"].N != "0" || len(item["tags"].SS) != 2 || item["active"].S != "1" || item["meta"].S != `{"k":1}` {
+		t.Errorf("Unexpected item %v", item)
+	} else if _, ok := item["gone"]; ok {
+		t.Error("Expected nulls to be left out")
+	}
+	f, _ := os.Open(deadLetter)
+	defer f.Close()
+	rows := []int64{}
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		r := Rejection{}
+		json.Unmarshal(sc.Bytes(), &r)
+		rows = append(rows, r.Row)
+	}
+	if fmt.Sprint(rows) != "[4 13 21 8]" {
+		t.Errorf("Expected rows 4, 13, 21 and 8 to be rejected, got %v", rows)
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	s := &writeServer{}
+	c, ts := newTestClient(s)
+	defer ts.Close()
+	input := "id,count,tags\na,1,x;y\nb,two,\nc,,z\n"
+	opts := Options{Format: export.CSV, Types: map[string]string{"count": dynamo.TypeNumber, "tags": dynamo.TypeStringSet}}
+	p, err := Table(c, "things", strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Written != 2 || p.Rejected != 1 {
+		t.Errorf("Unexpected progress %+v", p)
+	}
+	if a := s.items["a"]; a["count"].N != "1" || len(a["tags"].SS) != 2 {
+		t.Errorf("Unexpected item %v", a)
+	} else if _, ok := s.items["c"]["count"]; ok {
+		t.Error("Expected empty cells to be left out")
+	}
+}
+
+func TestImportResume(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	s := &writeServer{}
+	c, ts := newTestClient(s)
+	defer ts.Close()
+	lines := []string{}
+	for i := 0; i < 60; i++ {
+		lines = append(lines, fmt.Sprintf(`{"id":"item-%d"}`, i))
+	}
+	input := strings.Join(lines, "\n")
+	checkpoint := filepath.Join(dir, "checkpoint")
+	opts := Options{Checkpoint: checkpoint, Progress: func(p Progress) {
+		s.mu.Lock()
+		s.failing = p.Written == 25
+		s.mu.Unlock()
+	}}
+	if p, err := Table(c, "things", strings.NewReader(input), opts); err == nil || p.Rows != 25 {
+		t.Fatalf("Expected the import to fail after 25 rows, got %v and %+v", err, p)
+	}
+	s.mu.Lock()
+	s.failing = false
+	s.mu.Unlock()
+	opts.Progress = nil
+	p, err := Table(c, "things", strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Rows != 60 || p.Written != 60 || len(s.items) != 60 {
+		t.Errorf("Unexpected progress %+v with %d items", p, len(s.items))
+	}
+	if _, err := os.Stat(checkpoint); !os.IsNotExist(err) {
+		t.Error("Expected the checkpoint to be removed")
+	}
+}
+
+func TestImportInvalidNumber(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	s := &writeServer{}
+	c, ts := newTestClient(s)
+	defer ts.Close()
+	deadLetter := filepath.Join(dir, "rejected.json")
+	rows := `{"id":"a","n":1}` + "\n" + `{"id":"b","n":1e200}` + "\n" + `{"id":"c","n":3}`
+	p, err := Table(c, "things", strings.NewReader(rows), Options{DeadLetter: deadLetter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Rows != 3 || p.Written != 2 || p.Rejected != 1 || s.items["a"] == nil || s.items["c"] == nil {
+		t.Errorf("Unexpected progress %+v with items %v", p, s.items)
+	}
+	b, _ := ioutil.ReadFile(deadLetter)
+	r := Rejection{}
+	if err := json.Unmarshal(b, &r); err != nil || r.Row != 2 || !strings.Contains(r.Error, "out of range") {
+		t.Errorf("Unexpected rejection %s", b)
+	}
+}
@@ -0,0 +1,234 @@
+package dynamo
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span attributes set by the client, following the OpenTelemetry conventions where there is one.
+const (
+	AttrSystem           = "db.system"
+	AttrOperation        = "db.operation"
+	AttrTableNames       = "aws.dynamodb.table_names"
+	AttrConsumedCapacity = "aws.dynamodb.consumed_capacity"
+	AttrCount            = "aws.dynamodb.count"
+	AttrScannedCount     = "aws.dynamodb.scanned_count"
+	AttrRequestItems     = "dynamo.request_items"
+	AttrRetries          = "dynamo.retries"
+	AttrErrorType        = "error.type"
+)
+
+// Tracer starts a span for every operation. Implement it to export spans, i.e. to OpenTelemetry.
+type Tracer interface {
+	Start(operation string) Span
+}
+
+// Span is a single operation being traced.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End(err error)
+}
+
+// Meter records the latency and errors of every operation. table is a comma separated list for operations on
+// several tables.
+type Meter interface {
+	RecordLatency(operation, table string, d time.Duration)
+	CountError(operation, table, errorType string)
+}
+
+// Instrument traces every operation with t and records metrics with m. Either may be nil, and when both are the client
+// does no extra work. Spans include consumed capacity, so requests ask for it when t isn't nil.
+func (c *Client) Instrument(t Tracer, m Meter) {
+	c.tracer, c.meter = t, m
+}
+
+// observation is an operation being traced and measured.
+type observation struct {
+	c        *Client
+	endpoint string
+	table    string
+	req      *Request
+	span     Span
+	start    time.Time
+}
+
+func (c *Client) observe(endpoint string, data interface{}, req *Request) *observation {
+	obs := &observation{c: c, endpoint: endpoint, req: req, start: time.Now()}
+	var tables []string
+	if tr, ok := data.(tableRequest); ok {
+		tables = tr.tableNames()
+		sort.Strings(tables)
+		obs.table = strings.Join(tables, ",")
+	}
+	if c.tracer != nil {
+		obs.span = c.tracer.Start(endpoint)
+		obs.span.SetAttribute(AttrSystem, "dynamodb")
+		obs.span.SetAttribute(AttrOperation, endpoint)
+		if len(tables) > 0 {
+			obs.span.SetAttribute(AttrTableNames, tables)
+		}
+		if n, ok := requestItems(data); ok {
+			obs.span.SetAttribute(AttrRequestItems, n)
+		}
+	}
+	return obs
+}
+
+// end finishes the observation of an operation that returned dst and err. It does nothing if obs is nil.
+func (obs *observation) end(dst interface{}, err error) {
+	if obs == nil {
+		return
+	}
+	if m := obs.c.meter; m != nil {
+		m.RecordLatency(obs.endpoint, obs.table, time.Since(obs.start))
+		if err != nil {
+			m.CountError(obs.endpoint, obs.table, errorType(err))
+		}
+	}
+	if obs.span == nil {
+		return
+	}
+	if obs.req.attempts > 1 {
+		obs.span.SetAttribute(AttrRetries, obs.req.attempts-1)
+	}
+	if cr, ok := dst.(capacityResponse); ok && err == nil {
+		total := 0.0
+		for _, s := range cr.consumed() {
+			total += s.CapacityUnits
+		}
+		obs.span.SetAttribute(AttrConsumedCapacity, total)
+	}
+	switch res := dst.(type) {
+	case *QueryResponse:
+		obs.span.SetAttribute(AttrCount, res.Count)
+		obs.span.SetAttribute(AttrScannedCount, res.ScannedCount)
+	case *itemStream:
+		obs.span.SetAttribute(AttrCount, res.Count)
+		obs.span.SetAttribute(AttrScannedCount, res.ScannedCount)
+	case *BatchResponse:
+		n := 0
+		for _, items := range res.Responses {
+			n += len(items)
+		}
+		obs.span.SetAttribute(AttrCount, n)
+	case *TransactGetResponse:
+		obs.span.SetAttribute(AttrCount, len(res.Responses))
+	}
+	if err != nil {
+		obs.span.SetAttribute(AttrErrorType, errorType(err))
+	}
+	obs.span.End(err)
+}
+
+// requestItems returns the number of items a request writes or reads, for requests on several items.
+func requestItems(data interface{}) (int, bool) {
+	n := 0
+	switch req := data.(type) {
+	case BatchWriteRequest:
+		for _, items := range req.RequestItems {
+			n += len(items)
+		}
+	case BatchGetRequest:
+		for _, item := range req.RequestItems {
+			n += len(item.Keys)
+		}
+	case TransactWriteRequest:
+		n = len(req.TransactItems)
+	case TransactGetRequest:
+		n = len(req.TransactItems)
+	default:
+		return 0, false
+	}
+	return n, true
+}
+
+// errorType is the DynamoDB error code of err, or "ClientError" for errors that didn't come from DynamoDB.
+func errorType(err error) string {
+	if e, ok := err.(*Error); ok {
+		return e.Code()
+	}
+	return "ClientError"
+}
+
+// LatencyBuckets are the upper bounds of the buckets of the latency histograms kept by StatsMeter.
+var LatencyBuckets = []time.Duration{
+	2 * time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second,
+}
+
+// StatsMeter is a Meter that keeps latency histograms and error counts in memory, per operation and table.
+type StatsMeter struct {
+	mu    sync.Mutex
+	stats map[StatsKey]*OperationStats
+}
+
+// StatsKey identifies the stats of a StatsMeter.
+type StatsKey struct {
+	Operation string
+	Table     string
+}
+
+// OperationStats are the latencies and errors of an operation on a table.
+type OperationStats struct {
+	Count   int
+	Total   time.Duration
+	Buckets []int          // Counts of latencies up to the matching LatencyBuckets, the last one counts the rest.
+	Errors  map[string]int // By error type.
+}
+
+// Mean returns the mean latency.
+func (s OperationStats) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+func NewStatsMeter() *StatsMeter {
+	return &StatsMeter{stats: map[StatsKey]*OperationStats{}}
+}
+
+func (m *StatsMeter) get(operation, table string) *OperationStats {
+	key := StatsKey{operation, table}
+	s := m.stats[key]
+	if s == nil {
+		s = &OperationStats{Buckets: make([]int, len(LatencyBuckets)+1), Errors: map[string]int{}}
+		m.stats[key] = s
+	}
+	return s
+}
+
+func (m *StatsMeter) RecordLatency(operation, table string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.get(operation, table)
+	s.Count++
+	s.Total += d
+	s.Buckets[sort.Search(len(LatencyBuckets), func(i int) bool { return d <= LatencyBuckets[i] })]++
+}
+
+func (m *StatsMeter) CountError(operation, table, errorType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(operation, table).Errors[errorType]++
+}
+
+// Snapshot returns a copy of the stats so far.
+func (m *StatsMeter) Snapshot() map[StatsKey]OperationStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snap := make(map[StatsKey]OperationStats, len(m.stats))
+	for k, s := range m.stats {
+		c := *s
+		c.Buckets = append([]int(nil), s.Buckets...)
+		c.Errors = make(map[string]int, len(s.Errors))
+		for t, n := range s.Errors {
+			c.Errors[t] = n
+		}
+		snap[k] = c
+	}
+	return snap
+}
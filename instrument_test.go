@@ -0,0 +1,82 @@
+package dynamo
+
+import (
+	"net/http"
+	"testing"
+)
+
+type testSpan struct {
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *testSpan) End(err error)                              { s.err, s.ended = err, true }
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(operation string) Span {
+	s := &testSpan{attrs: map[string]interface{}{}}
+	t.spans = append(t.spans, s)
+	return s
+}
+
+func TestInstrument(t *testing.T) {
+	fail := false
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ProvisionedThroughputExceededException","message":"Slow down"}`))
+			return
+		}
+		w.Write([]byte(`{"ConsumedCapacity":[{"TableName":"things","CapacityUnits":2}]}`))
+	})
+	defer s.Close()
+	tracer, meter := &testTracer{}, NewStatsMeter()
+	c.Instrument(tracer, meter)
+
+	if _, err := c.BatchWrite("things", []keyDoc{{"a"}, {"b"}}); err != nil {
+		t.Fatal(err)
+	}
+	fail = true
+	c.BatchWrite("things", []keyDoc{{"a"}})
+
+	span := tracer.spans[0]
+	if !span.ended || span.attrs[AttrOperation] != BatchWriteItemEndpoint || span.attrs[AttrRequestItems] != 2 || span.attrs[AttrConsumedCapacity] != 2.0 {
+		t.Errorf("Unexpected span attributes %v", span.attrs)
+	}
+	if span = tracer.spans[1]; span.err == nil || span.attrs[AttrErrorType] != ProvisionedThroughputExceededException {
+		t.Errorf("Expected throttling error on span, got %v", span.attrs)
+	}
+	stats := meter.Snapshot()[StatsKey{BatchWriteItemEndpoint, "things"}]
+	if stats.Count != 2 || stats.Errors[ProvisionedThroughputExceededException] != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestInstrumentDescribeTable(t *testing.T) {
+	calls := 0
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write(describeBody)
+	})
+	defer s.Close()
+	tracer, meter := &testTracer{}, NewStatsMeter()
+	c.Instrument(tracer, meter)
+
+	if _, err := c.DescribeTable("things"); err != nil {
+		t.Fatal(err)
+	}
+	if len(tracer.spans) != 1 || tracer.spans[0].attrs[AttrOperation] != DescribeTableEndpoint {
+		t.Errorf("Unexpected spans %v", tracer.spans)
+	}
+	if stats := meter.Snapshot()[StatsKey{DescribeTableEndpoint, "things"}]; stats.Count != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if _, err := c.DescribeTable("a"); err == nil || calls != 1 {
+		t.Errorf("Described an invalid table name with %d calls: %v", calls, err)
+	}
+}
@@ -0,0 +1,241 @@
+package dynamo
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/crowdmob/goamz/cloudwatch"
+)
+
+const maxDatapoints = 1440 // Per GetMetricStatistics request.
+
+// Operations reported in the Operation dimension of the per-operation metrics.
+var metricOperations = []string{
+	GetItemEndpoint, PutItemEndpoint, UpdateItemEndpoint, DeleteItemEndpoint,
+	BatchGetItemEndpoint, BatchWriteItemEndpoint, QueryEndpoint, ScanEndpoint,
+}
+
+// MetricPoint is the value of a metric over the period starting at Time.
+type MetricPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// MetricSeries is a metric over time, oldest first.
+type MetricSeries []MetricPoint
+
+// Utilization compares consumed to provisioned capacity, in units per second.
+type Utilization struct {
+	Provisioned    float64
+	Average        float64
+	Peak           float64 // Highest average over a single period.
+	AveragePercent float64
+	PeakPercent    float64
+}
+
+// CapacityMetrics is the consumed capacity of a table or index, in units per second.
+type CapacityMetrics struct {
+	Read, Write                       MetricSeries
+	ReadThrottleEvents                MetricSeries
+	WriteThrottleEvents               MetricSeries
+	ReadUtilization, WriteUtilization Utilization
+}
+
+// TableMetrics is the CloudWatch metrics of a table and its global secondary indexes.
+type TableMetrics struct {
+	Table      string
+	Start, End time.Time
+	Period     int // Seconds covered by each point.
+	CapacityMetrics
+	Indexes map[string]*CapacityMetrics
+
+	// By operation, i.e. PutItemEndpoint. Operations without data are left out.
+	ThrottledRequests map[string]MetricSeries // Total per period.
+	Latency           map[string]MetricSeries // Average milliseconds.
+	ReturnedItemCount map[string]MetricSeries // Total per period, only for Query and Scan.
+}
+
+// TableMetrics fetches the metrics of table and its global secondary indexes for the window up to now.
+func (c *Client) TableMetrics(table string, window time.Duration) (*TableMetrics, error) {
+	desc, err := c.DescribeTable(table)
+	if err != nil {
+		return nil, err
+	}
+	end := time.Now().UTC().Truncate(time.Minute)
+	m := &TableMetrics{
+		Table:             table,
+		Start:             end.Add(-window),
+		End:               end,
+		Period:            metricPeriod(window),
+		Indexes:           map[string]*CapacityMetrics{},
+		ThrottledRequests: map[string]MetricSeries{},
+		Latency:           map[string]MetricSeries{},
+		ReturnedItemCount: map[string]MetricSeries{},
+	}
+	f := metricFetcher{c: c, m: m}
+	f.capacity(&m.CapacityMetrics, "")
+	for _, gsi := range desc.GlobalSecondaryIndexes {
+		cm := &CapacityMetrics{}
+		m.Indexes[gsi.IndexName] = cm
+		f.capacity(cm, gsi.IndexName)
+	}
+	for _, op := range metricOperations {
+		f.operation(m.ThrottledRequests, MetricThrottledRequests, StatisticSum, op, 1)
+		f.operation(m.Latency, MetricLatency, StatisticAverage, op, 1)
+	}
+	for _, op := range []string{QueryEndpoint, ScanEndpoint} {
+		f.operation(m.ReturnedItemCount, MetricReturnedItemCount, StatisticSum, op, 1)
+	}
+	f.wg.Wait()
+	if f.err != nil {
+		return nil, f.err
+	}
+	m.ReadUtilization = utilization(m.Read, float64(desc.ProvisionedThroughput.ReadUnits))
+	m.WriteUtilization = utilization(m.Write, float64(desc.ProvisionedThroughput.WriteUnits))
+	for _, gsi := range desc.GlobalSecondaryIndexes {
+		cm := m.Indexes[gsi.IndexName]
+		cm.ReadUtilization = utilization(cm.Read, float64(gsi.ProvisionedThroughput.ReadUnits))
+		cm.WriteUtilization = utilization(cm.Write, float64(gsi.ProvisionedThroughput.WriteUnits))
+	}
+	return m, nil
+}
+
+// metricFetcher fetches metrics concurrently, keeping the first error.
+type metricFetcher struct {
+	c   *Client
+	m   *TableMetrics
+	wg  sync.WaitGroup
+	mu  sync.Mutex
+	err error
+}
+
+func (f *metricFetcher) capacity(cm *CapacityMetrics, index string) {
+	perSecond := 1 / float64(f.m.Period)
+	f.fetch(&cm.Read, MetricConsumedReadCapacity, StatisticSum, index, "", perSecond)
+	f.fetch(&cm.Write, MetricConsumedWriteCapacity, StatisticSum, index, "", perSecond)
+	f.fetch(&cm.ReadThrottleEvents, MetricReadThrottleEvents, StatisticSum, index, "", 1)
+	f.fetch(&cm.WriteThrottleEvents, MetricWriteThrottleEvents, StatisticSum, index, "", 1)
+}
+
+func (f *metricFetcher) operation(dst map[string]MetricSeries, metric, statistic, op string, scale float64) {
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		series, err := f.c.metricSeries(f.m, metric, statistic, "", op, scale)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if err != nil && f.err == nil {
+			f.err = err
+		} else if len(series) > 0 {
+			dst[op] = series
+		}
+	}()
+}
+
+func (f *metricFetcher) fetch(dst *MetricSeries, metric, statistic, index, op string, scale float64) {
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		series, err := f.c.metricSeries(f.m, metric, statistic, index, op, scale)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if err != nil && f.err == nil {
+			f.err = err
+		}
+		*dst = series
+	}()
+}
+
+// metricSeries fetches a single metric, multiplying every value by scale.
+func (c *Client) metricSeries(m *TableMetrics, metric, statistic, index, op string, scale float64) (MetricSeries, error) {
+	cw, err := c.cloudWatch()
+	if err != nil {
+		return nil, err
+	}
+	res, err := cw.GetMetricStatistics(&cloudwatch.GetMetricStatisticsRequest{
+		Dimensions: metricDimensions(m.Table, index, op),
+		StartTime:  m.Start,
+		EndTime:    m.End,
+		MetricName: metric,
+		Period:     m.Period,
+		Statistics: []string{statistic},
+		Namespace:  cloudWatchNamespace,
+	})
+	if err != nil || res == nil {
+		return nil, err
+	}
+	series := make(MetricSeries, len(res.GetMetricStatisticsResult.Datapoints))
+	for i, d := range res.GetMetricStatisticsResult.Datapoints {
+		v := d.Sum
+		switch statistic {
+		case StatisticAverage:
+			v = d.Average
+		case StatisticMaximum:
+			v = d.Maximum
+		case StatisticMinimum:
+			v = d.Minimum
+		case StatisticSampleCount:
+			v = d.SampleCount
+		}
+		series[i] = MetricPoint{Time: d.Timestamp, Value: v * scale}
+	}
+	sort.Sort(series)
+	return series, nil
+}
+
+func (s MetricSeries) Len() int           { return len(s) }
+func (s MetricSeries) Less(i, j int) bool { return s[i].Time.Before(s[j].Time) }
+func (s MetricSeries) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// Average returns the average value of the series. Periods without data aren't counted.
+func (s MetricSeries) Average() float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, p := range s {
+		sum += p.Value
+	}
+	return sum / float64(len(s))
+}
+
+// Max returns the highest value of the series.
+func (s MetricSeries) Max() float64 {
+	max := 0.0
+	for _, p := range s {
+		if p.Value > max {
+			max = p.Value
+		}
+	}
+	return max
+}
+
+// utilization compares a consumed capacity series in units per second to the provisioned units per second.
+func utilization(consumed MetricSeries, provisioned float64) Utilization {
+	u := Utilization{Provisioned: provisioned, Average: consumed.Average(), Peak: consumed.Max()}
+	if provisioned > 0 {
+		u.AveragePercent = u.Average / provisioned * 100
+		u.PeakPercent = u.Peak / provisioned * 100
+	}
+	return u
+}
+
+// metricPeriod returns the shortest period, a multiple of a minute, that covers window up to now in a single request.
+// CloudWatch only keeps 5 minute data after 15 days and hourly data after 63 days, so windows starting earlier use
+// multiples of those.
+func metricPeriod(window time.Duration) int {
+	minutes := int(window/time.Minute+maxDatapoints-1) / maxDatapoints
+	if minutes < 1 {
+		minutes = 1
+	}
+	granularity := 1
+	switch {
+	case window > 63*24*time.Hour:
+		granularity = 60
+	case window > 15*24*time.Hour:
+		granularity = 5
+	}
+	minutes = (minutes + granularity - 1) / granularity * granularity
+	return minutes * 60
+}
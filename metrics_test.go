@@ -0,0 +1,81 @@
+package dynamo
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUtilization(t *testing.T) {
+	now := time.Now()
+	consumed := MetricSeries{{now, 20}, {now.Add(time.Minute), 60}, {now.Add(2 * time.Minute), 40}}
+	u := utilization(consumed, 100)
+	if u.Average != 40 || u.Peak != 60 || u.AveragePercent != 40 || u.PeakPercent != 60 {
+		t.Errorf("Unexpected utilization %+v", u)
+	}
+	if p := metricPeriod(time.Hour); p != 60 {
+		t.Errorf("Expected 60 second period for an hour, got %d", p)
+	}
+	if p := metricPeriod(7 * 24 * time.Hour); p != 7*60 {
+		t.Errorf("Expected 7 minute period for a week, got %d", p)
+	}
+	if p := metricPeriod(16 * 24 * time.Hour); p != 20*60 {
+		t.Errorf("Expected 20 minute period for 16 days, got %d", p)
+	}
+	if p := metricPeriod(90 * 24 * time.Hour); p != 2*3600 {
+		t.Errorf("Expected 2 hour period for 90 days, got %d", p)
+	}
+}
+
+func TestTableMetrics(t *testing.T) {
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(TableDescriptionWrapper{Table: TableDescription{
+			TableName:              "users",
+			ProvisionedThroughput:  Throughput{ReadUnits: 20, WriteUnits: 5},
+			GlobalSecondaryIndexes: []GlobalSecondaryIndex{{IndexName: "by-email", ProvisionedThroughput: Throughput{ReadUnits: 10}}},
+		}})
+	})
+	defer s.Close()
+	cw := &queryCloudWatch{body: func(params map[string]string) string {
+		points := ""
+		switch {
+		case params["MetricName"] == MetricConsumedReadCapacity && len(params["Dimensions.member.2.Name"]) == 0:
+			points = `<member><Timestamp>2014-03-01T12:01:00Z</Timestamp><Sum>1200</Sum></member>
+				<member><Timestamp>2014-03-01T12:00:00Z</Timestamp><Sum>600</Sum></member>`
+		case params["MetricName"] == MetricLatency && params["Dimensions.member.2.Value"] == GetItemEndpoint:
+			points = `<member><Timestamp>2014-03-01T12:00:00Z</Timestamp><Average>4.5</Average></member>`
+		}
+		return `<GetMetricStatisticsResponse><GetMetricStatisticsResult><Datapoints>` + points +
+			`</Datapoints></GetMetricStatisticsResult></GetMetricStatisticsResponse>`
+	}}
+	c.cw.Service = cw
+
+	m, err := c.TableMetrics("users", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Period != 60 || len(m.Read) != 2 || m.Read[0].Value != 10 || m.Read[1].Value != 20 || len(m.Indexes["by-email"].Read) != 0 {
+		t.Errorf("Unexpected read metrics %+v", m)
+	}
+	if u := m.ReadUtilization; u.Provisioned != 20 || u.Average != 15 || u.PeakPercent != 100 {
+		t.Errorf("Unexpected read utilization %+v", u)
+	}
+	if len(m.Latency) != 1 || m.Latency[GetItemEndpoint][0].Value != 4.5 || len(m.ThrottledRequests) != 0 {
+		t.Errorf("Unexpected operation metrics %+v", m.Latency)
+	}
+
+	// Capacity of the table and the index, then the operations.
+	if len(cw.params) != 8+2*len(metricOperations)+2 {
+		t.Errorf("Expected a request per metric, got %d", len(cw.params))
+	}
+	for _, p := range cw.params {
+		if p["Action"] != "GetMetricStatistics" || p["Namespace"] != "AWS/DynamoDB" || p["Period"] != "60" ||
+			p["Dimensions.member.1.Value"] != "users" || len(p["Statistics.member.1"]) == 0 {
+			t.Errorf("Unexpected request %v", p)
+		}
+		if p["Dimensions.member.2.Name"] == "GlobalSecondaryIndexName" && p["Dimensions.member.2.Value"] != "by-email" {
+			t.Errorf("Unexpected index request %v", p)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package dynamo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Handler sends a request, returning the response or the error DynamoDB returned.
+type Handler func(r *Request) (*http.Response, error)
+
+// Middleware wraps the sending of requests, i.e. to log, trace, time, alter or fail them. It's given the next
+// handler of the chain and returns a handler that, in most cases, calls it:
+//
+//	c.Use(func(next Handler) Handler {
+//		return func(r *Request) (*http.Response, error) {
+//			start := time.Now()
+//			res, err := next(r)
+//			log.Printf("%s took %v: %v", r.Operation(), time.Since(start), err)
+//			return res, err
+//		}
+//	})
+type Middleware func(next Handler) Handler
+
+// Use adds middleware to the client. Requests pass through middleware in the order it was added, before being signed
+// and sent, so changes made by middleware are signed. It isn't safe to call Use while requests are being made.
+func (c *Client) Use(m ...Middleware) {
+	c.middleware = append(c.middleware, m...)
+}
+
+// Operation returns the name of the operation, i.e. PutItemEndpoint.
+func (r *Request) Operation() string {
+	return strings.TrimPrefix(r.req.Header.Get("X-Amz-Target"), DynamoBaseEndpoint)
+}
+
+// Payload returns the JSON body of the request. It must not be modified, use SetContentBytes instead.
+func (r *Request) Payload() []byte {
+	return r.body
+}
+
+// Header returns the headers of the request.
+func (r *Request) Header() http.Header {
+	return r.req.Header
+}
+
+// resetBody rewinds the body so the request can be sent again.
+func (r *Request) resetBody() {
+	r.req.Body = ioutil.NopCloser(bytes.NewReader(r.body))
+	r.req.ContentLength = int64(len(r.body))
+}
@@ -0,0 +1,51 @@
+package dynamo
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var got string
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		b := make([]byte, r.ContentLength)
+		r.Body.Read(b)
+		got = string(b)
+		w.Write([]byte(`{}`))
+	})
+	defer s.Close()
+
+	var ops []string
+	c.Use(func(next Handler) Handler {
+		return func(r *Request) (*http.Response, error) {
+			ops = append(ops, r.Operation())
+			return next(r)
+		}
+	}, func(next Handler) Handler {
+		return func(r *Request) (*http.Response, error) {
+			r.SetContentString(strings.Replace(string(r.Payload()), "first", "second", 1))
+			return next(r)
+		}
+	})
+	if err := c.PutItem("things", versionedDoc{Id: "a", Name: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 1 || ops[0] != PutItemEndpoint {
+		t.Errorf("Expected PutItem to be seen by middleware, saw %v", ops)
+	}
+	if !strings.Contains(got, "second") {
+		t.Errorf("Expected payload to be altered, got %s", got)
+	}
+
+	injected := errors.New("injected")
+	c.Use(func(next Handler) Handler {
+		return func(r *Request) (*http.Response, error) {
+			return nil, injected
+		}
+	})
+	if err := c.PutItem("things", versionedDoc{Id: "a", Name: "first"}); err != injected {
+		t.Errorf("Expected injected error, got %v", err)
+	}
+}
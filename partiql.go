@@ -0,0 +1,171 @@
+package dynamo
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+)
+
+// Statement is a PartiQL statement with the values bound to its ? placeholders, in order. Values are marshaled like
+// the fields of MarshalAttributes; AttributeVals are passed as they are.
+type Statement struct {
+	Statement string
+	Params    []interface{}
+}
+
+// ExecuteStatement runs a PartiQL statement with params bound to its ? placeholders, returning a page of items and
+// the token to pass to get the next page, "" after the last page.
+//
+//	items, next, err := c.ExecuteStatement(`SELECT * FROM "users" WHERE id = ?`, "", "u1")
+func (c *Client) ExecuteStatement(stmt, nextToken string, params ...interface{}) ([]AttributeSet, string, error) {
+	vals, err := bindParams(stmt, params)
+	if err != nil {
+		return nil, "", err
+	}
+	req := ExecuteStatementRequest{
+		Statement:              stmt,
+		Parameters:             vals,
+		NextToken:              nextToken,
+		ReturnConsumedCapacity: c.returnConsumed(),
+	}
+	res := ExecuteStatementResponse{}
+	err = c.makeRequest(ExecuteStatementEndpoint, req, &res)
+	return res.Items, res.NextToken, err
+}
+
+// ExecuteStatementInto runs a PartiQL statement, reading every page, and unmarshals the items into dst, a ptr to a
+// slice of structs or of ptrs to structs.
+func (c *Client) ExecuteStatementInto(stmt string, dst interface{}, params ...interface{}) error {
+	all := []AttributeSet{}
+	next := ""
+	for {
+		items, token, err := c.ExecuteStatement(stmt, next, params...)
+		if err != nil {
+			return err
+		}
+		all = append(all, items...)
+		if len(token) == 0 {
+			break
+		}
+		next = token
+	}
+	return unmarshalItems(all, dst)
+}
+
+// BatchExecuteStatement runs up to 25 statements, reads or writes but not both. Statements fail independently: the
+// response of each holds its item or its error.
+func (c *Client) BatchExecuteStatement(stmts []Statement) ([]BatchStatementResponse, error) {
+	if len(stmts) > BatchWriteItemLimit {
+		return nil, fmt.Errorf("Maximum of %d statements for batches exceeded", BatchWriteItemLimit)
+	}
+	req := BatchExecuteStatementRequest{ReturnConsumedCapacity: c.returnConsumed()}
+	for i, s := range stmts {
+		vals, err := bindParams(s.Statement, s.Params)
+		if err != nil {
+			return nil, fmt.Errorf("Statement %d: %s", i, err.Error())
+		}
+		req.Statements = append(req.Statements, BatchStatementRequest{Statement: s.Statement, Parameters: vals})
+	}
+	res := BatchExecuteStatementResponse{}
+	err := c.makeRequest(BatchExecuteStatementEndpoint, req, &res)
+	return res.Responses, err
+}
+
+// ExecuteTransaction runs statements all or nothing, returning the items of reads. A cancelled transaction returns a
+// *TxCanceledError.
+func (c *Client) ExecuteTransaction(stmts []Statement) ([]AttributeSet, error) {
+	if len(stmts) == 0 {
+		return nil, errors.New("Transaction has no statements")
+	} else if len(stmts) > TransactItemLimit {
+		return nil, fmt.Errorf("Maximum of %d item limit for transactions exceeded", TransactItemLimit)
+	}
+	token, err := newRequestToken()
+	if err != nil {
+		return nil, err
+	}
+	req := ExecuteTransactionRequest{ClientRequestToken: token, ReturnConsumedCapacity: c.returnConsumed()}
+	for i, s := range stmts {
+		vals, err := bindParams(s.Statement, s.Params)
+		if err != nil {
+			return nil, fmt.Errorf("Statement %d: %s", i, err.Error())
+		}
+		req.TransactStatements = append(req.TransactStatements, ParameterizedStatement{Statement: s.Statement, Parameters: vals})
+	}
+	res := ExecuteTransactionResponse{}
+	if err := c.makeRequest(ExecuteTransactionEndpoint, req, &res); err != nil {
+		return nil, txError(err, nil)
+	}
+	items := make([]AttributeSet, len(res.Responses))
+	for i, r := range res.Responses {
+		items[i] = r.Item
+	}
+	return items, nil
+}
+
+// bindParams marshals the values of the placeholders of stmt, checking there's one for each.
+func bindParams(stmt string, params []interface{}) ([]AttributeVal, error) {
+	if n := countPlaceholders(stmt); n != len(params) {
+		return nil, fmt.Errorf("Statement has %d placeholders but %d parameters were given", n, len(params))
+	}
+	vals := make([]AttributeVal, len(params))
+	for i, p := range params {
+		val, err := marshalParam(p)
+		if err != nil {
+			return nil, fmt.Errorf("Parameter %d: %s", i, err.Error())
+		}
+		vals[i] = val
+	}
+	return vals, nil
+}
+
+func marshalParam(p interface{}) (val AttributeVal, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+			err = fmt.Errorf("Error: %v", r)
+		}
+	}()
+	if v, ok := p.(AttributeVal); ok {
+		val = v
+	} else {
+		v := reflect.ValueOf(p)
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Invalid, reflect.Ptr:
+			return val, errors.New("Nil values can't be bound")
+		case reflect.Array, reflect.Slice, reflect.Map, reflect.String:
+			if v.Len() == 0 {
+				return val, errors.New("Empty values can't be bound")
+			}
+		}
+		val = getAttribute(v)
+	}
+	if !val.IsValid() {
+		return val, errors.New("Empty values can't be bound")
+	}
+	return val, nil
+}
+
+// countPlaceholders counts the ? of stmt outside of quotes.
+func countPlaceholders(stmt string) int {
+	n := 0
+	var quote rune
+	for _, r := range stmt {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,26 @@
+package dynamo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBindParams(t *testing.T) {
+	id := "a"
+	vals, err := bindParams(`SELECT * FROM "what?" WHERE id = ? AND n > ? AND tags = ? AND note <> 'really?'`, []interface{}{&id, 5, []string{"x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []AttributeVal{{S: "a"}, {N: "5"}, {SS: []string{"x"}}}
+	if !reflect.DeepEqual(vals, expected) {
+		t.Errorf("Bound %+v", vals)
+	}
+	if _, err := bindParams("SELECT * FROM t WHERE id = ?", nil); err == nil || err.Error() != "Statement has 1 placeholders but 0 parameters were given" {
+		t.Errorf("Unexpected error %v", err)
+	}
+	for _, p := range []interface{}{nil, (*string)(nil), "", []string{}} {
+		if _, err := bindParams("SELECT * FROM t WHERE id = ?", []interface{}{p}); err == nil {
+			t.Errorf("Bound %#v", p)
+		}
+	}
+}
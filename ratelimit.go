@@ -0,0 +1,251 @@
+package dynamo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// After being throttled the rate is multiplied by throttleBackoff, but not lowered below minRateFraction of the
+	// target. Every successful request recovers recoverFraction of the target rate.
+	throttleBackoff = 0.5
+	minRateFraction = 0.05
+	recoverFraction = 0.02
+)
+
+// Endpoints that consume write and read capacity, respectively. Requests to other endpoints aren't limited.
+var (
+	writeEndpoints = map[string]bool{
+		PutItemEndpoint:        true,
+		UpdateItemEndpoint:     true,
+		DeleteItemEndpoint:     true,
+		BatchWriteItemEndpoint: true,
+		TransactWriteEndpoint:  true,
+	}
+	readEndpoints = map[string]bool{
+		GetItemEndpoint:      true,
+		BatchGetItemEndpoint: true,
+		QueryEndpoint:        true,
+		ScanEndpoint:         true,
+		TransactGetEndpoint:  true,
+	}
+)
+
+// RateLimiter caps the read and write capacity units the client consumes per table, using a token bucket per table
+// that is charged with the ConsumedCapacity reported by DynamoDB. When a request is throttled the rate for that table
+// is halved, then recovers gradually towards the configured rate.
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[bucketKey]*bucket
+	now     func() time.Time
+	sleep   func(time.Duration)
+}
+
+type bucketKey struct {
+	table string
+	write bool
+}
+
+type bucket struct {
+	target float64 // Capacity units per second allowed.
+	rate   float64 // Current rate, lowered after throttling.
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		buckets: map[bucketKey]*bucket{},
+		now:     time.Now,
+		sleep:   time.Sleep,
+	}
+}
+
+// SetRate limits table to read and write capacity units per second. A rate of 0 leaves it unlimited.
+func (l *RateLimiter) SetRate(table string, read, write float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.setRate(bucketKey{table, false}, read)
+	l.setRate(bucketKey{table, true}, write)
+}
+
+// SetReadRate limits the read capacity units per second of table, leaving its write rate alone.
+func (l *RateLimiter) SetReadRate(table string, read float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.setRate(bucketKey{table, false}, read)
+}
+
+// SetWriteRate limits the write capacity units per second of table, leaving its read rate alone.
+func (l *RateLimiter) SetWriteRate(table string, write float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.setRate(bucketKey{table, true}, write)
+}
+
+// Rate returns the current read and write rates of table, which are lower than the configured rates after throttling.
+func (l *RateLimiter) Rate(table string) (read, write float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b := l.buckets[bucketKey{table, false}]; b != nil {
+		read = b.rate
+	}
+	if b := l.buckets[bucketKey{table, true}]; b != nil {
+		write = b.rate
+	}
+	return
+}
+
+func (l *RateLimiter) setRate(key bucketKey, rate float64) {
+	if rate <= 0 {
+		delete(l.buckets, key)
+		return
+	}
+	if b := l.buckets[key]; b != nil {
+		b.target, b.rate = rate, math.Min(b.rate, rate)
+		return
+	}
+	l.buckets[key] = &bucket{target: rate, rate: rate, tokens: rate, last: l.now()}
+}
+
+// SetRateLimiter makes the client wait for capacity before reading or writing tables limited by l, and makes every
+// request return its consumed capacity so l can be charged for it. A nil limiter removes the limits.
+func (c *Client) SetRateLimiter(l *RateLimiter) {
+	c.limiter = l
+}
+
+// WithRateLimiter returns a copy of the client that uses l instead of the client's rate limiter, so a job can limit its
+// own requests without changing the limits of everyone else sharing the client. The copy shares the client's
+// credentials, so credentials refreshed by either are used by both.
+func (c *Client) WithRateLimiter(l *RateLimiter) *Client {
+	cc := *c
+	cc.limiter = l
+	return &cc
+}
+
+// LimitThroughput caps the client to fraction (0 < fraction <= 1) of the provisioned throughput of table, so
+// background jobs can leave capacity for production traffic.
+func (c *Client) LimitThroughput(table string, fraction float64) error {
+	read, write, err := c.throughput(table, fraction)
+	if err != nil {
+		return err
+	}
+	if c.limiter == nil {
+		c.limiter = NewRateLimiter()
+	}
+	c.limiter.SetRate(table, read, write)
+	return nil
+}
+
+// ThroughputLimiter returns a new rate limiter capped to fraction (0 < fraction <= 1) of the provisioned throughput of
+// table, for use with WithRateLimiter.
+func (c *Client) ThroughputLimiter(table string, fraction float64) (*RateLimiter, error) {
+	read, write, err := c.throughput(table, fraction)
+	if err != nil {
+		return nil, err
+	}
+	l := NewRateLimiter()
+	l.SetRate(table, read, write)
+	return l, nil
+}
+
+// throughput returns fraction of the provisioned read and write throughput of table.
+func (c *Client) throughput(table string, fraction float64) (float64, float64, error) {
+	if fraction <= 0 || fraction > 1 {
+		return 0, 0, fmt.Errorf("Throughput fraction must be between 0 and 1, was %v", fraction)
+	}
+	desc, err := c.DescribeTable(table)
+	if err != nil {
+		return 0, 0, err
+	}
+	t := desc.ProvisionedThroughput
+	if t.ReadUnits == 0 && t.WriteUnits == 0 {
+		return 0, 0, errors.New("Table has no provisioned throughput to limit")
+	}
+	return fraction * float64(t.ReadUnits), fraction * float64(t.WriteUnits), nil
+}
+
+// request waits until the tables in data have capacity left, does the request, and charges the consumed capacity.
+func (l *RateLimiter) request(endpoint string, data, dst interface{}, do func(string, interface{}, interface{}) error) error {
+	write := writeEndpoints[endpoint]
+	if !write && !readEndpoints[endpoint] {
+		return do(endpoint, data, dst)
+	}
+	tr, ok := data.(tableRequest)
+	if !ok {
+		return do(endpoint, data, dst)
+	}
+	tables := tr.tableNames()
+	for _, t := range tables {
+		l.wait(bucketKey{t, write})
+	}
+	err := do(endpoint, data, dst)
+	if IsErrorCode(err, ProvisionedThroughputExceededException) || IsErrorCode(err, ThrottlingException) {
+		for _, t := range tables {
+			l.throttled(bucketKey{t, write})
+		}
+		return err
+	}
+	var consumed []ConsumedStats
+	if cr, ok := dst.(capacityResponse); ok && err == nil {
+		consumed = cr.consumed()
+	}
+	if len(consumed) == 0 {
+		// Capacity wasn't returned, so charge the minimum of one unit per table.
+		for _, t := range tables {
+			consumed = append(consumed, ConsumedStats{TableName: t, CapacityUnits: 1})
+		}
+	}
+	for _, s := range consumed {
+		l.consume(bucketKey{s.TableName, write}, s.CapacityUnits)
+	}
+	return err
+}
+
+func (l *RateLimiter) wait(key bucketKey) {
+	for {
+		l.mu.Lock()
+		b := l.buckets[key]
+		if b == nil {
+			l.mu.Unlock()
+			return
+		}
+		b.refill(l.now())
+		if b.tokens > 0 {
+			l.mu.Unlock()
+			return
+		}
+		d := time.Duration(-b.tokens/b.rate*float64(time.Second)) + time.Millisecond
+		l.mu.Unlock()
+		l.sleep(d)
+	}
+}
+
+func (l *RateLimiter) consume(key bucketKey, units float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b := l.buckets[key]; b != nil {
+		b.refill(l.now())
+		b.tokens -= units
+		b.rate = math.Min(b.target, b.rate+b.target*recoverFraction)
+	}
+}
+
+func (l *RateLimiter) throttled(key bucketKey) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b := l.buckets[key]; b != nil {
+		b.refill(l.now())
+		b.rate = math.Max(b.rate*throttleBackoff, b.target*minRateFraction)
+		b.tokens = math.Min(b.tokens, 0)
+	}
+}
+
+// refill adds tokens for the time passed since the last refill, allowing bursts of up to one second.
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(b.tokens+now.Sub(b.last).Seconds()*b.rate, b.rate)
+	b.last = now
+}
@@ -0,0 +1,105 @@
+package dynamo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crowdmob/goamz/aws"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	var slept time.Duration
+	l := NewRateLimiter()
+	l.now = func() time.Time { return now }
+	l.sleep = func(d time.Duration) {
+		slept += d
+		now = now.Add(d)
+	}
+	l.SetRate("things", 10, 4)
+
+	throttle := false
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if throttle {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ProvisionedThroughputExceededException","message":"Slow down"}`))
+			return
+		}
+		w.Write([]byte(`{"ConsumedCapacity":[{"TableName":"things","CapacityUnits":8}]}`))
+	})
+	defer s.Close()
+	c.SetRateLimiter(l)
+
+	docs := []keyDoc{{"a"}, {"b"}}
+	if _, err := c.BatchWrite("things", docs); err != nil {
+		t.Fatal(err)
+	}
+	if slept != 0 {
+		t.Errorf("First request should not wait, waited %v", slept)
+	}
+	// The bucket is now 4 units in debt, which takes a second to pay back at 4 units per second.
+	if _, err := c.BatchWrite("things", docs); err != nil {
+		t.Fatal(err)
+	}
+	if slept < time.Second || slept > 1100*time.Millisecond {
+		t.Errorf("Expected to wait about a second, waited %v", slept)
+	}
+
+	throttle = true
+	if _, err := c.BatchWrite("things", docs); !IsErrorCode(err, ProvisionedThroughputExceededException) {
+		t.Fatalf("Expected throttling error, got %v", err)
+	}
+	if read, write := l.Rate("things"); read != 10 || write != 2 {
+		t.Errorf("Expected write rate to be halved, rates are %v/%v", read, write)
+	}
+}
+
+func TestThroughputLimiter(t *testing.T) {
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Table":{"TableName":"things","ProvisionedThroughput":{"ReadCapacityUnits":10,"WriteCapacityUnits":4}}}`))
+	})
+	defer s.Close()
+	shared := NewRateLimiter()
+	shared.SetRate("things", 100, 100)
+	c.SetRateLimiter(shared)
+
+	if _, err := c.ThroughputLimiter("things", 1.5); err == nil {
+		t.Error("Expected an error for a fraction over 1")
+	}
+	l, err := c.ThroughputLimiter("things", 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read, write := l.Rate("things"); read != 5 || write != 2 {
+		t.Errorf("Expected half the provisioned throughput, rates are %v/%v", read, write)
+	}
+	if cc := c.WithRateLimiter(l); cc.limiter != l || c.limiter != shared {
+		t.Error("WithRateLimiter should only change the limiter of the copy")
+	}
+	if read, write := shared.Rate("things"); read != 100 || write != 100 {
+		t.Errorf("Shared limiter changed to %v/%v", read, write)
+	}
+}
+
+func TestWithRateLimiterCredentials(t *testing.T) {
+	var auth string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte(`{}`))
+	}))
+	defer s.Close()
+	region := aws.USEast
+	region.DynamoDBEndpoint = s.URL
+	c, err := NewClientWithCredentials(&rotatingProvider{}, region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.WithRateLimiter(NewRateLimiter()).PutItem("things", keyDoc{"a"})
+	c.PutItem("things", keyDoc{"a"})
+	if !strings.Contains(auth, "Credential=key2") {
+		t.Errorf("Client signed with %q after its copy refreshed the credentials", auth)
+	}
+}
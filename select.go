@@ -0,0 +1,544 @@
+package dynamo
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Select is a parsed SELECT statement:
+//
+//	SELECT * | attr [, attr]... FROM table [INDEX index] [WHERE predicate [AND predicate]...] [LIMIT n]
+//
+// Predicates are attr = value, with the operators =, <> (or !=), <, <=, >, >=, BEGINS_WITH and CONTAINS, as well
+// as attr BETWEEN value AND value, attr IN (value, ...), attr IS [NOT] NULL, begins_with(attr, value) and
+// contains(attr, value). Values are 'strings' or numbers. Keywords are case insensitive, and attribute names can be
+// quoted with backticks.
+type Select struct {
+	Attributes []string // Attributes to get, all if empty.
+	Table      string
+	Index      string      // Index to query. If empty, Plan picks the table or an index projecting all attributes.
+	Where      []Predicate // Joined by AND.
+	Limit      int         // Maximum number of items, unlimited if 0.
+}
+
+// Predicate is a condition on an attribute of a WHERE clause.
+type Predicate struct {
+	Attribute string
+	Condition
+}
+
+// Plan is how a Select statement runs: a query when its predicates are all key conditions, a scan otherwise.
+type Plan struct {
+	Query       *Query       // Set when the statement is served by a query,
+	Scan        *ScanRequest // or else by a scan with the predicates as filter.
+	Limit       int
+	Explanation string // Why the query or scan was chosen.
+}
+
+// Explain parses a SELECT statement and plans it against the description of its table.
+func (c *Client) Explain(stmt string) (Plan, error) {
+	s, err := ParseSelect(stmt)
+	if err != nil {
+		return Plan{}, err
+	}
+	desc, err := c.DescribeTable(s.Table)
+	if err != nil {
+		return Plan{}, err
+	}
+	return s.Plan(desc)
+}
+
+// Select runs a SELECT statement, reading pages until its limit or the last page.
+//
+//	items, err := c.Select("SELECT * FROM users WHERE id = 'u1' AND created > 1400000000 LIMIT 10")
+func (c *Client) Select(stmt string) ([]AttributeSet, error) {
+	p, err := c.Explain(stmt)
+	if err != nil {
+		return nil, err
+	}
+	items := []AttributeSet{}
+	var start AttributeSet
+	for {
+		page, last, err := c.RunPlan(p, start)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page...)
+		if len(last) == 0 || (p.Limit > 0 && len(items) >= p.Limit) {
+			break
+		}
+		start = last
+	}
+	if p.Limit > 0 && len(items) > p.Limit {
+		items = items[:p.Limit]
+	}
+	return items, nil
+}
+
+// SelectInto runs a SELECT statement like Select, unmarshaling the items into dst, a ptr to a slice of structs or
+// of ptrs to structs.
+func (c *Client) SelectInto(stmt string, dst interface{}) error {
+	items, err := c.Select(stmt)
+	if err != nil {
+		return err
+	}
+	return unmarshalItems(items, dst)
+}
+
+// RunPlan runs a single page of a plan from start, the first page if nil, returning its items and the key to start
+// the next page from, nil after the last page.
+func (c *Client) RunPlan(p Plan, start AttributeSet) ([]AttributeSet, AttributeSet, error) {
+	if p.Query != nil {
+		q := *p.Query
+		q.ExclusiveStartKey = start
+		return c.RawQuery(q)
+	}
+	s := *p.Scan
+	s.ExclusiveStartKey = start
+	return c.RawScan(s)
+}
+
+// Plan compiles the statement to a query on the table, or else on an index projecting all attributes, when its
+// predicates are an equality on the hash key and at most one key condition on the range key. Otherwise it compiles
+// to a scan of the table. Values are converted to the types of the attribute definitions of desc, so that 5 matches a
+// string key "5".
+func (s Select) Plan(desc TableDescription) (Plan, error) {
+	types := map[string]string{}
+	for _, def := range desc.AttributeDefinitions {
+		types[def.Name] = def.Type
+	}
+	conds := map[string]Condition{}
+	for _, p := range s.Where {
+		if _, ok := conds[p.Attribute]; ok {
+			return Plan{}, fmt.Errorf("More than one predicate on %s", p.Attribute)
+		}
+		cond := Condition{ComparisonOperator: p.ComparisonOperator}
+		for _, val := range p.AttributeValueList {
+			cond.AttributeValueList = append(cond.AttributeValueList, convertValue(val, types[p.Attribute]))
+		}
+		conds[p.Attribute] = cond
+	}
+	names := make([]string, 0, len(conds))
+	for name := range conds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	plan := Plan{Limit: s.Limit}
+
+	// The table first, then the indexes in the order they're described.
+	type candidate struct {
+		index  string
+		schema []Key
+	}
+	candidates := []candidate{}
+	if len(s.Index) == 0 {
+		candidates = append(candidates, candidate{"", desc.KeySchema})
+	}
+	for _, idx := range desc.LocalSecondaryIndexes {
+		if idx.IndexName == s.Index || (len(s.Index) == 0 && idx.Projection.ProjectionType == ProjectionAll) {
+			candidates = append(candidates, candidate{idx.IndexName, idx.KeySchema})
+		}
+	}
+	for _, idx := range desc.GlobalSecondaryIndexes {
+		if idx.IndexName == s.Index || (len(s.Index) == 0 && idx.Projection.ProjectionType == ProjectionAll) {
+			candidates = append(candidates, candidate{idx.IndexName, idx.KeySchema})
+		}
+	}
+	if len(candidates) == 0 {
+		return plan, fmt.Errorf("Table %s has no index %s", s.Table, s.Index)
+	}
+	reason := ""
+	for i, cand := range candidates {
+		r := keyConditionsReason(cand.schema, conds)
+		if i == 0 {
+			reason = r
+		}
+		if len(r) > 0 {
+			continue
+		}
+		plan.Query = &Query{
+			TableName:       s.Table,
+			IndexName:       cand.index,
+			AttributesToGet: s.Attributes,
+			KeyConditions:   conds,
+			Limit:           s.Limit,
+		}
+		on := s.Table
+		if len(cand.index) > 0 {
+			on = "index " + cand.index + " of " + s.Table
+		}
+		plan.Explanation = fmt.Sprintf("Query on %s with key conditions on %s", on, strings.Join(names, ", "))
+		return plan, nil
+	}
+	if len(s.Index) > 0 {
+		return plan, fmt.Errorf("Index %s can't serve the WHERE clause: %s", s.Index, reason)
+	}
+	plan.Scan = &ScanRequest{TableName: s.Table, AttributesToGet: s.Attributes, TotalSegments: 1}
+	plan.Explanation = "Scan of " + s.Table
+	if len(conds) > 0 {
+		plan.Scan.ScanFilter = conds
+		plan.Explanation += " filtered on " + strings.Join(names, ", ")
+	}
+	plan.Explanation += ": " + reason
+	return plan, nil
+}
+
+// Operators of key conditions on range keys.
+var rangeKeyOperators = map[string]bool{
+	ConditionEqual: true, ConditionLessThan: true, ConditionLessThanOrEqual: true, ConditionGreaterThan: true,
+	ConditionGreaterThanOrEqual: true, ConditionBeginsWith: true, ConditionBetween: true,
+}
+
+// keyConditionsReason returns why conds can't be the key conditions of a query on schema, or "" if they can.
+func keyConditionsReason(schema []Key, conds map[string]Condition) string {
+	if len(conds) == 0 {
+		return "no WHERE clause"
+	}
+	hash, rng := "", ""
+	for _, k := range schema {
+		if k.Type == TypeHashKey {
+			hash = k.Name
+		} else {
+			rng = k.Name
+		}
+	}
+	if cond, ok := conds[hash]; !ok || cond.ComparisonOperator != ConditionEqual {
+		return "no equality on hash key " + hash
+	}
+	names := make([]string, 0, len(conds))
+	for name := range conds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch op := conds[name].ComparisonOperator; {
+		case name == hash:
+		case name != rng:
+			return name + " isn't a key attribute"
+		case !rangeKeyOperators[op]:
+			return op + " on range key " + name + " isn't a key condition"
+		}
+	}
+	return ""
+}
+
+// convertValue converts a literal to the type of its attribute: numbers to strings, and strings that look like
+// numbers to numbers.
+func convertValue(val AttributeVal, typ string) AttributeVal {
+	switch {
+	case typ == TypeString && len(val.N) > 0:
+		return AttributeVal{S: val.N}
+	case typ == TypeNumber && len(val.S) > 0 && NumberRegex.MatchString(val.S):
+		return AttributeVal{N: val.S}
+	}
+	return val
+}
+
+// ParseSelect parses a SELECT statement.
+func ParseSelect(stmt string) (Select, error) {
+	s := Select{}
+	p := &selectParser{}
+	if err := p.lex(stmt); err != nil {
+		return s, err
+	}
+	if err := p.keyword("SELECT"); err != nil {
+		return s, err
+	}
+	if !p.accept("*") {
+		for {
+			name, err := p.ident("attribute")
+			if err != nil {
+				return s, err
+			}
+			s.Attributes = append(s.Attributes, name)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	var err error
+	if err = p.keyword("FROM"); err != nil {
+		return s, err
+	} else if s.Table, err = p.ident("table"); err != nil {
+		return s, err
+	}
+	if p.acceptKeyword("INDEX") {
+		if s.Index, err = p.ident("index"); err != nil {
+			return s, err
+		}
+	}
+	if p.acceptKeyword("WHERE") {
+		for {
+			pred, err := p.predicate()
+			if err != nil {
+				return s, err
+			}
+			s.Where = append(s.Where, pred)
+			if !p.acceptKeyword("AND") {
+				break
+			}
+		}
+	}
+	if p.acceptKeyword("LIMIT") {
+		t := p.next()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != tokenNumber || err != nil || n <= 0 {
+			return s, p.errorf(t, "positive LIMIT")
+		}
+		s.Limit = n
+	}
+	if t := p.next(); t.kind != tokenEOF {
+		return s, p.errorf(t, "end of statement")
+	}
+	return s, nil
+}
+
+const (
+	tokenEOF = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+type selectParser struct {
+	tokens []token
+	i      int
+}
+
+// lex splits a statement into tokens.
+func (p *selectParser) lex(stmt string) error {
+	runes := []rune(stmt)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '\'' || r == '"' || r == '`':
+			// Quotes are escaped by doubling them.
+			text := []rune{}
+			for i++; ; i++ {
+				if i == len(runes) {
+					return fmt.Errorf("Unterminated quote at position %d", start+1)
+				} else if runes[i] == r && i+1 < len(runes) && runes[i+1] == r {
+					i++
+				} else if runes[i] == r {
+					break
+				}
+				text = append(text, runes[i])
+			}
+			i++
+			kind := tokenString
+			if r == '`' {
+				kind = tokenQuotedIdent
+			}
+			p.tokens = append(p.tokens, token{kind, string(text), start})
+			continue
+		case unicode.IsDigit(r) || ((r == '-' || r == '.') && i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.')):
+			for i++; i < len(runes) && (unicode.IsDigit(runes[i]) || strings.ContainsRune(".eE", runes[i]) ||
+				((runes[i] == '-' || runes[i] == '+') && (runes[i-1] == 'e' || runes[i-1] == 'E'))); i++ {
+			}
+			text := string(runes[start:i])
+			if !NumberRegex.MatchString(text) {
+				return fmt.Errorf("Invalid number %s at position %d", text, start+1)
+			}
+			p.tokens = append(p.tokens, token{tokenNumber, text, start})
+			continue
+		case unicode.IsLetter(r) || r == '_':
+			for i++; i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || strings.ContainsRune("_-.", runes[i])); i++ {
+			}
+			p.tokens = append(p.tokens, token{tokenIdent, string(runes[start:i]), start})
+			continue
+		}
+		for _, sym := range []string{"<=", ">=", "<>", "!=", "=", "<", ">", "(", ")", ",", "*"} {
+			if strings.HasPrefix(string(runes[i:]), sym) {
+				p.tokens = append(p.tokens, token{tokenSymbol, sym, start})
+				i += len(sym)
+				break
+			}
+		}
+		if i == start {
+			return fmt.Errorf("Unexpected %q at position %d", r, start+1)
+		}
+	}
+	p.tokens = append(p.tokens, token{kind: tokenEOF, pos: len(runes)})
+	return nil
+}
+
+func (p *selectParser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *selectParser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *selectParser) errorf(t token, expected string) error {
+	if t.kind == tokenEOF {
+		return fmt.Errorf("Expected %s at the end of the statement", expected)
+	}
+	return fmt.Errorf("Expected %s at position %d, found %q", expected, t.pos+1, t.text)
+}
+
+func (p *selectParser) isKeyword(t token, kw string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.text, kw)
+}
+
+func (p *selectParser) keyword(kw string) error {
+	if t := p.next(); !p.isKeyword(t, kw) {
+		return p.errorf(t, kw)
+	}
+	return nil
+}
+
+func (p *selectParser) acceptKeyword(kw string) bool {
+	if p.isKeyword(p.peek(), kw) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *selectParser) accept(sym string) bool {
+	if t := p.peek(); t.kind == tokenSymbol && t.text == sym {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *selectParser) expect(sym string) error {
+	if t := p.next(); t.kind != tokenSymbol || t.text != sym {
+		return p.errorf(t, sym)
+	}
+	return nil
+}
+
+func (p *selectParser) ident(what string) (string, error) {
+	t := p.next()
+	if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+		return "", p.errorf(t, what)
+	}
+	return t.text, nil
+}
+
+func (p *selectParser) value() (AttributeVal, error) {
+	switch t := p.next(); t.kind {
+	case tokenString:
+		return AttributeVal{S: t.text}, nil
+	case tokenNumber:
+		return AttributeVal{N: t.text}, nil
+	default:
+		return AttributeVal{}, p.errorf(t, "value")
+	}
+}
+
+// Operators followed by a single value.
+var selectOperators = map[string]string{
+	"=":           ConditionEqual,
+	"<>":          ConditionNotEqual,
+	"!=":          ConditionNotEqual,
+	"<":           ConditionLessThan,
+	"<=":          ConditionLessThanOrEqual,
+	">":           ConditionGreaterThan,
+	">=":          ConditionGreaterThanOrEqual,
+	"BEGINS_WITH": ConditionBeginsWith,
+	"CONTAINS":    ConditionContains,
+}
+
+func (p *selectParser) predicate() (Predicate, error) {
+	pred := Predicate{}
+	name, err := p.ident("attribute")
+	if err != nil {
+		return pred, err
+	}
+	if fn := strings.ToUpper(name); p.accept("(") {
+		// begins_with(attr, value) and contains(attr, value).
+		if fn != "BEGINS_WITH" && fn != "CONTAINS" {
+			return pred, fmt.Errorf("Unknown function %s", name)
+		}
+		if pred.Attribute, err = p.ident("attribute"); err != nil {
+			return pred, err
+		} else if err := p.expect(","); err != nil {
+			return pred, err
+		}
+		val, err := p.value()
+		if err != nil {
+			return pred, err
+		}
+		pred.ComparisonOperator, pred.AttributeValueList = selectOperators[fn], []AttributeVal{val}
+		return pred, p.expect(")")
+	}
+	pred.Attribute = name
+	t := p.next()
+	op := t.text
+	if t.kind == tokenIdent {
+		op = strings.ToUpper(op)
+	}
+	switch {
+	case t.kind == tokenEOF:
+		return pred, p.errorf(t, "operator")
+	case op == "BETWEEN":
+		low, err := p.value()
+		if err != nil {
+			return pred, err
+		} else if err := p.keyword("AND"); err != nil {
+			return pred, err
+		}
+		high, err := p.value()
+		pred.ComparisonOperator, pred.AttributeValueList = ConditionBetween, []AttributeVal{low, high}
+		return pred, err
+	case op == "IN":
+		if err := p.expect("("); err != nil {
+			return pred, err
+		}
+		pred.ComparisonOperator = ConditionIn
+		for {
+			val, err := p.value()
+			if err != nil {
+				return pred, err
+			}
+			pred.AttributeValueList = append(pred.AttributeValueList, val)
+			if !p.accept(",") {
+				break
+			}
+		}
+		return pred, p.expect(")")
+	case op == "IS":
+		pred.ComparisonOperator = ConditionAttributeNotExists
+		if p.acceptKeyword("NOT") {
+			pred.ComparisonOperator = ConditionAttributeExists
+		}
+		return pred, p.keyword("NULL")
+	case op == "NOT":
+		if err := p.keyword("CONTAINS"); err != nil {
+			return pred, err
+		}
+		val, err := p.value()
+		pred.ComparisonOperator, pred.AttributeValueList = ConditionNotContains, []AttributeVal{val}
+		return pred, err
+	}
+	cond, ok := selectOperators[op]
+	if !ok || (t.kind != tokenSymbol && t.kind != tokenIdent) {
+		return pred, p.errorf(t, "operator")
+	}
+	val, err := p.value()
+	pred.ComparisonOperator, pred.AttributeValueList = cond, []AttributeVal{val}
+	return pred, err
+}
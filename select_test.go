@@ -0,0 +1,131 @@
+package dynamo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var usersTable = TableDescription{
+	TableName:            "users",
+	KeySchema:            []Key{{Name: "id", Type: TypeHashKey}, {Name: "created", Type: TypeRangeKey}},
+	AttributeDefinitions: []AttributeDefinition{{Name: "id", Type: TypeString}, {Name: "created", Type: TypeNumber}, {Name: "email", Type: TypeString}, {Name: "team", Type: TypeString}},
+	GlobalSecondaryIndexes: []GlobalSecondaryIndex{
+		{IndexName: "byEmail", KeySchema: []Key{{Name: "email", Type: TypeHashKey}}, Projection: IndexProjection{ProjectionType: ProjectionAll}},
+		{IndexName: "byTeam", KeySchema: []Key{{Name: "team", Type: TypeHashKey}}, Projection: IndexProjection{ProjectionType: ProjectionKeysOnly}},
+	},
+}
+
+func TestParseSelect(t *testing.T) {
+	s, err := ParseSelect("select id, `first name` FROM users WHERE id = 'it''s' AND created BETWEEN -1.5 AND 2e3 and begins_with(name, \"a\") AND tags IN ('x', 'y') AND x IS NOT NULL AND y not contains 'z' LIMIT 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Select{
+		Attributes: []string{"id", "first name"},
+		Table:      "users",
+		Where: []Predicate{
+			{"id", Condition{[]AttributeVal{{S: "it's"}}, ConditionEqual}},
+			{"created", Condition{[]AttributeVal{{N: "-1.5"}, {N: "2e3"}}, ConditionBetween}},
+			{"name", Condition{[]AttributeVal{{S: "a"}}, ConditionBeginsWith}},
+			{"tags", Condition{[]AttributeVal{{S: "x"}, {S: "y"}}, ConditionIn}},
+			{"x", Condition{nil, ConditionAttributeExists}},
+			{"y", Condition{[]AttributeVal{{S: "z"}}, ConditionNotContains}},
+		},
+		Limit: 10,
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("Parsed %+v", s)
+	}
+	for stmt, msg := range map[string]string{
+		"SELECT * users":                       `Expected FROM at position 10, found "users"`,
+		"SELECT * FROM users WHERE id = x":     `Expected value at position 32, found "x"`,
+		"SELECT * FROM users WHERE id =":       "Expected value at the end of the statement",
+		"SELECT * FROM users LIMIT 0":          `Expected positive LIMIT at position 27, found "0"`,
+		"SELECT * FROM users WHERE id = 'a":    "Unterminated quote at position 32",
+		"SELECT * FROM users WHERE id ~ 'a'":   `Unexpected '~' at position 30`,
+		"SELECT * FROM users WHERE f(id, 'a')": "Unknown function f",
+	} {
+		if _, err := ParseSelect(stmt); err == nil || err.Error() != msg {
+			t.Errorf("%s: unexpected error %v", stmt, err)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	for stmt, explanation := range map[string]string{
+		"SELECT * FROM users WHERE id = 'a' AND created > 5":        "Query on users with key conditions on created, id",
+		"SELECT * FROM users WHERE email = 'a@b.c'":                 "Query on index byEmail of users with key conditions on email",
+		"SELECT * FROM users WHERE team = 'a'":                      "Scan of users filtered on team: no equality on hash key id",
+		"SELECT * FROM users WHERE id = 'a' AND created <> 5":       "Scan of users filtered on created, id: NE on range key created isn't a key condition",
+		"SELECT * FROM users WHERE id = 'a' AND name = 'b'":         "Scan of users filtered on id, name: name isn't a key attribute",
+		"SELECT * FROM users":                                       "Scan of users: no WHERE clause",
+		"SELECT * FROM users INDEX byTeam WHERE team = 'a' LIMIT 2": "Query on index byTeam of users with key conditions on team",
+	} {
+		s, err := ParseSelect(stmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := s.Plan(usersTable)
+		if err != nil {
+			t.Errorf("%s: %s", stmt, err.Error())
+		} else if p.Explanation != explanation {
+			t.Errorf("%s: explained as %q", stmt, p.Explanation)
+		} else if (p.Query == nil) == strings.HasPrefix(explanation, "Query") {
+			t.Errorf("%s: planned as %+v", stmt, p)
+		}
+	}
+	s, _ := ParseSelect("SELECT * FROM users WHERE id = 5 AND created = '6'")
+	p, _ := s.Plan(usersTable)
+	if cond := p.Query.KeyConditions; cond["id"].AttributeValueList[0].S != "5" || cond["created"].AttributeValueList[0].N != "6" {
+		t.Errorf("Values weren't converted to the key types: %+v", cond)
+	}
+	s, _ = ParseSelect("SELECT * FROM users INDEX byTeam WHERE id = 'a'")
+	if _, err := s.Plan(usersTable); err == nil || err.Error() != "Index byTeam can't serve the WHERE clause: no equality on hash key team" {
+		t.Errorf("Unexpected error %v", err)
+	}
+	s, _ = ParseSelect("SELECT * FROM users INDEX byName WHERE id = 'a'")
+	if _, err := s.Plan(usersTable); err == nil {
+		t.Error("Planned on an unknown index")
+	}
+}
+
+func TestSelectInto(t *testing.T) {
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		switch strings.TrimPrefix(r.Header.Get("X-Amz-Target"), DynamoBaseEndpoint) {
+		case DescribeTableEndpoint:
+			json.NewEncoder(w).Encode(TableDescriptionWrapper{Table: usersTable})
+		case QueryEndpoint:
+			q := Query{}
+			json.Unmarshal(b, &q)
+			if q.Limit != 3 || q.KeyConditions["id"].AttributeValueList[0].S != "a" {
+				t.Errorf("Unexpected query %s", b)
+			}
+			if q.ExclusiveStartKey == nil {
+				json.NewEncoder(w).Encode(QueryResponse{
+					Items:            []AttributeSet{{"id": {S: "a"}, "created": {N: "1"}}, {"id": {S: "a"}, "created": {N: "2"}}},
+					LastEvaluatedKey: AttributeSet{"id": {S: "a"}, "created": {N: "2"}},
+				})
+				return
+			}
+			json.NewEncoder(w).Encode(QueryResponse{Items: []AttributeSet{{"id": {S: "a"}, "created": {N: "3"}}, {"id": {S: "a"}, "created": {N: "4"}}}})
+		default:
+			t.Errorf("Unexpected request %s", b)
+		}
+	})
+	defer s.Close()
+	users := []*struct {
+		ID      string `dynamo:"id"`
+		Created int64  `dynamo:"created"`
+	}{}
+	if err := c.SelectInto("SELECT * FROM users WHERE id = 'a' LIMIT 3", &users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 || users[0].ID != "a" || users[2].Created != 3 {
+		t.Errorf("Unexpected users %+v", users)
+	}
+}
@@ -0,0 +1,140 @@
+package dynamo
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	readUnitSize  = 4096 // Bytes read per read capacity unit with a strongly consistent read.
+	writeUnitSize = 1024 // Bytes written per write capacity unit.
+)
+
+// ItemTooLargeError is returned when an item is larger than ItemSizeLimit, so it would be rejected by DynamoDB.
+type ItemTooLargeError struct {
+	Size    int
+	Largest []AttributeSize // The largest attributes of the item, largest first.
+	Index   int             // Of the item in the items of BatchWrite, -1 for other writes.
+}
+
+// AttributeSize is the size of an attribute, including its name.
+type AttributeSize struct {
+	Name string
+	Size int
+}
+
+func (e *ItemTooLargeError) Error() string {
+	attrs := make([]string, len(e.Largest))
+	for i, a := range e.Largest {
+		attrs[i] = fmt.Sprintf("%s (%d bytes)", a.Name, a.Size)
+	}
+	prefix := ""
+	if e.Index >= 0 {
+		prefix = fmt.Sprintf("Item %d: ", e.Index)
+	}
+	return prefix + fmt.Sprintf("Item size of %d bytes exceeds limit of %d bytes, largest attributes: %s", e.Size, ItemSizeLimit, strings.Join(attrs, ", "))
+}
+
+// ItemSize returns the size DynamoDB bills for item: the UTF-8 length of every attribute name plus the size of its
+// value. Strings count their UTF-8 length, binaries their decoded length, and numbers 1 byte per two significant
+// digits plus 1. Sets count the sum of their elements.
+func ItemSize(item AttributeSet) int {
+	size := 0
+	for name, val := range item {
+		size += AttributeValSize(name, val)
+	}
+	return size
+}
+
+// AttributeValSize returns the size of a single attribute, see ItemSize.
+func AttributeValSize(name string, val AttributeVal) int {
+	size := len(name) + len(val.S) + numberSize(val.N) + binarySize(val.B)
+	for _, s := range val.SS {
+		size += len(s)
+	}
+	for _, n := range val.NS {
+		size += numberSize(n)
+	}
+	for _, b := range val.BS {
+		size += binarySize(b)
+	}
+	return size
+}
+
+// ReadUnits returns the read capacity units needed to read an item of size bytes. Eventually consistent reads cost
+// half as much.
+func ReadUnits(size int, consistent bool) float64 {
+	units := math.Ceil(float64(size) / readUnitSize)
+	if units == 0 {
+		units = 1
+	}
+	if !consistent {
+		return units / 2
+	}
+	return units
+}
+
+// WriteUnits returns the write capacity units needed to write an item of size bytes.
+func WriteUnits(size int) float64 {
+	units := math.Ceil(float64(size) / writeUnitSize)
+	if units == 0 {
+		units = 1
+	}
+	return units
+}
+
+// checkItemSize returns an *ItemTooLargeError if item is over ItemSizeLimit.
+func checkItemSize(item AttributeSet) error {
+	size := ItemSize(item)
+	if size <= ItemSizeLimit {
+		return nil
+	}
+	attrs := make([]AttributeSize, 0, len(item))
+	for name, val := range item {
+		attrs = append(attrs, AttributeSize{Name: name, Size: AttributeValSize(name, val)})
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].Size == attrs[j].Size {
+			return attrs[i].Name < attrs[j].Name
+		}
+		return attrs[i].Size > attrs[j].Size
+	})
+	if len(attrs) > 3 {
+		attrs = attrs[:3]
+	}
+	return &ItemTooLargeError{Size: size, Largest: attrs, Index: -1}
+}
+
+// numberSize is 1 byte per two significant digits, plus 1. Leading and trailing zeroes aren't significant.
+func numberSize(n string) int {
+	if len(n) == 0 {
+		return 0
+	}
+	if i := strings.IndexAny(n, "eE"); i >= 0 {
+		n = n[:i]
+	}
+	digits := strings.Trim(strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, n), "0")
+	return (len(digits)+1)/2 + 1
+}
+
+// binarySize is the length of the base64 decoded value.
+func binarySize(b string) int {
+	if len(b) == 0 {
+		return 0
+	}
+	size := base64.StdEncoding.DecodedLen(len(b))
+	if strings.HasSuffix(b, "==") {
+		size -= 2
+	} else if strings.HasSuffix(b, "=") {
+		size--
+	}
+	return size
+}
@@ -0,0 +1,55 @@
+package dynamo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestItemSize(t *testing.T) {
+	tests := []struct {
+		item AttributeSet
+		size int
+	}{
+		{AttributeSet{"name": {S: "héllo"}}, 4 + 6},
+		{AttributeSet{"n": {N: "12345"}}, 1 + 4},
+		{AttributeSet{"n": {N: "-0.00120"}}, 1 + 2},
+		{AttributeSet{"n": {N: "1.5E+10"}}, 1 + 2},
+		{AttributeSet{"b": {B: "aGVsbG8="}}, 1 + 5},
+		{AttributeSet{"ss": {SS: []string{"a", "bc"}}, "ns": {NS: []string{"1", "100"}}}, 2 + 3 + 2 + 4},
+	}
+	for _, test := range tests {
+		if size := ItemSize(test.item); size != test.size {
+			t.Errorf("Expected size %d for %v, got %d", test.size, test.item, size)
+		}
+	}
+	if r, w := ReadUnits(5000, true), WriteUnits(5000); r != 2 || w != 5 {
+		t.Errorf("Expected 2 read and 5 write units, got %v and %v", r, w)
+	}
+	if r := ReadUnits(100, false); r != 0.5 {
+		t.Errorf("Expected 0.5 read units for eventually consistent read, got %v", r)
+	}
+}
+
+func TestPutItemTooLarge(t *testing.T) {
+	c, s := newTestClient(nil)
+	defer s.Close()
+	doc := versionedDoc{Id: "a", Name: strings.Repeat("x", ItemSizeLimit)}
+	err := c.PutItem("things", doc)
+	e, ok := err.(*ItemTooLargeError)
+	if !ok {
+		t.Fatalf("Expected *ItemTooLargeError, got %v", err)
+	}
+	if e.Largest[0].Name != "name" || !strings.Contains(err.Error(), "name (") {
+		t.Errorf("Expected name to be the largest attribute, got %v", err)
+	}
+}
+
+func TestBatchWriteItemTooLarge(t *testing.T) {
+	c, s := newTestClient(nil)
+	defer s.Close()
+	docs := []versionedDoc{{Id: "a"}, {Id: "b", Name: strings.Repeat("x", ItemSizeLimit)}}
+	_, err := c.BatchWrite("things", docs)
+	if e, ok := err.(*ItemTooLargeError); !ok || e.Index != 1 || !strings.HasPrefix(err.Error(), "Item 1: ") {
+		t.Errorf("Expected *ItemTooLargeError of item 1, got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package dynamo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"runtime"
+)
+
+// ItemFunc is called with every item of a page as it's decoded. Returning an error stops decoding. The item is reused
+// for the next one, so it must be copied to be kept.
+type ItemFunc func(item AttributeSet) error
+
+// StreamQuery runs a single page of the query, calling fn with each item as it's read from the response instead of
+// holding the whole page in memory. The returned response has no Items.
+func (c *Client) StreamQuery(q Query, fn ItemFunc) (QueryResponse, error) {
+	s := &itemStream{fn: fn}
+	err := c.streamQuery(q, s)
+	return s.QueryResponse, err
+}
+
+// StreamScan scans a single page like StreamQuery.
+func (c *Client) StreamScan(scan ScanRequest, fn ItemFunc) (QueryResponse, error) {
+	s := &itemStream{fn: fn}
+	err := c.streamScan(scan, s)
+	return s.QueryResponse, err
+}
+
+func (c *Client) streamQuery(q Query, s *itemStream) error {
+	if len(q.ReturnConsumedCapacity) == 0 {
+		q.ReturnConsumedCapacity = c.returnConsumed()
+	}
+	return c.makeRequest(QueryEndpoint, q, s)
+}
+
+func (c *Client) streamScan(scan ScanRequest, s *itemStream) error {
+	if len(scan.ReturnConsumedCapacity) == 0 {
+		scan.ReturnConsumedCapacity = c.returnConsumed()
+	}
+	return c.makeRequest(ScanEndpoint, scan, s)
+}
+
+// QueryEach runs a single page of the query, decoding each item straight into doc, a ptr to struct, before calling fn.
+// doc is reused, so fn must copy it to keep it, and like UnmarshalAttributes fields without an attribute in the item
+// are left alone. It returns the key to start the next page from, nil after the last page.
+//
+//	u := User{}
+//	last, err := c.QueryEach(q, &u, func() error {
+//		fmt.Println(u.Name)
+//		return nil
+//	})
+func (c *Client) QueryEach(q Query, doc interface{}, fn func() error) (AttributeSet, error) {
+	d, err := newDocDecoder(doc, fn)
+	if err != nil {
+		return nil, err
+	}
+	s := &itemStream{doc: d}
+	err = c.streamQuery(q, s)
+	return s.LastEvaluatedKey, err
+}
+
+// ScanEach scans a single page like QueryEach.
+func (c *Client) ScanEach(scan ScanRequest, doc interface{}, fn func() error) (AttributeSet, error) {
+	d, err := newDocDecoder(doc, fn)
+	if err != nil {
+		return nil, err
+	}
+	s := &itemStream{doc: d}
+	err = c.streamScan(scan, s)
+	return s.LastEvaluatedKey, err
+}
+
+// itemStream is the destination of streamed requests. unmarshalResponse decodes it with decode. Items are passed to
+// fn, or decoded into the struct of doc when it's set.
+type itemStream struct {
+	QueryResponse
+	fn    ItemFunc
+	doc   *docDecoder
+	items int // Passed on so far. Requests aren't retried once items were passed on.
+}
+
+// decode walks the response token by token, decoding one item at a time.
+func (s *itemStream) decode(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var dst interface{}
+		switch t {
+		case "Items":
+			if err := s.decodeItems(dec); err != nil {
+				return err
+			}
+			continue
+		case "Count":
+			dst = &s.Count
+		case "ScannedCount":
+			dst = &s.ScannedCount
+		case "LastEvaluatedKey":
+			dst = &s.LastEvaluatedKey
+		case "ConsumedCapacity":
+			dst = &s.ConsumedCapacity
+		default:
+			dst = &json.RawMessage{}
+		}
+		if err := dec.Decode(dst); err != nil {
+			return err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+	// Read to the end so the checksum is verified.
+	_, err := io.Copy(ioutil.Discard, r)
+	return err
+}
+
+func (s *itemStream) decodeItems(dec *json.Decoder) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	if s.doc != nil {
+		for dec.More() {
+			if err := s.doc.decode(dec); err != nil {
+				return err
+			}
+			s.items++
+			if err := s.doc.fn(); err != nil {
+				return err
+			}
+		}
+		return expectDelim(dec, ']')
+	}
+	item := AttributeSet{}
+	for dec.More() {
+		for name := range item {
+			delete(item, name)
+		}
+		if err := dec.Decode(&item); err != nil {
+			return err
+		}
+		s.items++
+		if err := s.fn(item); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	} else if t != delim {
+		return fmt.Errorf("Invalid response, expected %v but got %v", delim, t)
+	}
+	return nil
+}
+
+// docDecoder decodes items into the fields of a struct attribute by attribute, without building an AttributeSet first.
+type docDecoder struct {
+	v      reflect.Value
+	fields map[string]attributeField
+	fn     func() error
+	val    AttributeVal    // Reused for every attribute.
+	skip   json.RawMessage // Reused for attributes without a field.
+}
+
+// attributeField is the struct field an attribute is decoded into.
+type attributeField struct {
+	index int
+	ttl   bool
+}
+
+func newDocDecoder(doc interface{}, fn func() error) (*docDecoder, error) {
+	v := reflect.ValueOf(doc)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Destination was not a non-nil ptr to struct, was %v", reflect.TypeOf(doc))
+	}
+	d := &docDecoder{v: v.Elem(), fields: map[string]attributeField{}, fn: fn}
+	t := d.v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if len(f.PkgPath) > 0 {
+			continue // Unexported.
+		}
+		if tag := parseFieldTag(f); !tag.ignore {
+			d.fields[tag.name] = attributeField{index: i, ttl: tag.ttl}
+		}
+	}
+	return d, nil
+}
+
+// decode reads the next item from dec into the struct, the way UnmarshalAttributes would set it.
+func (d *docDecoder) decode(dec *json.Decoder) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+			err = fmt.Errorf("Error: %v", r)
+		}
+	}()
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name, _ := t.(string)
+		f, ok := d.fields[name]
+		if !ok {
+			if err := dec.Decode(&d.skip); err != nil {
+				return err
+			}
+			continue
+		}
+		d.val = AttributeVal{}
+		if err := dec.Decode(&d.val); err != nil {
+			return err
+		}
+		v := d.v.Field(f.index)
+		if f.ttl && isTime(v) {
+			err = setTTLSeconds(v, d.val.N)
+		} else {
+			err = setAttribute(v, d.val)
+		}
+		if err != nil {
+			return fmt.Errorf("Attribute %s: %s", name, err.Error())
+		}
+	}
+	return expectDelim(dec, '}')
+}
@@ -0,0 +1,120 @@
+package dynamo
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type streamDoc struct {
+	Id    string `dynamo:"id"`
+	Name  string `dynamo:"name"`
+	Count int    `dynamo:"count"`
+}
+
+// queryPage returns a Query response of n items.
+func queryPage(n int) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString(`{"ConsumedCapacity":{"CapacityUnits":2,"TableName":"things"},"Count":`)
+	fmt.Fprintf(b, "%d,\"Items\":[", n)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(b, `{"id":{"S":"item-%d"},"name":{"S":"a reasonably long name for item %d"},"count":{"N":"%d"}}`, i, i, i)
+	}
+	b.WriteString(`],"LastEvaluatedKey":{"id":{"S":"last"}},"ScannedCount":`)
+	fmt.Fprintf(b, "%d,\"Unknown\":[1,{\"a\":2}]}", n)
+	return b.Bytes()
+}
+
+func TestQueryEach(t *testing.T) {
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(queryPage(3))
+	})
+	defer s.Close()
+	doc, ids := streamDoc{}, []string{}
+	last, err := c.QueryEach(Query{TableName: "things"}, &doc, func() error {
+		ids = append(ids, doc.Id)
+		if doc.Count != len(ids)-1 {
+			t.Errorf("Unexpected item %+v", doc)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[2] != "item-2" || last["id"].S != "last" {
+		t.Errorf("Unexpected items %v and last key %v", ids, last)
+	}
+
+	// Attributes without a field are skipped.
+	idOnly := struct {
+		Id string `dynamo:"id"`
+	}{}
+	if _, err := c.ScanEach(ScanRequest{TableName: "things"}, &idOnly, func() error { return nil }); err != nil || idOnly.Id != "item-2" {
+		t.Errorf("Scanned %+v, %v", idOnly, err)
+	}
+	if _, err := c.ScanEach(ScanRequest{TableName: "things"}, doc, func() error { return nil }); err == nil {
+		t.Error("Scanned into a struct that isn't a ptr")
+	}
+
+	stop := fmt.Errorf("stop")
+	res, err := c.StreamScan(ScanRequest{TableName: "things"}, func(item AttributeSet) error {
+		return stop
+	})
+	if err != stop || res.Count != 3 {
+		t.Errorf("Expected the error from the callback, got %v and %+v", err, res)
+	}
+}
+
+// BenchmarkDecodePage decodes a page the way RawQuery does, then unmarshals each item.
+func BenchmarkDecodePage(b *testing.B) {
+	page := queryPage(10000)
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		res := QueryResponse{}
+		if err := unmarshalResponse(bytes.NewReader(page), &res); err != nil {
+			b.Fatal(err)
+		}
+		docs := make([]streamDoc, len(res.Items))
+		for j, item := range res.Items {
+			if err := UnmarshalAttributes(item, &docs[j]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkStreamPage decodes a page item by item like StreamQuery, then unmarshals each item.
+func BenchmarkStreamPage(b *testing.B) {
+	page := queryPage(10000)
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		doc := streamDoc{}
+		s := &itemStream{fn: func(item AttributeSet) error { return UnmarshalAttributes(item, &doc) }}
+		if err := unmarshalResponse(bytes.NewReader(page), s); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkStreamDocs decodes a page straight into a struct like QueryEach.
+func BenchmarkStreamDocs(b *testing.B) {
+	page := queryPage(10000)
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		doc := streamDoc{}
+		d, err := newDocDecoder(&doc, func() error { return nil })
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := unmarshalResponse(bytes.NewReader(page), &itemStream{doc: d}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
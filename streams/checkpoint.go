@@ -0,0 +1,116 @@
+package streams
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/poptip/dynamo"
+)
+
+// ShardEnd is the checkpoint of shards read to their end.
+const ShardEnd = "SHARD_END"
+
+// Checkpoints store the sequence number of the last record read from each shard of streams.
+type Checkpoints interface {
+	// Load returns the sequence number saved for a shard, "" if there's none.
+	Load(arn, shard string) (string, error)
+	Save(arn, shard, seq string) error
+}
+
+// FileCheckpoints saves checkpoints to a JSON file, rewritten on every save. It's safe for concurrent use.
+type FileCheckpoints struct {
+	filename string
+	mu       sync.Mutex
+	seqs     map[string]map[string]string // By stream and shard, nil until loaded.
+}
+
+// NewFileCheckpoints returns checkpoints saved to filename, created on the first save.
+func NewFileCheckpoints(filename string) *FileCheckpoints {
+	return &FileCheckpoints{filename: filename}
+}
+
+func (f *FileCheckpoints) load() error {
+	if f.seqs != nil {
+		return nil
+	}
+	b, err := ioutil.ReadFile(f.filename)
+	if os.IsNotExist(err) {
+		f.seqs = map[string]map[string]string{}
+		return nil
+	} else if err != nil {
+		return err
+	}
+	seqs := map[string]map[string]string{}
+	if err := json.Unmarshal(b, &seqs); err != nil {
+		return fmt.Errorf("Invalid checkpoints %s: %s", f.filename, err.Error())
+	}
+	f.seqs = seqs
+	return nil
+}
+
+func (f *FileCheckpoints) Load(arn, shard string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return "", err
+	}
+	return f.seqs[arn][shard], nil
+}
+
+func (f *FileCheckpoints) Save(arn, shard, seq string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return err
+	}
+	if f.seqs[arn] == nil {
+		f.seqs[arn] = map[string]string{}
+	}
+	f.seqs[arn][shard] = seq
+	b, err := json.Marshal(f.seqs)
+	if err != nil {
+		return err
+	}
+	tmp := f.filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.filename)
+}
+
+// TableCheckpoints saves checkpoints to a DynamoDB table, see CreateTable, so consumers can move between hosts. Each
+// consumer of a stream has its own checkpoints.
+type TableCheckpoints struct {
+	db       *dynamo.Client
+	table    string
+	consumer string
+}
+
+// NewTableCheckpoints returns the checkpoints of a consumer saved to a table.
+func NewTableCheckpoints(db *dynamo.Client, table, consumer string) *TableCheckpoints {
+	return &TableCheckpoints{db: db, table: table, consumer: consumer}
+}
+
+// CreateTable creates the table of the checkpoints, keyed by the consumer and by stream and shard.
+func (t *TableCheckpoints) CreateTable(read, write int) error {
+	_, err := t.db.CreateTableSimple(t.table, "consumer", dynamo.TypeString, "shard", dynamo.TypeString, read, write)
+	return err
+}
+
+func (t *TableCheckpoints) key(arn, shard string) dynamo.AttributeSet {
+	return dynamo.AttributeSet{"consumer": {S: t.consumer}, "shard": {S: arn + "/" + shard}}
+}
+
+func (t *TableCheckpoints) Load(arn, shard string) (string, error) {
+	item, err := t.db.GetItemRaw(t.table, t.key(arn, shard), true)
+	return item["seq"].S, err
+}
+
+func (t *TableCheckpoints) Save(arn, shard, seq string) error {
+	item := t.key(arn, shard)
+	item["seq"] = dynamo.AttributeVal{S: seq}
+	return t.db.PutItemRaw(t.table, item)
+}
@@ -0,0 +1,217 @@
+package streams
+
+import (
+	"time"
+
+	"github.com/poptip/dynamo"
+)
+
+const defaultPollInterval = time.Second
+
+// RecordFunc is called with the records read from a shard, in order. Returning an error stops reading, before the
+// position after the records is saved.
+type RecordFunc func(shard Shard, records []Record) error
+
+// ReadOptions configure Read. The zero value reads every record kept, from the start, without saving its position.
+type ReadOptions struct {
+	// Checkpoints saves the position reached in each shard, so reading resumes after it.
+	Checkpoints Checkpoints
+
+	// Latest starts reading after the newest record of the shards without checkpoints when reading starts, instead
+	// of at their oldest record. Shards started later are always read from their oldest record.
+	Latest bool
+
+	Limit        int           // Of records per call of the RecordFunc, MaxRecords if 0.
+	PollInterval time.Duration // Waited when every shard was read to its newest record, 1s if 0.
+}
+
+// shardReader is the position in a shard being read.
+type shardReader struct {
+	shard    Shard
+	iterator string
+	seq      string // Of the last record read.
+}
+
+// Read reads the records of the shards of a stream as they're added, calling fn with them. Shards are read after
+// their parent, so the changes of an item are read in order. It returns when stop is closed, when fn returns an error,
+// or once the stream is disabled and every shard was read.
+func (c *Client) Read(arn string, opts ReadOptions, fn RecordFunc, stop <-chan struct{}) error {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	done := map[string]bool{}
+	active := []*shardReader{}
+	for first := true; ; first = false {
+		desc, err := c.DescribeStream(arn)
+		if err != nil {
+			return err
+		}
+		started, err := c.startShards(desc, opts, first, done, active)
+		if err != nil {
+			return err
+		}
+		active = append(active, started...)
+		if len(active) == 0 && desc.StreamStatus == StreamDisabled {
+			return nil
+		} else if len(active) == 0 && !wait(stop, opts.PollInterval) {
+			return nil
+		}
+		// Read the shards until one of them ends, as its children can then be started.
+		for ended := false; !ended && len(active) > 0; {
+			read := false
+			for i := 0; i < len(active); i++ {
+				r := active[i]
+				n, err := c.readShard(arn, r, opts, fn)
+				if err != nil {
+					return err
+				} else if len(r.iterator) > 0 {
+					read = read || n > 0
+					continue
+				}
+				if opts.Checkpoints != nil {
+					if err := opts.Checkpoints.Save(arn, r.shard.ShardId, ShardEnd); err != nil {
+						return err
+					}
+				}
+				done[r.shard.ShardId], ended = true, true
+				active = append(active[:i], active[i+1:]...)
+				i--
+			}
+			if !read && !ended && !wait(stop, opts.PollInterval) {
+				return nil
+			} else if stopped(stop) {
+				return nil
+			}
+		}
+	}
+}
+
+// startShards returns readers of the shards of a stream that aren't done or active and whose parent was read.
+func (c *Client) startShards(desc StreamDescription, opts ReadOptions, first bool, done map[string]bool, active []*shardReader) ([]*shardReader, error) {
+	known := map[string]bool{}
+	for _, s := range desc.Shards {
+		known[s.ShardId] = true
+	}
+	started := []*shardReader{}
+	for _, s := range Lineage(desc.Shards) {
+		if done[s.ShardId] || isActive(active, s.ShardId) {
+			continue
+		} else if p := s.ParentShardId; len(p) > 0 && !done[p] && (known[p] || isActive(active, p)) {
+			continue // Its parent hasn't been read, or was trimmed if it isn't known.
+		}
+		seq := ""
+		if opts.Checkpoints != nil {
+			var err error
+			if seq, err = opts.Checkpoints.Load(desc.StreamArn, s.ShardId); err != nil {
+				return nil, err
+			}
+		}
+		position := TrimHorizon
+		switch {
+		case seq == ShardEnd:
+			done[s.ShardId] = true
+			continue
+		case len(seq) > 0:
+			position = AfterSequenceNumber
+		case opts.Latest && first && !s.Open():
+			done[s.ShardId] = true
+			continue
+		case opts.Latest && first:
+			position = Latest
+		}
+		iterator, err := c.GetShardIterator(desc.StreamArn, s.ShardId, position, seq)
+		if dynamo.IsErrorCode(err, TrimmedDataAccessException) {
+			// The records after the checkpoint are older than 24 hours and were trimmed.
+			iterator, err = c.GetShardIterator(desc.StreamArn, s.ShardId, TrimHorizon, "")
+		}
+		if err != nil {
+			return nil, err
+		}
+		started = append(started, &shardReader{shard: s, iterator: iterator, seq: seq})
+	}
+	return started, nil
+}
+
+// readShard reads a page of records from a shard, returning how many. The iterator of r is empty once the shard
+// ended.
+func (c *Client) readShard(arn string, r *shardReader, opts ReadOptions, fn RecordFunc) (int, error) {
+	records, next, err := c.GetRecords(r.iterator, opts.Limit)
+	if dynamo.IsErrorCode(err, ExpiredIteratorException) {
+		position := AfterSequenceNumber
+		if len(r.seq) == 0 {
+			position = TrimHorizon
+		}
+		r.iterator, err = c.GetShardIterator(arn, r.shard.ShardId, position, r.seq)
+		return 0, err
+	} else if err != nil {
+		return 0, err
+	}
+	if len(records) > 0 {
+		if err := fn(r.shard, records); err != nil {
+			return 0, err
+		}
+		r.seq = records[len(records)-1].Dynamodb.SequenceNumber
+		if opts.Checkpoints != nil {
+			if err := opts.Checkpoints.Save(arn, r.shard.ShardId, r.seq); err != nil {
+				return 0, err
+			}
+		}
+	}
+	r.iterator = next
+	return len(records), nil
+}
+
+// Lineage orders shards so parents come before their children, otherwise keeping their order.
+func Lineage(shards []Shard) []Shard {
+	byID := map[string]Shard{}
+	for _, s := range shards {
+		byID[s.ShardId] = s
+	}
+	ordered := make([]Shard, 0, len(shards))
+	visited := map[string]bool{}
+	var visit func(s Shard)
+	visit = func(s Shard) {
+		if visited[s.ShardId] {
+			return
+		}
+		visited[s.ShardId] = true
+		if parent, ok := byID[s.ParentShardId]; ok {
+			visit(parent)
+		}
+		ordered = append(ordered, s)
+	}
+	for _, s := range shards {
+		visit(s)
+	}
+	return ordered
+}
+
+func isActive(readers []*shardReader, shard string) bool {
+	for _, r := range readers {
+		if r.shard.ShardId == shard {
+			return true
+		}
+	}
+	return false
+}
+
+// wait waits for d, returning false if stop was closed first.
+func wait(stop <-chan struct{}, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-stop:
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,267 @@
+// Package streams reads the changes of DynamoDB tables from DynamoDB Streams, see dynamo.Client.EnableStream.
+//
+// A stream is made of shards of records, which DynamoDB closes every few hours or splits, starting new shards whose
+// parent is the shard closed. Read reads every shard of a stream, parents before children, saving its position in
+// each to a Checkpoints store so it can resume:
+//
+//	s := streams.New(c)
+//	arn := desc.LatestStreamArn
+//	err := s.Read(arn, streams.ReadOptions{Checkpoints: streams.NewFileCheckpoints("orders.json")}, func(shard streams.Shard, records []streams.Record) error {
+//		for _, r := range records {
+//			o := Order{}
+//			if err := r.UnmarshalNew(&o); err != nil {
+//				return err
+//			}
+//			...
+//		}
+//		return nil
+//	}, stop)
+package streams
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/poptip/dynamo"
+)
+
+const (
+	// Target endpoints.
+	BaseEndpoint             = "DynamoDBStreams_20120810."
+	ListStreamsEndpoint      = "ListStreams"
+	DescribeStreamEndpoint   = "DescribeStream"
+	GetShardIteratorEndpoint = "GetShardIterator"
+	GetRecordsEndpoint       = "GetRecords"
+
+	ExpiredIteratorException   = "ExpiredIteratorException"   // Iterators expire after 15 minutes.
+	TrimmedDataAccessException = "TrimmedDataAccessException" // Records are trimmed after 24 hours.
+
+	// Positions of shard iterators.
+	TrimHorizon         = "TRIM_HORIZON" // The oldest record of the shard, records are kept 24 hours.
+	Latest              = "LATEST"       // After the newest record.
+	AtSequenceNumber    = "AT_SEQUENCE_NUMBER"
+	AfterSequenceNumber = "AFTER_SEQUENCE_NUMBER"
+
+	// Names of events.
+	Insert = "INSERT"
+	Modify = "MODIFY"
+	Remove = "REMOVE"
+
+	// Statuses of streams.
+	StreamEnabling  = "ENABLING"
+	StreamEnabled   = "ENABLED"
+	StreamDisabling = "DISABLING"
+	StreamDisabled  = "DISABLED"
+
+	MaxRecords = 1000 // Returned by GetRecords.
+)
+
+// ErrNoImage is returned when unmarshaling an image a record doesn't have, because of the view of the stream or the
+// event, i.e. the new image of a REMOVE.
+var ErrNoImage = errors.New("Record has no such image")
+
+// Client reads streams with the credentials and region of a DynamoDB client.
+type Client struct {
+	db *dynamo.Client
+
+	// Endpoint of DynamoDB Streams. It defaults to streams.dynamodb.<region>.amazonaws.com for DynamoDB endpoints of
+	// the form dynamodb.<region>.amazonaws.com, or to the DynamoDB endpoint for others, as local servers serve both.
+	Endpoint string
+}
+
+// New returns a client of the streams of the tables of db.
+func New(db *dynamo.Client) *Client {
+	endpoint := db.Region.DynamoDBEndpoint
+	if i := strings.Index(endpoint, "://dynamodb."); i >= 0 && strings.Contains(endpoint, "amazonaws.com") {
+		endpoint = endpoint[:i] + "://streams." + endpoint[i+3:]
+	}
+	return &Client{db: db, Endpoint: endpoint}
+}
+
+type Stream struct {
+	StreamArn   string
+	StreamLabel string
+	TableName   string
+}
+
+type StreamDescription struct {
+	StreamArn               string
+	StreamLabel             string
+	StreamStatus            string
+	StreamViewType          string
+	CreationRequestDateTime float64
+	TableName               string
+	KeySchema               []dynamo.Key
+	Shards                  []Shard
+	LastEvaluatedShardId    string `json:",omitempty"`
+}
+
+type Shard struct {
+	ShardId             string
+	ParentShardId       string `json:",omitempty"`
+	SequenceNumberRange SequenceNumberRange
+}
+
+// Open reports whether records are still added to the shard.
+func (s Shard) Open() bool {
+	return len(s.SequenceNumberRange.EndingSequenceNumber) == 0
+}
+
+type SequenceNumberRange struct {
+	StartingSequenceNumber string `json:",omitempty"`
+	EndingSequenceNumber   string `json:",omitempty"` // Only set once the shard is closed.
+}
+
+// Record is a change of an item.
+type Record struct {
+	EventID      string        `json:"eventID"`
+	EventName    string        `json:"eventName"` // Insert, Modify or Remove.
+	EventVersion string        `json:"eventVersion"`
+	EventSource  string        `json:"eventSource"`
+	AwsRegion    string        `json:"awsRegion"`
+	Dynamodb     StreamRecord  `json:"dynamodb"`
+	UserIdentity *UserIdentity `json:"userIdentity,omitempty"` // Only set for deletions by TTL.
+}
+
+type StreamRecord struct {
+	ApproximateCreationDateTime float64 // In unix seconds.
+	Keys                        dynamo.AttributeSet
+	NewImage                    dynamo.AttributeSet `json:",omitempty"`
+	OldImage                    dynamo.AttributeSet `json:",omitempty"`
+	SequenceNumber              string
+	SizeBytes                   int64
+	StreamViewType              string
+}
+
+// UserIdentity identifies the DynamoDB service as the one deleting expired items.
+type UserIdentity struct {
+	PrincipalId string
+	Type        string
+}
+
+// Time returns the approximate time of the change.
+func (r Record) Time() time.Time {
+	return time.Unix(int64(r.Dynamodb.ApproximateCreationDateTime), 0)
+}
+
+// Expired reports whether the record is the deletion of an expired item by DynamoDB, see dynamo.Client.EnableTTL.
+func (r Record) Expired() bool {
+	return r.EventName == Remove && r.UserIdentity != nil && r.UserIdentity.PrincipalId == "dynamodb.amazonaws.com"
+}
+
+// UnmarshalNew unmarshals the item after the change into dst, a ptr to struct, see dynamo.UnmarshalAttributes.
+func (r Record) UnmarshalNew(dst interface{}) error {
+	return unmarshalImage(r.Dynamodb.NewImage, dst)
+}
+
+// UnmarshalOld unmarshals the item before the change into dst.
+func (r Record) UnmarshalOld(dst interface{}) error {
+	return unmarshalImage(r.Dynamodb.OldImage, dst)
+}
+
+// UnmarshalKeys unmarshals the key of the item changed into dst. Every record has it.
+func (r Record) UnmarshalKeys(dst interface{}) error {
+	return unmarshalImage(r.Dynamodb.Keys, dst)
+}
+
+func unmarshalImage(image dynamo.AttributeSet, dst interface{}) error {
+	if image == nil {
+		return ErrNoImage
+	}
+	return dynamo.UnmarshalAttributes(image, dst)
+}
+
+type listStreamsRequest struct {
+	TableName               string `json:",omitempty"`
+	ExclusiveStartStreamArn string `json:",omitempty"`
+}
+
+type listStreamsResponse struct {
+	Streams                []Stream
+	LastEvaluatedStreamArn string
+}
+
+type describeStreamRequest struct {
+	StreamArn             string
+	ExclusiveStartShardId string `json:",omitempty"`
+}
+
+type getShardIteratorRequest struct {
+	StreamArn         string
+	ShardId           string
+	ShardIteratorType string
+	SequenceNumber    string `json:",omitempty"`
+}
+
+type getRecordsRequest struct {
+	ShardIterator string
+	Limit         int `json:",omitempty"`
+}
+
+type getRecordsResponse struct {
+	Records           []Record
+	NextShardIterator string
+}
+
+func (c *Client) request(endpoint string, data, dst interface{}) error {
+	r, err := c.db.NewTargetRequest(c.Endpoint, BaseEndpoint+endpoint, data)
+	if err != nil {
+		return err
+	}
+	return c.db.DoAndUnmarshal(r, dst)
+}
+
+// ListStreams returns the streams of a table, or of every table if table is empty. Tables have a stream each time
+// their stream is enabled, kept 24 hours after it's disabled.
+func (c *Client) ListStreams(table string) ([]Stream, error) {
+	streams := []Stream{}
+	req := listStreamsRequest{TableName: table}
+	for {
+		res := listStreamsResponse{}
+		if err := c.request(ListStreamsEndpoint, req, &res); err != nil {
+			return nil, err
+		}
+		streams = append(streams, res.Streams...)
+		if len(res.LastEvaluatedStreamArn) == 0 {
+			return streams, nil
+		}
+		req.ExclusiveStartStreamArn = res.LastEvaluatedStreamArn
+	}
+}
+
+// DescribeStream returns a stream with all its shards.
+func (c *Client) DescribeStream(arn string) (StreamDescription, error) {
+	desc := StreamDescription{}
+	req := describeStreamRequest{StreamArn: arn}
+	for {
+		res := struct{ StreamDescription StreamDescription }{}
+		if err := c.request(DescribeStreamEndpoint, req, &res); err != nil {
+			return desc, err
+		}
+		shards := append(desc.Shards, res.StreamDescription.Shards...)
+		desc = res.StreamDescription
+		desc.Shards = shards
+		if len(desc.LastEvaluatedShardId) == 0 {
+			return desc, nil
+		}
+		req.ExclusiveStartShardId = desc.LastEvaluatedShardId
+	}
+}
+
+// GetShardIterator returns an iterator of the records of a shard from position, i.e. TrimHorizon. seq is the sequence
+// number of AtSequenceNumber and AfterSequenceNumber iterators. Iterators expire after 15 minutes.
+func (c *Client) GetShardIterator(arn, shard, position, seq string) (string, error) {
+	req := getShardIteratorRequest{StreamArn: arn, ShardId: shard, ShardIteratorType: position, SequenceNumber: seq}
+	res := struct{ ShardIterator string }{}
+	err := c.request(GetShardIteratorEndpoint, req, &res)
+	return res.ShardIterator, err
+}
+
+// GetRecords returns up to limit records from an iterator, MaxRecords if limit is 0, and the iterator of the next
+// ones. The next iterator is empty once the shard is closed and every record was returned.
+func (c *Client) GetRecords(iterator string, limit int) ([]Record, string, error) {
+	res := getRecordsResponse{}
+	err := c.request(GetRecordsEndpoint, getRecordsRequest{ShardIterator: iterator, Limit: limit}, &res)
+	return res.Records, res.NextShardIterator, err
+}
@@ -0,0 +1,257 @@
+package streams
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crowdmob/goamz/aws"
+	"github.com/poptip/dynamo"
+	"github.com/poptip/dynamo/dynamotest"
+)
+
+// fakeShard is a shard of fakeStream, its records numbered by their sequence number.
+type fakeShard struct {
+	id, parent string
+	seqs       []int
+	closed     bool
+}
+
+// fakeStream serves a stream of one shard per DescribeStream page. Iterators are a shard and a record index.
+type fakeStream struct {
+	mu     sync.Mutex
+	status string
+	shards []*fakeShard
+}
+
+func (f *fakeStream) shard(id string) *fakeShard {
+	for _, s := range f.shards {
+		if s.id == id {
+			return s
+		}
+	}
+	return nil
+}
+
+func (f *fakeStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, _ := ioutil.ReadAll(r.Body)
+	op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), BaseEndpoint)
+	var res interface{}
+	switch op {
+	case DescribeStreamEndpoint:
+		req := describeStreamRequest{}
+		json.Unmarshal(b, &req)
+		desc := StreamDescription{StreamArn: req.StreamArn, StreamStatus: f.status}
+		start := 0
+		for i, s := range f.shards {
+			if s.id == req.ExclusiveStartShardId {
+				start = i + 1
+			}
+		}
+		for i, s := range f.shards[start:] {
+			i += start
+			shard := Shard{ShardId: s.id, ParentShardId: s.parent}
+			if s.closed {
+				shard.SequenceNumberRange.EndingSequenceNumber = "end"
+			}
+			desc.Shards = []Shard{shard}
+			if i < len(f.shards)-1 {
+				desc.LastEvaluatedShardId = s.id
+			}
+			break
+		}
+		res = struct{ StreamDescription StreamDescription }{desc}
+	case GetShardIteratorEndpoint:
+		req := getShardIteratorRequest{}
+		json.Unmarshal(b, &req)
+		s, i := f.shard(req.ShardId), 0
+		switch req.ShardIteratorType {
+		case Latest:
+			i = len(s.seqs)
+		case AfterSequenceNumber:
+			seq, _ := strconv.Atoi(req.SequenceNumber)
+			for i < len(s.seqs) && s.seqs[i] <= seq {
+				i++
+			}
+		}
+		res = struct{ ShardIterator string }{fmt.Sprintf("%s:%d", s.id, i)}
+	case GetRecordsEndpoint:
+		req := getRecordsRequest{}
+		json.Unmarshal(b, &req)
+		parts := strings.Split(req.ShardIterator, ":")
+		s := f.shard(parts[0])
+		i, _ := strconv.Atoi(parts[1])
+		out := getRecordsResponse{Records: []Record{}}
+		for ; i < len(s.seqs) && (req.Limit == 0 || len(out.Records) < req.Limit); i++ {
+			seq := strconv.Itoa(s.seqs[i])
+			out.Records = append(out.Records, Record{EventName: Insert, Dynamodb: StreamRecord{
+				SequenceNumber: seq,
+				Keys:           dynamo.AttributeSet{"id": {N: seq}},
+				NewImage:       dynamo.AttributeSet{"id": {N: seq}, "shard": {S: s.id}},
+			}})
+		}
+		if !s.closed || i < len(s.seqs) {
+			out.NextShardIterator = fmt.Sprintf("%s:%d", s.id, i)
+		}
+		res = out
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func newTestClient(f *fakeStream) (*Client, *httptest.Server) {
+	s := httptest.NewServer(f)
+	region := aws.USEast
+	region.DynamoDBEndpoint = s.URL
+	return New(dynamo.NewClient(aws.Auth{AccessKey: "key", SecretKey: "secret"}, region)), s
+}
+
+type change struct {
+	ID    int    `dynamo:"id"`
+	Shard string `dynamo:"shard"`
+}
+
+func TestRead(t *testing.T) {
+	// s2 split from s1, which was rotated from s0, trimmed. s3 split too but is empty.
+	f := &fakeStream{status: StreamEnabled, shards: []*fakeShard{
+		{id: "s2", parent: "s1", seqs: []int{5, 6}},
+		{id: "s1", parent: "s0", seqs: []int{2, 3, 4}, closed: true},
+		{id: "s3", parent: "s1"},
+	}}
+	c, s := newTestClient(f)
+	defer s.Close()
+	dir, err := ioutil.TempDir("", "streams")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cp := NewFileCheckpoints(filepath.Join(dir, "checkpoints.json"))
+
+	read := func() []change {
+		changes := []change{}
+		stop := make(chan struct{})
+		opts := ReadOptions{Checkpoints: cp, Limit: 2, PollInterval: time.Millisecond}
+		err := c.Read("arn", opts, func(shard Shard, records []Record) error {
+			for _, r := range records {
+				ch := change{}
+				if err := r.UnmarshalNew(&ch); err != nil {
+					return err
+				} else if ch.Shard != shard.ShardId {
+					t.Errorf("Record %+v of shard %s", r, shard.ShardId)
+				}
+				changes = append(changes, ch)
+				if ch.ID == 6 || ch.ID == 7 {
+					close(stop)
+				}
+			}
+			return nil
+		}, stop)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return changes
+	}
+	if changes := read(); len(changes) != 5 || changes[0].ID != 2 || changes[4].ID != 6 {
+		t.Errorf("Read %+v", changes)
+	}
+	if seq, _ := cp.Load("arn", "s1"); seq != ShardEnd {
+		t.Errorf("Checkpoint of s1 is %q", seq)
+	}
+
+	f.mu.Lock()
+	f.status = StreamDisabled
+	f.shards[0].seqs = append(f.shards[0].seqs, 7)
+	f.shards[0].closed, f.shards[2].closed = true, true
+	f.mu.Unlock()
+	if changes := read(); len(changes) != 1 || changes[0].ID != 7 {
+		t.Errorf("Resumed reading %+v", changes)
+	}
+}
+
+func TestReadLatest(t *testing.T) {
+	f := &fakeStream{status: StreamDisabled, shards: []*fakeShard{
+		{id: "s1", seqs: []int{1}, closed: true},
+		{id: "s2", parent: "s1", seqs: []int{2}, closed: true},
+	}}
+	c, s := newTestClient(f)
+	defer s.Close()
+	err := c.Read("arn", ReadOptions{Latest: true}, func(shard Shard, records []Record) error {
+		return fmt.Errorf("Read %+v", records)
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLineage(t *testing.T) {
+	shards := Lineage([]Shard{
+		{ShardId: "c", ParentShardId: "b"},
+		{ShardId: "d", ParentShardId: "a"},
+		{ShardId: "b", ParentShardId: "a"},
+		{ShardId: "a", ParentShardId: "trimmed"},
+	})
+	ids := []string{}
+	for _, s := range shards {
+		ids = append(ids, s.ShardId)
+	}
+	if strings.Join(ids, ",") != "a,b,c,d" {
+		t.Errorf("Ordered %v", ids)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	r := Record{}
+	if err := json.Unmarshal([]byte(`{"eventName":"REMOVE","userIdentity":{"principalId":"dynamodb.amazonaws.com","type":"Service"},"dynamodb":{"Keys":{"id":{"N":"1"}},"OldImage":{"id":{"N":"1"},"shard":{"S":"a"}}}}`), &r); err != nil {
+		t.Fatal(err)
+	}
+	ch := change{}
+	if !r.Expired() {
+		t.Error("Deletion by TTL isn't expired")
+	} else if err := r.UnmarshalNew(&ch); err != ErrNoImage {
+		t.Errorf("Unmarshaled the new image of a removal: %v", err)
+	} else if err := r.UnmarshalOld(&ch); err != nil || ch.ID != 1 || ch.Shard != "a" {
+		t.Errorf("Unmarshaled %+v, %v", ch, err)
+	}
+}
+
+func TestTableCheckpoints(t *testing.T) {
+	s := dynamotest.NewServer()
+	defer s.Close()
+	cp := NewTableCheckpoints(s.Client(), "checkpoints", "indexer")
+	if err := cp.CreateTable(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if seq, err := cp.Load("arn", "s1"); err != nil || seq != "" {
+		t.Errorf("Loaded %q, %v", seq, err)
+	}
+	if err := cp.Save("arn", "s1", "12"); err != nil {
+		t.Fatal(err)
+	}
+	if seq, err := NewTableCheckpoints(s.Client(), "checkpoints", "indexer").Load("arn", "s1"); err != nil || seq != "12" {
+		t.Errorf("Loaded %q, %v", seq, err)
+	}
+	if seq, _ := NewTableCheckpoints(s.Client(), "checkpoints", "archiver").Load("arn", "s1"); seq != "" {
+		t.Errorf("Loaded the checkpoint of another consumer: %q", seq)
+	}
+}
+
+func TestNew(t *testing.T) {
+	region := aws.USEast
+	region.DynamoDBEndpoint = "https://dynamodb.us-east-1.amazonaws.com"
+	if c := New(dynamo.NewClient(aws.Auth{}, region)); c.Endpoint != "https://streams.dynamodb.us-east-1.amazonaws.com" {
+		t.Errorf("Endpoint is %s", c.Endpoint)
+	}
+}
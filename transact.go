@@ -0,0 +1,366 @@
+package dynamo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// txPrefix starts the placeholders generated for transactions, reserved so they can't clash with the ones of
+// TxConditions.
+const txPrefix = "dynamo_"
+
+// TxCondition is a condition expression attached to an operation of a write transaction, i.e.
+//
+//	TxCondition{Expression: "#s = :s", Names: map[string]string{"#s": "status"}, Values: AttributeSet{":s": {S: "open"}}}
+//
+// Placeholders starting with #dynamo_ and :dynamo_ are reserved for the ones generated by Update and version checks.
+// Conditions of an operation giving a placeholder different values make Run fail.
+type TxCondition struct {
+	Expression string
+	Names      map[string]string
+	Values     AttributeSet
+}
+
+// TxCanceledError is returned when DynamoDB cancels a transaction. It holds the reason for every operation in the
+// order they were added, so the operations that caused the cancellation can be told apart from the ones that didn't.
+type TxCanceledError struct {
+	Operations []TxOperation
+	Reasons    []CancellationReason
+}
+
+// TxOperation describes an operation of a transaction, for error reporting.
+type TxOperation struct {
+	Type  string // "Put", "Update", "Delete", "ConditionCheck" or "Get".
+	Table string
+}
+
+func (e *TxCanceledError) Error() string {
+	var failed []string
+	for i, r := range e.Reasons {
+		if r.Code == "None" || len(r.Code) == 0 {
+			continue
+		}
+		desc := fmt.Sprintf("operation %d", i)
+		if i < len(e.Operations) {
+			desc = fmt.Sprintf("operation %d (%s on %s)", i, e.Operations[i].Type, e.Operations[i].Table)
+		}
+		if len(r.Message) > 0 {
+			failed = append(failed, fmt.Sprintf("%s: %s: %s", desc, r.Code, r.Message))
+		} else {
+			failed = append(failed, fmt.Sprintf("%s: %s", desc, r.Code))
+		}
+	}
+	return "Transaction cancelled: " + strings.Join(failed, "; ")
+}
+
+// Failed returns the indexes of the operations that caused the transaction to be cancelled.
+func (e *TxCanceledError) Failed() []int {
+	var idx []int
+	for i, r := range e.Reasons {
+		if r.Code != "None" && len(r.Code) > 0 {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// WriteTx builds a TransactWriteItems request. Operations are added with Put, Update, Delete and ConditionCheck and
+// are applied all or nothing by Run. The first error encountered while building is returned by Run.
+type WriteTx struct {
+	c        *Client
+	items    []TransactWriteItem
+	ops      []TxOperation
+	versions []*version
+	token    string
+	err      error
+}
+
+// WriteTx starts a new write transaction.
+func (c *Client) WriteTx() *WriteTx {
+	return &WriteTx{c: c}
+}
+
+// IdempotencyToken sets the client request token of the transaction. Submitting the same token again within ten
+// minutes won't apply the transaction twice. If not set, a random token is generated on the first Run, so calling
+// Run again after a network error is safe.
+func (tx *WriteTx) IdempotencyToken(token string) *WriteTx {
+	tx.token = token
+	return tx
+}
+
+// Put adds a PutItem operation for doc. Version fields are checked and incremented as in PutItem.
+func (tx *WriteTx) Put(table string, doc interface{}, cond ...TxCondition) *WriteTx {
+	item, err := MarshalAttributes(doc)
+	if err != nil {
+		return tx.fail(err)
+	}
+	ver, err := getVersion(doc)
+	if err != nil {
+		return tx.fail(err)
+	}
+	op := &TransactOperation{TableName: table, Item: item}
+	if ver != nil {
+		item[ver.name] = ver.next()
+		cond = append(cond, ver.condition())
+	}
+	if err := checkItemSize(item); err != nil {
+		return tx.fail(err)
+	}
+	tx.add(TransactWriteItem{Put: op}, "Put", op, cond, ver)
+	return tx
+}
+
+// Update adds an UpdateItem operation that sets every attribute of updates on the item identified by matchDoc.
+// Version fields of updates are checked and incremented as in UpdateItem.
+func (tx *WriteTx) Update(table string, matchDoc interface{}, updates interface{}, cond ...TxCondition) *WriteTx {
+	key, err := marshalKey(matchDoc)
+	if err != nil {
+		return tx.fail(err)
+	}
+	attr, err := MarshalAttributes(updates)
+	if err != nil {
+		return tx.fail(err)
+	}
+	ver, err := getVersion(updates)
+	if err != nil {
+		return tx.fail(err)
+	}
+	if ver != nil {
+		attr[ver.name] = ver.next()
+		cond = append(cond, ver.condition())
+	}
+	names := []string{}
+	for a := range attr {
+		if _, ok := key[a]; !ok {
+			names = append(names, a)
+		}
+	}
+	if len(names) == 0 {
+		return tx.fail(errors.New("Update has no attributes to set"))
+	}
+	sort.Strings(names)
+	op := &TransactOperation{
+		TableName:                 table,
+		Key:                       key,
+		ExpressionAttributeNames:  map[string]string{},
+		ExpressionAttributeValues: AttributeSet{},
+	}
+	sets := make([]string, len(names))
+	for i, a := range names {
+		n, v := "#"+txPrefix+"upd"+strconv.Itoa(i), ":"+txPrefix+"upd"+strconv.Itoa(i)
+		op.ExpressionAttributeNames[n] = a
+		op.ExpressionAttributeValues[v] = attr[a]
+		sets[i] = n + " = " + v
+	}
+	op.UpdateExpression = "SET " + strings.Join(sets, ", ")
+	tx.add(TransactWriteItem{Update: op}, "Update", op, cond, ver)
+	return tx
+}
+
+// Delete adds a DeleteItem operation for the item identified by keyDoc.
+func (tx *WriteTx) Delete(table string, keyDoc interface{}, cond ...TxCondition) *WriteTx {
+	key, err := marshalKey(keyDoc)
+	if err != nil {
+		return tx.fail(err)
+	}
+	op := &TransactOperation{TableName: table, Key: key}
+	tx.add(TransactWriteItem{Delete: op}, "Delete", op, cond, nil)
+	return tx
+}
+
+// ConditionCheck adds a check that cond holds for the item identified by keyDoc, without modifying it.
+func (tx *WriteTx) ConditionCheck(table string, keyDoc interface{}, cond TxCondition) *WriteTx {
+	key, err := marshalKey(keyDoc)
+	if err != nil {
+		return tx.fail(err)
+	}
+	op := &TransactOperation{TableName: table, Key: key}
+	tx.add(TransactWriteItem{ConditionCheck: op}, "ConditionCheck", op, []TxCondition{cond}, nil)
+	return tx
+}
+
+// Run submits the transaction. If DynamoDB cancels it, a *TxCanceledError is returned.
+func (tx *WriteTx) Run() error {
+	if tx.err != nil {
+		return tx.err
+	} else if len(tx.items) == 0 {
+		return errors.New("Transaction has no operations")
+	} else if len(tx.items) > TransactItemLimit {
+		return fmt.Errorf("Maximum of %d item limit for transactions exceeded", TransactItemLimit)
+	}
+	if len(tx.token) == 0 {
+		token, err := newRequestToken()
+		if err != nil {
+			return err
+		}
+		tx.token = token
+	}
+	req := TransactWriteRequest{
+		ClientRequestToken:     tx.token,
+		ReturnConsumedCapacity: tx.c.returnConsumed(),
+		TransactItems:          tx.items,
+	}
+	err := tx.c.makeRequest(TransactWriteEndpoint, req, &TransactWriteResponse{})
+	if err != nil {
+		return txError(err, tx.ops)
+	}
+	for _, ver := range tx.versions {
+		ver.result(nil)
+	}
+	return nil
+}
+
+func (tx *WriteTx) add(item TransactWriteItem, opType string, op *TransactOperation, cond []TxCondition, ver *version) {
+	if tx.err != nil {
+		return
+	}
+	exprs := []string{}
+	for _, c := range cond {
+		if len(c.Expression) == 0 {
+			continue
+		}
+		exprs = append(exprs, "("+c.Expression+")")
+		for k, v := range c.Names {
+			if op.ExpressionAttributeNames == nil {
+				op.ExpressionAttributeNames = map[string]string{}
+			} else if old, ok := op.ExpressionAttributeNames[k]; ok && old != v {
+				tx.err = fmt.Errorf("Placeholder %s of %s on %s names both %s and %s", k, opType, op.TableName, old, v)
+				return
+			}
+			op.ExpressionAttributeNames[k] = v
+		}
+		for k, v := range c.Values {
+			if op.ExpressionAttributeValues == nil {
+				op.ExpressionAttributeValues = AttributeSet{}
+			} else if old, ok := op.ExpressionAttributeValues[k]; ok && !reflect.DeepEqual(old, v) {
+				tx.err = fmt.Errorf("Placeholder %s of %s on %s has two different values", k, opType, op.TableName)
+				return
+			}
+			op.ExpressionAttributeValues[k] = v
+		}
+	}
+	if len(exprs) == 0 && opType == "ConditionCheck" {
+		tx.err = errors.New("ConditionCheck requires a condition expression")
+		return
+	}
+	op.ConditionExpression = strings.Join(exprs, " AND ")
+	tx.items = append(tx.items, item)
+	tx.ops = append(tx.ops, TxOperation{Type: opType, Table: op.TableName})
+	if ver != nil {
+		tx.versions = append(tx.versions, ver)
+	}
+}
+
+func (tx *WriteTx) fail(err error) *WriteTx {
+	if tx.err == nil {
+		tx.err = err
+	}
+	return tx
+}
+
+// ReadTx builds a TransactGetItems request, reading several items as a consistent snapshot.
+type ReadTx struct {
+	c     *Client
+	items []TransactGetItem
+	ops   []TxOperation
+	err   error
+}
+
+// ReadTx starts a new read transaction.
+func (c *Client) ReadTx() *ReadTx {
+	return &ReadTx{c: c}
+}
+
+// Get adds a read of the item identified by keyDoc. If attributes are given only those are returned.
+func (tx *ReadTx) Get(table string, keyDoc interface{}, attributes ...string) *ReadTx {
+	if tx.err != nil {
+		return tx
+	}
+	key, err := marshalKey(keyDoc)
+	if err != nil {
+		tx.err = err
+		return tx
+	}
+	get := TransactGet{TableName: table, Key: key}
+	if len(attributes) > 0 {
+		get.ExpressionAttributeNames = map[string]string{}
+		proj := make([]string, len(attributes))
+		for i, a := range attributes {
+			proj[i] = "#p" + strconv.Itoa(i)
+			get.ExpressionAttributeNames[proj[i]] = a
+		}
+		get.ProjectionExpression = strings.Join(proj, ", ")
+	}
+	tx.items = append(tx.items, TransactGetItem{Get: get})
+	tx.ops = append(tx.ops, TxOperation{Type: "Get", Table: table})
+	return tx
+}
+
+// Run reads the items, returned in the order they were added. Items that don't exist are nil.
+func (tx *ReadTx) Run() ([]AttributeSet, error) {
+	if tx.err != nil {
+		return nil, tx.err
+	} else if len(tx.items) == 0 {
+		return nil, errors.New("Transaction has no operations")
+	} else if len(tx.items) > TransactItemLimit {
+		return nil, fmt.Errorf("Maximum of %d item limit for transactions exceeded", TransactItemLimit)
+	}
+	res := TransactGetResponse{}
+	req := TransactGetRequest{ReturnConsumedCapacity: tx.c.returnConsumed(), TransactItems: tx.items}
+	if err := tx.c.makeRequest(TransactGetEndpoint, req, &res); err != nil {
+		return nil, txError(err, tx.ops)
+	}
+	items := make([]AttributeSet, len(tx.items))
+	for i, r := range res.Responses {
+		if i < len(items) {
+			items[i] = r.Item
+		}
+	}
+	return items, nil
+}
+
+// condition returns the version check as a condition expression, see expected.
+func (ver *version) condition() TxCondition {
+	n, v := "#"+txPrefix+"ver", ":"+txPrefix+"ver"
+	names := map[string]string{n: ver.name}
+	if ver.current() == 0 {
+		return TxCondition{Expression: "attribute_not_exists(" + n + ")", Names: names}
+	}
+	return TxCondition{
+		Expression: n + " = " + v,
+		Names:      names,
+		Values:     AttributeSet{v: {N: strconv.FormatInt(ver.current(), 10)}},
+	}
+}
+
+// marshalKey marshals a document that should only contain the hash key and range key.
+func marshalKey(doc interface{}) (AttributeSet, error) {
+	key, err := MarshalAttributes(doc)
+	if err != nil {
+		return nil, err
+	} else if len(key) == 0 || len(key) > 2 {
+		return nil, fmt.Errorf("Document contains %d attributes, should only contain hashkey and range key", len(key))
+	}
+	return key, nil
+}
+
+func txError(err error, ops []TxOperation) error {
+	if e, ok := err.(*Error); ok && e.Code() == TransactionCanceledException && len(e.CancellationReasons) > 0 {
+		return &TxCanceledError{Operations: ops, Reasons: e.CancellationReasons}
+	}
+	return err
+}
+
+func newRequestToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,122 @@
+package dynamo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+type keyDoc struct {
+	Id string `dynamo:"id"`
+}
+
+func TestWriteTxCanceled(t *testing.T) {
+	var got TransactWriteRequest
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &got)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#TransactionCanceledException",
+			"message":"Transaction cancelled",
+			"CancellationReasons":[{"Code":"None"},{"Code":"ConditionalCheckFailed","Message":"The conditional request failed"}]}`))
+	})
+	defer s.Close()
+
+	doc := &versionedDoc{Id: "a", Name: "first", Version: 4}
+	tx := c.WriteTx().
+		Put("things", doc).
+		ConditionCheck("owners", keyDoc{"b"}, TxCondition{Expression: "attribute_exists(id)"})
+	err := tx.Run()
+	e, ok := err.(*TxCanceledError)
+	if !ok {
+		t.Fatalf("Expected *TxCanceledError, got %v", err)
+	}
+	if f := e.Failed(); len(f) != 1 || f[0] != 1 || e.Operations[1].Table != "owners" {
+		t.Errorf("Expected operation 1 on owners to fail, got %v", err)
+	}
+	if doc.Version != 4 {
+		t.Errorf("Version should not change on cancellation, was %d", doc.Version)
+	}
+	if len(got.ClientRequestToken) == 0 {
+		t.Error("Expected a generated idempotency token")
+	}
+	put := got.TransactItems[0].Put
+	if put == nil || put.ConditionExpression != "(#dynamo_ver = :dynamo_ver)" || put.Item["version"].N != "5" {
+		t.Errorf("Unexpected put operation %+v", put)
+	}
+
+	token := got.ClientRequestToken
+	tx.Run()
+	if got.ClientRequestToken != token {
+		t.Error("Idempotency token should be reused when retrying")
+	}
+}
+
+func TestWriteTxUpdateAndDelete(t *testing.T) {
+	var got TransactWriteRequest
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		got = TransactWriteRequest{}
+		json.Unmarshal(b, &got)
+		w.Write([]byte(`{}`))
+	})
+	defer s.Close()
+
+	doc := &versionedDoc{Id: "a", Name: "second", Version: 1}
+	status := TxCondition{Expression: "#ver = :ver", Names: map[string]string{"#ver": "status"}, Values: AttributeSet{":ver": {S: "open"}}}
+	err := c.WriteTx().
+		Update("things", keyDoc{"a"}, doc, status).
+		Delete("owners", keyDoc{"b"}, TxCondition{Expression: "attribute_exists(id)"}).
+		Run()
+	if err != nil {
+		t.Fatal(err)
+	} else if doc.Version != 2 {
+		t.Errorf("Version should be bumped after the transaction, was %d", doc.Version)
+	}
+	update := got.TransactItems[0].Update
+	if update == nil || update.UpdateExpression != "SET #dynamo_upd0 = :dynamo_upd0, #dynamo_upd1 = :dynamo_upd1" {
+		t.Fatalf("Unexpected update operation %+v", update)
+	}
+	if update.ExpressionAttributeNames["#dynamo_upd0"] != "name" || update.ExpressionAttributeValues[":dynamo_upd1"].N != "2" {
+		t.Errorf("Unexpected update placeholders %+v", update)
+	}
+	if update.ConditionExpression != "(#ver = :ver) AND (#dynamo_ver = :dynamo_ver)" ||
+		update.ExpressionAttributeNames["#ver"] != "status" || update.ExpressionAttributeNames["#dynamo_ver"] != "version" ||
+		update.ExpressionAttributeValues[":dynamo_ver"].N != "1" {
+		t.Errorf("Unexpected update conditions %+v", update)
+	}
+	del := got.TransactItems[1].Delete
+	if del == nil || del.Key["id"].S != "b" || del.ConditionExpression != "(attribute_exists(id))" {
+		t.Errorf("Unexpected delete operation %+v", del)
+	}
+
+	clash := TxCondition{Expression: "#dynamo_ver <> :n", Names: map[string]string{"#dynamo_ver": "name"}, Values: AttributeSet{":n": {S: "x"}}}
+	if err := c.WriteTx().Put("things", &versionedDoc{Id: "a"}, clash).Run(); err == nil {
+		t.Error("Expected a clash of placeholders to fail")
+	}
+}
+
+func TestReadTx(t *testing.T) {
+	var got TransactGetRequest
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &got)
+		w.Write([]byte(`{"Responses":[{"Item":{"id":{"S":"a"},"name":{"S":"first"}}},{}]}`))
+	})
+	defer s.Close()
+
+	items, err := c.ReadTx().Get("things", keyDoc{"a"}, "id", "name").Get("owners", keyDoc{"b"}).Run()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(items) != 2 || items[0]["name"].S != "first" || items[1] != nil {
+		t.Errorf("Unexpected items %v", items)
+	}
+	get := got.TransactItems[0].Get
+	if get.ProjectionExpression != "#p0, #p1" || get.ExpressionAttributeNames["#p1"] != "name" || len(got.TransactItems[1].Get.ProjectionExpression) > 0 {
+		t.Errorf("Unexpected gets %+v", got.TransactItems)
+	}
+	if _, err := c.ReadTx().Run(); err == nil {
+		t.Error("Expected an empty read transaction to fail")
+	}
+}
@@ -0,0 +1,132 @@
+package dynamo
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// ttlHorizon is how far in the past a TTL can be for DynamoDB to delete the item. Items with older TTLs, typically
+// in milliseconds or mistaken for other numbers, never expire.
+const ttlHorizon = 5 * 365 * 24 * time.Hour
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// EnableTTL makes DynamoDB delete the items of a table once the time in attr, in epoch seconds, has passed. It may
+// take up to an hour for the change to apply, see DescribeTTL.
+func (c *Client) EnableTTL(table, attr string) error {
+	return c.updateTTL(table, attr, true)
+}
+
+// DisableTTL stops DynamoDB from deleting the expired items of a table. attr must be the enabled TTL attribute.
+func (c *Client) DisableTTL(table, attr string) error {
+	return c.updateTTL(table, attr, false)
+}
+
+func (c *Client) updateTTL(table, attr string, enabled bool) error {
+	req := UpdateTimeToLiveRequest{
+		TableName:               table,
+		TimeToLiveSpecification: TimeToLiveSpecification{AttributeName: attr, Enabled: enabled},
+	}
+	return c.makeRequest(UpdateTimeToLiveEndpoint, req, &UpdateTimeToLiveResponse{})
+}
+
+// DescribeTTL returns the TTL status of a table, TTLEnabled or TTLDisabled once changes have applied, and its TTL
+// attribute if any.
+func (c *Client) DescribeTTL(table string) (TimeToLiveDescription, error) {
+	res := DescribeTimeToLiveResponse{}
+	err := c.makeRequest(DescribeTimeToLiveEndpoint, BasicRequest{TableName: table}, &res)
+	return res.TimeToLiveDescription, err
+}
+
+// TTLAttribute returns the name of the field of doc, a struct or ptr to struct, tagged `dynamo:",ttl"`, or "" if
+// there's none.
+func TTLAttribute(doc interface{}) string {
+	t := reflect.TypeOf(doc)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return ""
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if tag := parseFieldTag(t.Field(i)); tag.ttl && !tag.ignore {
+			return tag.name
+		}
+	}
+	return ""
+}
+
+// Expired reports whether the TTL in attr of item has passed at now, so DynamoDB will delete the item but may not have
+// yet: deletion usually happens within a couple of days. Items without a TTL, or with one DynamoDB ignores, don't
+// expire.
+func Expired(item AttributeSet, attr string, now time.Time) bool {
+	val, ok := item[attr]
+	if !ok || len(val.N) == 0 {
+		return false
+	}
+	secs, err := strconv.ParseFloat(val.N, 64)
+	if err != nil {
+		return false
+	}
+	ttl := time.Unix(int64(secs), 0)
+	return !ttl.After(now) && now.Sub(ttl) <= ttlHorizon
+}
+
+// FilterExpired returns the items that haven't expired at now, see Expired. The items are filtered in place.
+func FilterExpired(items []AttributeSet, attr string, now time.Time) []AttributeSet {
+	live := items[:0]
+	for _, item := range items {
+		if !Expired(item, attr, now) {
+			live = append(live, item)
+		}
+	}
+	return live
+}
+
+// SkipExpired returns an ItemFunc calling fn with the items that haven't expired at now, for StreamQuery and
+// StreamScan.
+func SkipExpired(attr string, now time.Time, fn ItemFunc) ItemFunc {
+	return func(item AttributeSet) error {
+		if Expired(item, attr, now) {
+			return nil
+		}
+		return fn(item)
+	}
+}
+
+func isTime(v reflect.Value) bool {
+	t := v.Type()
+	return t == timeType || (t.Kind() == reflect.Ptr && t.Elem() == timeType)
+}
+
+// ttlSeconds returns the epoch seconds of a time.Time or *time.Time field, zero if the time is.
+func ttlSeconds(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.ValueOf(int64(0))
+		}
+		v = v.Elem()
+	}
+	t := v.Interface().(time.Time)
+	if t.IsZero() {
+		return reflect.ValueOf(int64(0))
+	}
+	return reflect.ValueOf(t.Unix())
+}
+
+// setTTLSeconds sets a time.Time or *time.Time field from epoch seconds.
+func setTTLSeconds(v reflect.Value, n string) error {
+	secs, err := strconv.ParseInt(n, 10, 64)
+	if err != nil {
+		return err
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(timeType))
+		}
+		v = v.Elem()
+	}
+	v.Set(reflect.ValueOf(time.Unix(secs, 0)))
+	return nil
+}
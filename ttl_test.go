@@ -0,0 +1,58 @@
+package dynamo
+
+import (
+	"testing"
+	"time"
+)
+
+type session struct {
+	ID      string     `dynamo:"id"`
+	Expires time.Time  `dynamo:"expires,ttl"`
+	Renewed *time.Time `dynamo:"renewed,ttl"`
+}
+
+func TestTTLTag(t *testing.T) {
+	expires := time.Unix(1500000000, 0)
+	attrs, err := MarshalAttributes(session{ID: "a", Expires: expires})
+	if err != nil {
+		t.Fatal(err)
+	} else if attrs["expires"].N != "1500000000" {
+		t.Errorf("Marshaled %+v", attrs)
+	} else if _, ok := attrs["renewed"]; ok {
+		t.Errorf("Marshaled a nil time: %+v", attrs)
+	}
+	if attrs, _ := MarshalAttributes(session{ID: "a"}); len(attrs) != 1 {
+		t.Errorf("Marshaled a zero time: %+v", attrs)
+	}
+
+	s := session{}
+	if err := UnmarshalAttributes(AttributeSet{"expires": {N: "1500000000"}, "renewed": {N: "1500000001"}}, &s); err != nil {
+		t.Fatal(err)
+	} else if !s.Expires.Equal(expires) || s.Renewed == nil || s.Renewed.Unix() != 1500000001 {
+		t.Errorf("Unmarshaled %+v", s)
+	}
+	if name := TTLAttribute(&s); name != "expires" {
+		t.Errorf("TTL attribute is %q", name)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	items := []AttributeSet{
+		{"id": {S: "past"}, "expires": {N: "1499999999"}},
+		{"id": {S: "now"}, "expires": {N: "1500000000"}},
+		{"id": {S: "future"}, "expires": {N: "1500000001"}},
+		{"id": {S: "millis"}, "expires": {N: "1499999999000"}},
+		{"id": {S: "ancient"}, "expires": {N: "1"}},
+		{"id": {S: "string"}, "expires": {S: "1"}},
+		{"id": {S: "none"}},
+	}
+	live := FilterExpired(items, "expires", now)
+	ids := []string{}
+	for _, item := range live {
+		ids = append(ids, item["id"].S)
+	}
+	if len(ids) != 5 || ids[0] != "future" || ids[1] != "millis" || ids[2] != "ancient" {
+		t.Errorf("Live items %v", ids)
+	}
+}
@@ -1,5 +1,10 @@
 package dynamo
 
+import (
+	"fmt"
+	"strings"
+)
+
 const (
 	// Query condition operators.
 	ConditionEqual              = "EQ"
@@ -39,9 +44,18 @@ const (
 	ConsumedNone    = "NONE"
 	ConsumedIndexes = "INDEXES"
 
+	// Index projection types.
+	ProjectionAll      = "ALL"
+	ProjectionKeysOnly = "KEYS_ONLY"
+	ProjectionInclude  = "INCLUDE"
+
 	// Commonly encountered errors.
 	ProvisionedThroughputExceededException = "ProvisionedThroughputExceededException"
 	ResourceNotFoundExcpetion              = "ResourceNotFoundException"
+	ConditionalCheckFailedException        = "ConditionalCheckFailedException"
+	TransactionCanceledException           = "TransactionCanceledException"
+	ThrottlingException                    = "ThrottlingException"
+	ValidationException                    = "ValidationException"
 )
 
 // Table-level operations.
@@ -61,15 +75,20 @@ type TableDescriptionWrapper struct {
 }
 
 type TableDescription struct {
-	AttributeDefinitions  []AttributeDefinition
-	CreationDateTime      float64 // Expressed in scientific notation, i.e. 1.3E9, in unix seconds.
-	ItemCount             int
-	KeySchema             []Key
-	LocalSecondaryIndexes []SecondaryIndex
-	ProvisionedThroughput Throughput
-	TableName             string
-	TableSizeBytes        int64
-	TableStatus           string
+	AttributeDefinitions   []AttributeDefinition
+	CreationDateTime       float64 // Expressed in scientific notation, i.e. 1.3E9, in unix seconds.
+	ItemCount              int
+	KeySchema              []Key
+	LocalSecondaryIndexes  []SecondaryIndex
+	GlobalSecondaryIndexes []GlobalSecondaryIndex
+	ProvisionedThroughput  Throughput
+	TableName              string
+	TableSizeBytes         int64
+	TableStatus            string
+	StreamSpecification    *StreamSpecification `json:",omitempty"`
+	LatestStreamArn        string               `json:",omitempty"`
+	LatestStreamLabel      string               `json:",omitempty"`
+	RestoreSummary         *RestoreSummary      `json:",omitempty"` // Only set for restored tables.
 }
 
 type TableRequest struct {
@@ -78,16 +97,40 @@ type TableRequest struct {
 	KeySchema             []Key                 `json:",omitempty"`
 	LocalSecondaryIndexes []SecondaryIndex      `json:",omitempty"`
 	ProvisionedThroughput Throughput
+	StreamSpecification   *StreamSpecification `json:",omitempty"`
 }
 
 // Table attributes.
 
+// Views of the items changed that stream records have.
+const (
+	StreamViewKeysOnly        = "KEYS_ONLY"
+	StreamViewNewImage        = "NEW_IMAGE"
+	StreamViewOldImage        = "OLD_IMAGE"
+	StreamViewNewAndOldImages = "NEW_AND_OLD_IMAGES"
+)
+
+type StreamSpecification struct {
+	StreamEnabled  bool
+	StreamViewType string `json:",omitempty"`
+}
+
 type SecondaryIndex struct {
 	IndexName  string
 	KeySchema  []Key
 	Projection IndexProjection
 }
 
+type GlobalSecondaryIndex struct {
+	IndexName             string
+	KeySchema             []Key
+	Projection            IndexProjection
+	ProvisionedThroughput Throughput
+	IndexStatus           string `json:",omitempty"`
+	IndexSizeBytes        int64  `json:",omitempty"`
+	ItemCount             int    `json:",omitempty"`
+}
+
 type IndexProjection struct {
 	NonKeyAttributes []string
 	ProjectionType   string
@@ -158,7 +201,8 @@ type BasicRequest struct {
 
 type PutRequest struct {
 	BasicRequest
-	Item AttributeSet `json:"Item"`
+	Item     AttributeSet             `json:"Item"`
+	Expected map[string]ExpectedValue `json:",omitempty"`
 }
 
 type Query struct {
@@ -191,12 +235,31 @@ type AttributeUpdate struct {
 
 type ExpectedValue struct {
 	Exists bool
-	Value  AttributeVal
+	Value  *AttributeVal `json:",omitempty"` // Must be nil when Exists is false.
+}
+
+type GetRequest struct {
+	TableName              string
+	Key                    AttributeSet
+	AttributesToGet        []string `json:",omitempty"`
+	ConsistentRead         bool     `json:",omitempty"`
+	ReturnConsumedCapacity string   `json:",omitempty"`
+}
+
+type GetResponse struct {
+	Item             AttributeSet
+	ConsumedCapacity ConsumedStats
+}
+
+type DeleteItemRequest struct {
+	BasicRequest
+	Key      AttributeSet
+	Expected map[string]ExpectedValue `json:",omitempty"`
 }
 
 type UpdateResponse struct {
 	Attributes       AttributeSet
-	ConsumedCapacity []ConsumedStats
+	ConsumedCapacity ConsumedStats
 }
 
 type ScanRequest struct {
@@ -244,8 +307,21 @@ type BatchGetRequest struct {
 }
 
 type ConsumedStats struct {
-	CapacityUnits int
-	TableName     string // TODO: Implement TableName restrictions.
+	CapacityUnits      float64 // May be fractional, i.e. 0.5 for an eventually consistent read.
+	ReadCapacityUnits  float64 `json:",omitempty"`
+	WriteCapacityUnits float64 `json:",omitempty"`
+	TableName          string  // TODO: Implement TableName restrictions.
+
+	// Only returned with ConsumedIndexes.
+	Table                  *Capacity           `json:",omitempty"`
+	LocalSecondaryIndexes  map[string]Capacity `json:",omitempty"`
+	GlobalSecondaryIndexes map[string]Capacity `json:",omitempty"`
+}
+
+type Capacity struct {
+	CapacityUnits      float64
+	ReadCapacityUnits  float64 `json:",omitempty"`
+	WriteCapacityUnits float64 `json:",omitempty"`
 }
 
 type QueryResponse struct {
@@ -277,8 +353,317 @@ type DeleteRequest struct {
 	Key AttributeSet `json:",omitempty"`
 }
 
+// Transactions.
+
+type TransactWriteRequest struct {
+	ClientRequestToken          string `json:",omitempty"`
+	ReturnConsumedCapacity      string `json:",omitempty"`
+	ReturnItemCollectionMetrics string `json:",omitempty"`
+	TransactItems               []TransactWriteItem
+}
+
+type TransactWriteItem struct {
+	// Exactly one of these is set.
+	ConditionCheck *TransactOperation `json:",omitempty"`
+	Delete         *TransactOperation `json:",omitempty"`
+	Put            *TransactOperation `json:",omitempty"`
+	Update         *TransactOperation `json:",omitempty"`
+}
+
+type TransactOperation struct {
+	TableName                 string
+	Item                      AttributeSet      `json:",omitempty"` // Put only.
+	Key                       AttributeSet      `json:",omitempty"` // Everything but Put.
+	UpdateExpression          string            `json:",omitempty"`
+	ConditionExpression       string            `json:",omitempty"`
+	ExpressionAttributeNames  map[string]string `json:",omitempty"`
+	ExpressionAttributeValues AttributeSet      `json:",omitempty"`
+}
+
+type TransactGetRequest struct {
+	ReturnConsumedCapacity string `json:",omitempty"`
+	TransactItems          []TransactGetItem
+}
+
+type TransactGetItem struct {
+	Get TransactGet
+}
+
+type TransactGet struct {
+	TableName                string
+	Key                      AttributeSet
+	ProjectionExpression     string            `json:",omitempty"`
+	ExpressionAttributeNames map[string]string `json:",omitempty"`
+}
+
+type TransactGetResponse struct {
+	ConsumedCapacity []ConsumedStats
+	Responses        []struct {
+		Item AttributeSet
+	}
+}
+
+type TransactWriteResponse struct {
+	ConsumedCapacity []ConsumedStats
+}
+
+// Time to live.
+
+const (
+	TTLEnabled   = "ENABLED"
+	TTLEnabling  = "ENABLING"
+	TTLDisabled  = "DISABLED"
+	TTLDisabling = "DISABLING"
+)
+
+type TimeToLiveSpecification struct {
+	AttributeName string
+	Enabled       bool
+}
+
+type UpdateTimeToLiveRequest struct {
+	TableName               string
+	TimeToLiveSpecification TimeToLiveSpecification
+}
+
+type UpdateTimeToLiveResponse struct {
+	TimeToLiveSpecification TimeToLiveSpecification
+}
+
+type TimeToLiveDescription struct {
+	AttributeName    string `json:",omitempty"`
+	TimeToLiveStatus string
+}
+
+type DescribeTimeToLiveResponse struct {
+	TimeToLiveDescription TimeToLiveDescription
+}
+
+// Backups.
+
+const (
+	BackupCreating  = "CREATING"
+	BackupAvailable = "AVAILABLE"
+	BackupDeleted   = "DELETED"
+
+	// Types of backups, BackupTypeAll lists them all.
+	BackupTypeUser      = "USER"
+	BackupTypeSystem    = "SYSTEM"
+	BackupTypeAWSBackup = "AWS_BACKUP"
+	BackupTypeAll       = "ALL"
+
+	// Statuses of continuous backups and point in time recovery.
+	ContinuousBackupsEnabled  = "ENABLED"
+	ContinuousBackupsDisabled = "DISABLED"
+)
+
+type BackupDetails struct {
+	BackupArn              string
+	BackupName             string
+	BackupSizeBytes        int64
+	BackupStatus           string
+	BackupType             string
+	BackupCreationDateTime float64
+	BackupExpiryDateTime   float64 `json:",omitempty"` // Of system backups.
+}
+
+type BackupSummary struct {
+	TableName              string
+	TableId                string
+	TableArn               string
+	BackupArn              string
+	BackupName             string
+	BackupSizeBytes        int64
+	BackupStatus           string
+	BackupType             string
+	BackupCreationDateTime float64
+	BackupExpiryDateTime   float64 `json:",omitempty"`
+}
+
+// SourceTableDetails describe the table of a backup when it was made.
+type SourceTableDetails struct {
+	TableName             string
+	TableId               string
+	TableArn              string
+	TableSizeBytes        int64
+	ItemCount             int64
+	KeySchema             []Key
+	ProvisionedThroughput Throughput
+	TableCreationDateTime float64
+	BillingMode           string `json:",omitempty"`
+}
+
+type BackupDescription struct {
+	BackupDetails      BackupDetails
+	SourceTableDetails SourceTableDetails
+}
+
+type RestoreSummary struct {
+	SourceBackupArn   string `json:",omitempty"`
+	SourceTableArn    string `json:",omitempty"`
+	RestoreDateTime   float64
+	RestoreInProgress bool
+}
+
+type CreateBackupRequest struct {
+	TableName  string
+	BackupName string
+}
+
+type CreateBackupResponse struct {
+	BackupDetails BackupDetails
+}
+
+type ListBackupsRequest struct {
+	TableName               string  `json:",omitempty"`
+	BackupType              string  `json:",omitempty"`
+	TimeRangeLowerBound     float64 `json:",omitempty"`
+	TimeRangeUpperBound     float64 `json:",omitempty"`
+	ExclusiveStartBackupArn string  `json:",omitempty"`
+	Limit                   int     `json:",omitempty"`
+}
+
+type ListBackupsResponse struct {
+	BackupSummaries        []BackupSummary
+	LastEvaluatedBackupArn string
+}
+
+// BackupRequest is the request of DescribeBackup and DeleteBackup.
+type BackupRequest struct {
+	BackupArn string
+}
+
+// BackupResponse is the response of DescribeBackup and DeleteBackup.
+type BackupResponse struct {
+	BackupDescription BackupDescription
+}
+
+type RestoreTableFromBackupRequest struct {
+	TargetTableName string
+	BackupArn       string
+}
+
+type PointInTimeRecoverySpecification struct {
+	PointInTimeRecoveryEnabled bool
+}
+
+type UpdateContinuousBackupsRequest struct {
+	TableName                        string
+	PointInTimeRecoverySpecification PointInTimeRecoverySpecification
+}
+
+type PointInTimeRecoveryDescription struct {
+	PointInTimeRecoveryStatus  string
+	EarliestRestorableDateTime float64 `json:",omitempty"`
+	LatestRestorableDateTime   float64 `json:",omitempty"`
+}
+
+type ContinuousBackupsDescription struct {
+	ContinuousBackupsStatus        string
+	PointInTimeRecoveryDescription PointInTimeRecoveryDescription
+}
+
+// ContinuousBackupsResponse is the response of DescribeContinuousBackups and UpdateContinuousBackups.
+type ContinuousBackupsResponse struct {
+	ContinuousBackupsDescription ContinuousBackupsDescription
+}
+
+type RestoreTableToPointInTimeRequest struct {
+	SourceTableName         string
+	TargetTableName         string
+	RestoreDateTime         float64 `json:",omitempty"`
+	UseLatestRestorableTime bool    `json:",omitempty"`
+}
+
+// PartiQL.
+
+type ExecuteStatementRequest struct {
+	Statement              string
+	Parameters             []AttributeVal `json:",omitempty"`
+	ConsistentRead         bool           `json:",omitempty"`
+	Limit                  int            `json:",omitempty"`
+	NextToken              string         `json:",omitempty"`
+	ReturnConsumedCapacity string         `json:",omitempty"`
+}
+
+type ExecuteStatementResponse struct {
+	ConsumedCapacity ConsumedStats
+	Items            []AttributeSet
+	LastEvaluatedKey AttributeSet
+	NextToken        string
+}
+
+type BatchStatementRequest struct {
+	Statement      string
+	Parameters     []AttributeVal `json:",omitempty"`
+	ConsistentRead bool           `json:",omitempty"`
+}
+
+type BatchExecuteStatementRequest struct {
+	Statements             []BatchStatementRequest
+	ReturnConsumedCapacity string `json:",omitempty"`
+}
+
+type BatchExecuteStatementResponse struct {
+	ConsumedCapacity []ConsumedStats
+	Responses        []BatchStatementResponse
+}
+
+// BatchStatementResponse is the result of a statement of a batch: its item for reads, or its error if it failed.
+type BatchStatementResponse struct {
+	Item      AttributeSet         `json:",omitempty"`
+	TableName string               `json:",omitempty"`
+	Error     *BatchStatementError `json:",omitempty"`
+}
+
+type BatchStatementError struct {
+	Code    string
+	Message string
+	Item    AttributeSet `json:",omitempty"`
+}
+
+type ParameterizedStatement struct {
+	Statement  string
+	Parameters []AttributeVal `json:",omitempty"`
+}
+
+type ExecuteTransactionRequest struct {
+	TransactStatements     []ParameterizedStatement
+	ClientRequestToken     string `json:",omitempty"`
+	ReturnConsumedCapacity string `json:",omitempty"`
+}
+
+type ExecuteTransactionResponse struct {
+	ConsumedCapacity []ConsumedStats
+	Responses        []struct {
+		Item AttributeSet
+	}
+}
+
+type CancellationReason struct {
+	Code    string // "None" if the operation didn't cause the cancellation.
+	Message string
+	Item    AttributeSet
+}
+
 type Error struct {
-	StatusCode int
-	Type       string `json:"__type"`
-	Message    string `json:"message"`
+	StatusCode          int
+	Type                string               `json:"__type"`
+	Message             string               `json:"message"`
+	CancellationReasons []CancellationReason `json:",omitempty"` // Only set for TransactionCanceledException.
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("Status code %d returned: %s: %s", e.StatusCode, e.Type, e.Message)
+}
+
+// Code returns the error type without its namespace, i.e. "ConditionalCheckFailedException".
+func (e *Error) Code() string {
+	return e.Type[strings.LastIndex(e.Type, "#")+1:]
+}
+
+// IsErrorCode reports whether err was returned by DynamoDB with the given error type.
+func IsErrorCode(err error, code string) bool {
+	e, ok := err.(*Error)
+	return ok && e.Code() == code
 }
@@ -0,0 +1,165 @@
+package dynamo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+)
+
+// UnmarshalAttributes sets the fields of the struct dst points to from attr, the reverse of MarshalAttributes. Fields
+// without a matching attribute are left alone.
+func UnmarshalAttributes(attr AttributeSet, dst interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+			err = fmt.Errorf("Error: %v", r)
+		}
+	}()
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Destination was not a non-nil ptr to struct, was %v", reflect.TypeOf(dst))
+	}
+	v = v.Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if len(f.PkgPath) > 0 {
+			continue // Unexported.
+		}
+		tag := parseFieldTag(f)
+		if tag.ignore {
+			continue
+		}
+		val, ok := attr[tag.name]
+		if !ok {
+			continue
+		}
+		if tag.ttl && isTime(v.Field(i)) {
+			err = setTTLSeconds(v.Field(i), val.N)
+		} else {
+			err = setAttribute(v.Field(i), val)
+		}
+		if err != nil {
+			return fmt.Errorf("Attribute %s: %s", tag.name, err.Error())
+		}
+	}
+	return nil
+}
+
+// unmarshalItems unmarshals items into dst, a ptr to a slice of structs or of ptrs to structs.
+func unmarshalItems(items []AttributeSet, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("Destination was not a non-nil ptr to slice, was %v", reflect.TypeOf(dst))
+	}
+	elem := v.Elem().Type().Elem()
+	isPtr := elem.Kind() == reflect.Ptr
+	if isPtr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return fmt.Errorf("Destination was not a slice of structs, was %v", v.Elem().Type())
+	}
+	slice := reflect.MakeSlice(v.Elem().Type(), 0, len(items))
+	for _, item := range items {
+		doc := reflect.New(elem)
+		if err := UnmarshalAttributes(item, doc.Interface()); err != nil {
+			return err
+		}
+		if isPtr {
+			slice = reflect.Append(slice, doc)
+		} else {
+			slice = reflect.Append(slice, doc.Elem())
+		}
+	}
+	v.Elem().Set(slice)
+	return nil
+}
+
+func setAttribute(v reflect.Value, val AttributeVal) error {
+	switch {
+	case len(val.SS) > 0:
+		return setStringArray(v, val.SS)
+	case len(val.NS) > 0:
+		return setStringArray(v, val.NS)
+	case len(val.BS) > 0:
+		return setStringArray(v, val.BS)
+	case len(val.N) > 0:
+		return setStringValue(v, val.N)
+	case len(val.B) > 0:
+		return setStringValue(v, val.B)
+	}
+	return setStringValue(v, val.S)
+}
+
+func setStringValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		v.SetBool(s == "1" || s == "true")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Struct, reflect.Map, reflect.Array, reflect.Slice:
+		// Stored as JSON by getStringValue.
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setStringValue(v.Elem(), s)
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			return fmt.Errorf("Cannot set %v", v.Type())
+		}
+		v.Set(reflect.ValueOf(s))
+	default:
+		return fmt.Errorf("Cannot set %v", v.Type())
+	}
+	return nil
+}
+
+func setStringArray(v reflect.Value, vals []string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), len(vals), len(vals)))
+	case reflect.Array:
+		if len(vals) > v.Len() {
+			return fmt.Errorf("Set of %d values doesn't fit in %v", len(vals), v.Type())
+		}
+	default:
+		return fmt.Errorf("Cannot set a set of values to %v", v.Type())
+	}
+	for i, s := range vals {
+		if err := setStringValue(v.Index(i), s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
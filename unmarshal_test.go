@@ -0,0 +1,46 @@
+package dynamo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type unmarshalDoc struct {
+	Id      string            `dynamo:"id"`
+	Count   int               `dynamo:"count"`
+	Score   float64           `dynamo:"score"`
+	Active  bool              `dynamo:"active"`
+	Tags    []string          `dynamo:"tags"`
+	Nums    []int             `dynamo:"nums"`
+	Owner   *string           `dynamo:"owner"`
+	Meta    map[string]string `dynamo:"meta"`
+	Created time.Time         `dynamo:"created"`
+	Skipped string            `dynamo:"-"`
+}
+
+func TestUnmarshalAttributes(t *testing.T) {
+	owner := "joy"
+	doc := unmarshalDoc{
+		Id: "a", Count: -3, Score: 1.5, Active: true, Tags: []string{"x", "y"}, Nums: []int{1, 2}, Owner: &owner,
+		Meta: map[string]string{"k": "v"}, Created: time.Date(2014, 3, 1, 12, 0, 0, 0, time.UTC), Skipped: "skipped",
+	}
+	attr, err := MarshalAttributes(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := unmarshalDoc{}
+	if err := UnmarshalAttributes(attr, &got); err != nil {
+		t.Fatal(err)
+	}
+	doc.Skipped = ""
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("Expected %+v, got %+v", doc, got)
+	}
+	if err := UnmarshalAttributes(attr, got); err == nil {
+		t.Error("Expected error unmarshaling into a struct value")
+	}
+	if err := UnmarshalAttributes(AttributeSet{"count": {N: "1.5"}}, &got); err == nil {
+		t.Error("Expected error unmarshaling a fraction into an int")
+	}
+}
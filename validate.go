@@ -0,0 +1,381 @@
+package dynamo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxAttributeNameLength = 65535
+	minNumberExponent      = -130 // Smallest magnitude is 1E-130.
+	maxNumberExponent      = 125  // Largest magnitude is 9.99...E+125.
+)
+
+var tableNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// ValidationError is a problem with a single field of a request, found before sending it.
+type ValidationError struct {
+	Field   string // i.e. "TableName" or "Item.price".
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors holds every problem found in a request.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, v := range e {
+		msgs[i] = v.Error()
+	}
+	return "Invalid request: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns nil if there were no problems, so a nil ValidationErrors isn't returned as a non-nil error.
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// ValidateTableName checks a table, index or backup name is 3 to 255 characters of a-z, A-Z, 0-9, '_', '-' and '.'.
+func ValidateTableName(name string) error {
+	if len(name) < minTableLength || len(name) > maxTableLength {
+		return fmt.Errorf("Name %q must be between %d and %d characters", name, minTableLength, maxTableLength)
+	} else if !tableNameRegex.MatchString(name) {
+		return fmt.Errorf("Name %q may only contain a-z, A-Z, 0-9, '_', '-' and '.'", name)
+	}
+	return nil
+}
+
+// ValidateNumber checks n is a number DynamoDB can store: at most 38 significant digits, with a magnitude between
+// 1E-130 and 9.99...E+125.
+func ValidateNumber(n string) error {
+	if !NumberRegex.MatchString(n) {
+		return fmt.Errorf("%q is not a number", n)
+	}
+	mantissa, exp := strings.TrimPrefix(n, "-"), 0
+	if i := strings.IndexAny(mantissa, "eE"); i >= 0 {
+		exp, _ = strconv.Atoi(mantissa[i+1:])
+		mantissa = mantissa[:i]
+	}
+	intPart, fracPart := mantissa, ""
+	if i := strings.Index(mantissa, "."); i >= 0 {
+		intPart, fracPart = mantissa[:i], mantissa[i+1:]
+	}
+	digits := strings.TrimLeft(intPart+fracPart, "0")
+	if len(digits) == 0 {
+		return nil // Zero.
+	}
+	// The exponent of the first significant digit, i.e. 1 for 12.3 and -2 for 0.0123.
+	exp += len(intPart) - (len(intPart+fracPart) - len(digits)) - 1
+	if significant := len(strings.TrimRight(digits, "0")); significant > numDigitsPrecision {
+		return fmt.Errorf("%q has %d significant digits, the maximum is %d", n, significant, numDigitsPrecision)
+	} else if exp < minNumberExponent || exp > maxNumberExponent {
+		return fmt.Errorf("%q is out of range, magnitude must be between 1E%d and 1E%d", n, minNumberExponent, maxNumberExponent+1)
+	}
+	return nil
+}
+
+// ValidateAttributeName checks an attribute name isn't empty or too long. Reserved words are valid attribute names,
+// but can't be used in expressions without a placeholder, see IsReservedWord.
+func ValidateAttributeName(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("Attribute name may not be empty")
+	} else if len(name) > maxAttributeNameLength {
+		return fmt.Errorf("Attribute name is %d bytes, the maximum is %d", len(name), maxAttributeNameLength)
+	}
+	return nil
+}
+
+// IsReservedWord reports whether name is a DynamoDB reserved word, which must be replaced by an expression attribute
+// name placeholder like "#name" in expressions.
+func IsReservedWord(name string) bool {
+	return reservedWords[strings.ToUpper(name)]
+}
+
+// validator is implemented by requests that can be checked before being sent.
+type validator interface {
+	validate() ValidationErrors
+}
+
+func (e *ValidationErrors) table(field, name string) {
+	if err := ValidateTableName(name); err != nil {
+		e.add(field, "%s", err)
+	}
+}
+
+func (e *ValidationErrors) index(field, name string) {
+	if len(name) > 0 {
+		e.table(field, name)
+	}
+}
+
+func (e *ValidationErrors) attributes(field string, attr AttributeSet) {
+	for name, val := range attr {
+		e.value(field+"."+name, val)
+		if err := ValidateAttributeName(name); err != nil {
+			e.add(field, "%s", err)
+		}
+	}
+}
+
+func (e *ValidationErrors) value(field string, val AttributeVal) {
+	if len(val.N) > 0 {
+		if err := ValidateNumber(val.N); err != nil {
+			e.add(field, "%s", err)
+		}
+	}
+	for _, n := range val.NS {
+		if err := ValidateNumber(n); err != nil {
+			e.add(field, "%s", err)
+		}
+	}
+}
+
+func (e *ValidationErrors) conditions(field string, conds map[string]Condition) {
+	for name, c := range conds {
+		for _, val := range c.AttributeValueList {
+			e.value(field+"."+name, val)
+		}
+	}
+}
+
+// expression checks that attribute names in expr that are reserved words use placeholders.
+func (e *ValidationErrors) expression(field, expr string) {
+	for _, w := range reservedIdentifiers(expr) {
+		e.add(field, "%q is a reserved word, use an expression attribute name placeholder instead", w)
+	}
+}
+
+// reservedIdentifiers returns the bare attribute names in expr that are reserved words. Placeholders, function calls
+// and the operators of the expression syntax are skipped.
+func reservedIdentifiers(expr string) []string {
+	var words []string
+	isIdent := func(b byte) bool {
+		return b == '_' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+	}
+	for i := 0; i < len(expr); {
+		if !isIdent(expr[i]) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(expr) && isIdent(expr[i]) {
+			i++
+		}
+		word := expr[start:i]
+		if start > 0 && (expr[start-1] == '#' || expr[start-1] == ':') {
+			continue
+		} else if strings.HasPrefix(strings.TrimLeft(expr[i:], " "), "(") {
+			continue
+		}
+		switch strings.ToUpper(word) {
+		case "AND", "OR", "NOT", "BETWEEN", "IN":
+			continue
+		}
+		if IsReservedWord(word) {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+func (r BasicRequest) validate() (e ValidationErrors) {
+	e.table("TableName", r.TableName)
+	return
+}
+
+func (r PutRequest) validate() (e ValidationErrors) {
+	e.table("TableName", r.TableName)
+	e.attributes("Item", r.Item)
+	for name, exp := range r.Expected {
+		if exp.Value != nil {
+			e.value("Expected."+name, *exp.Value)
+		}
+	}
+	return
+}
+
+func (r Update) validate() (e ValidationErrors) {
+	e.table("TableName", r.TableName)
+	e.attributes("Key", r.Key)
+	for name, u := range r.AttributeUpdates {
+		e.value("AttributeUpdates."+name, u.Value)
+		if err := ValidateAttributeName(name); err != nil {
+			e.add("AttributeUpdates", "%s", err)
+		}
+	}
+	for name, exp := range r.Expected {
+		if exp.Value != nil {
+			e.value("Expected."+name, *exp.Value)
+		}
+	}
+	return
+}
+
+func (r GetRequest) validate() (e ValidationErrors) {
+	e.table("TableName", r.TableName)
+	e.attributes("Key", r.Key)
+	return
+}
+
+func (r DeleteItemRequest) validate() (e ValidationErrors) {
+	e.table("TableName", r.TableName)
+	e.attributes("Key", r.Key)
+	return
+}
+
+func (r Query) validate() (e ValidationErrors) {
+	e.table("TableName", r.TableName)
+	e.index("IndexName", r.IndexName)
+	e.conditions("KeyConditions", r.KeyConditions)
+	e.attributes("ExclusiveStartKey", r.ExclusiveStartKey)
+	return
+}
+
+func (r ScanRequest) validate() (e ValidationErrors) {
+	e.table("TableName", r.TableName)
+	e.conditions("ScanFilter", r.ScanFilter)
+	e.attributes("ExclusiveStartKey", r.ExclusiveStartKey)
+	return
+}
+
+func (r CreateBackupRequest) validate() (e ValidationErrors) {
+	e.table("TableName", r.TableName)
+	e.table("BackupName", r.BackupName)
+	return
+}
+
+func (r RestoreTableFromBackupRequest) validate() (e ValidationErrors) {
+	e.table("TargetTableName", r.TargetTableName)
+	return
+}
+
+func (r RestoreTableToPointInTimeRequest) validate() (e ValidationErrors) {
+	e.table("SourceTableName", r.SourceTableName)
+	e.table("TargetTableName", r.TargetTableName)
+	return
+}
+
+func (r TableRequest) validate() (e ValidationErrors) {
+	e.table("TableName", r.TableName)
+	for i, def := range r.AttributeDefinitions {
+		if !isValidType(def.Type) {
+			e.add(fmt.Sprintf("AttributeDefinitions[%d]", i), "%q is invalid attribute type", def.Type)
+		}
+	}
+	for i, idx := range r.LocalSecondaryIndexes {
+		e.table(fmt.Sprintf("LocalSecondaryIndexes[%d].IndexName", i), idx.IndexName)
+	}
+	return
+}
+
+func (r BatchWriteRequest) validate() (e ValidationErrors) {
+	for table, items := range r.RequestItems {
+		e.table("RequestItems", table)
+		for i, item := range items {
+			field := fmt.Sprintf("RequestItems.%s[%d]", table, i)
+			if item.PutRequest != nil {
+				e.attributes(field+".Item", item.PutRequest.Item)
+			}
+			if item.DeleteRequest != nil {
+				e.attributes(field+".Key", item.DeleteRequest.Key)
+			}
+		}
+	}
+	return
+}
+
+func (r BatchGetRequest) validate() (e ValidationErrors) {
+	for table, item := range r.RequestItems {
+		e.table("RequestItems", table)
+		for i, key := range item.Keys {
+			e.attributes(fmt.Sprintf("RequestItems.%s.Keys[%d]", table, i), key)
+		}
+	}
+	return
+}
+
+func (r TransactWriteRequest) validate() (e ValidationErrors) {
+	for i, item := range r.TransactItems {
+		for _, op := range []*TransactOperation{item.ConditionCheck, item.Delete, item.Put, item.Update} {
+			if op == nil {
+				continue
+			}
+			field := fmt.Sprintf("TransactItems[%d]", i)
+			e.table(field+".TableName", op.TableName)
+			e.attributes(field+".Item", op.Item)
+			e.attributes(field+".Key", op.Key)
+			e.attributes(field+".ExpressionAttributeValues", op.ExpressionAttributeValues)
+			e.expression(field+".ConditionExpression", op.ConditionExpression)
+		}
+	}
+	return
+}
+
+func (r TransactGetRequest) validate() (e ValidationErrors) {
+	for i, item := range r.TransactItems {
+		field := fmt.Sprintf("TransactItems[%d]", i)
+		e.table(field+".TableName", item.Get.TableName)
+		e.attributes(field+".Key", item.Get.Key)
+	}
+	return
+}
+
+var reservedWords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`
+		ABORT ABSOLUTE ACTION ADD AFTER AGENT AGGREGATE ALL ALLOCATE ALTER ANALYZE AND ANY ARCHIVE ARE ARRAY AS ASC
+		ASCII ASENSITIVE ASSERTION ASYMMETRIC AT ATOMIC ATTACH ATTRIBUTE AUTH AUTHORIZATION AUTHORIZE AUTO AVG BACK
+		BACKUP BASE BATCH BEFORE BEGIN BETWEEN BIGINT BINARY BIT BLOB BLOCK BOOLEAN BOTH BREADTH BUCKET BULK BY BYTE
+		CALL CALLED CALLING CAPACITY CASCADE CASCADED CASE CAST CATALOG CHAR CHARACTER CHECK CLASS CLOB CLOSE CLUSTER
+		CLUSTERED CLUSTERING CLUSTERS COALESCE COLLATE COLLATION COLLECTION COLUMN COLUMNS COMBINE COMMENT COMMIT
+		COMPACT COMPILE COMPRESS CONDITION CONFLICT CONNECT CONNECTION CONSISTENCY CONSISTENT CONSTRAINT CONSTRAINTS
+		CONSTRUCTOR CONSUMED CONTINUE CONVERT COPY CORRESPONDING COUNT COUNTER CREATE CROSS CUBE CURRENT CURSOR CYCLE
+		DATA DATABASE DATE DATETIME DAY DEALLOCATE DEC DECIMAL DECLARE DEFAULT DEFERRABLE DEFERRED DEFINE DEFINED
+		DEFINITION DELETE DELIMITED DEPTH DEREF DESC DESCRIBE DESCRIPTOR DETACH DETERMINISTIC DIAGNOSTICS DIRECTORIES
+		DISABLE DISCONNECT DISTINCT DISTRIBUTE DO DOMAIN DOUBLE DROP DUMP DURATION DYNAMIC EACH ELEMENT ELSE ELSEIF
+		EMPTY ENABLE END EQUAL EQUALS ERROR ESCAPE ESCAPED EVAL EVALUATE EXCEEDED EXCEPT EXCEPTION EXCEPTIONS
+		EXCLUSIVE EXEC EXECUTE EXISTS EXIT EXPLAIN EXPLODE EXPORT EXPRESSION EXTENDED EXTERNAL EXTRACT FAIL FALSE
+		FAMILY FETCH FIELDS FILE FILTER FILTERING FINAL FINISH FIRST FIXED FLATTERN FLOAT FOR FORCE FOREIGN FORMAT
+		FORWARD FOUND FREE FROM FULL FUNCTION FUNCTIONS GENERAL GENERATE GET GLOB GLOBAL GO GOTO GRANT GREATER GROUP
+		GROUPING HANDLER HASH HAVE HAVING HEAP HIDDEN HOLD HOUR IDENTIFIED IDENTITY IF IGNORE IMMEDIATE IMPORT IN
+		INCLUDING INCLUSIVE INCREMENT INCREMENTAL INDEX INDEXED INDEXES INDICATOR INFINITE INITIALLY INLINE INNER
+		INNTER INOUT INPUT INSENSITIVE INSERT INSTEAD INT INTEGER INTERSECT INTERVAL INTO INVALIDATE IS ISOLATION
+		ITEM ITEMS ITERATE JOIN KEY KEYS LAG LANGUAGE LARGE LAST LATERAL LEAD LEADING LEAVE LEFT LENGTH LESS LEVEL
+		LIKE LIMIT LIMITED LINES LIST LOAD LOCAL LOCALTIME LOCALTIMESTAMP LOCATION LOCATOR LOCK LOCKS LOG LOGED LONG
+		LOOP LOWER MAP MATCH MATERIALIZED MAX MAXLEN MEMBER MERGE METHOD METRICS MIN MINUS MINUTE MISSING MOD MODE
+		MODIFIES MODIFY MODULE MONTH MULTI MULTISET NAME NAMES NATIONAL NATURAL NCHAR NCLOB NEW NEXT NO NONE NOT NULL
+		NULLIF NUMBER NUMERIC OBJECT OF OFFLINE OFFSET OLD ON ONLINE ONLY OPAQUE OPEN OPERATOR OPTION OR ORDER
+		ORDINALITY OTHER OTHERS OUT OUTER OUTPUT OVER OVERLAPS OVERRIDE OWNER PAD PARALLEL PARAMETER PARAMETERS
+		PARTIAL PARTITION PARTITIONED PARTITIONS PATH PERCENT PERCENTILE PERMISSION PERMISSIONS PIPE PIPELINED PLAN
+		POOL POSITION PRECISION PREPARE PRESERVE PRIMARY PRIOR PRIVATE PRIVILEGES PROCEDURE PROCESSED PROJECT
+		PROJECTION PROPERTY PROVISIONING PUBLIC PUT QUERY QUIT QUORUM RAISE RANDOM RANGE RANK RAW READ READS REAL
+		REBUILD RECORD RECURSIVE REDUCE REF REFERENCE REFERENCES REFERENCING REGEXP REGION REINDEX RELATIVE RELEASE
+		REMAINDER RENAME REPEAT REPLACE REQUEST RESET RESIGNAL RESOURCE RESPONSE RESTORE RESTRICT RESULT RETURN
+		RETURNING RETURNS REVERSE REVOKE RIGHT ROLE ROLES ROLLBACK ROLLUP ROUTINE ROW ROWS RULE RULES SAMPLE
+		SATISFIES SAVE SAVEPOINT SCAN SCHEMA SCOPE SCROLL SEARCH SECOND SECTION SEGMENT SEGMENTS SELECT SELF SEMI
+		SENSITIVE SEPARATE SEQUENCE SERIALIZABLE SESSION SET SETS SHARD SHARE SHARED SHORT SHOW SIGNAL SIMILAR SIZE
+		SKEWED SMALLINT SNAPSHOT SOME SOURCE SPACE SPACES SPARSE SPECIFIC SPECIFICTYPE SPLIT SQL SQLCODE SQLERROR
+		SQLEXCEPTION SQLSTATE SQLWARNING START STATE STATIC STATUS STORAGE STORE STORED STREAM STRING STRUCT STYLE
+		SUB SUBMULTISET SUBPARTITION SUBSTRING SUBTYPE SUM SUPER SYMMETRIC SYNONYM SYSTEM TABLE TABLESAMPLE TEMP
+		TEMPORARY TERMINATED TEXT THAN THEN THROUGHPUT TIME TIMESTAMP TIMEZONE TINYINT TO TOKEN TOTAL TOUCH TRAILING
+		TRANSACTION TRANSFORM TRANSLATE TRANSLATION TREAT TRIGGER TRIM TRUE TRUNCATE TTL TUPLE TYPE UNDER UNDO UNION
+		UNIQUE UNIT UNKNOWN UNLOGGED UNNEST UNPROCESSED UNSIGNED UNTIL UPDATE UPPER URL USAGE USE USER USERS USING
+		UUID VACUUM VALUE VALUED VALUES VARCHAR VARIABLE VARIANCE VARINT VARYING VIEW VIEWS VIRTUAL VOID WAIT WHEN
+		WHENEVER WHERE WHILE WINDOW WITH WITHIN WITHOUT WORK WRAPPED WRITE YEAR ZONE`) {
+		reservedWords[w] = true
+	}
+}
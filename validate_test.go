@@ -0,0 +1,65 @@
+package dynamo
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateNumber(t *testing.T) {
+	valid := []string{"0", "-0.0", "12.3", "1E-130", "-9.9999999999999999999999999999999999999E+125", "12345678901234567890123456789012345678", "1000000000000000000000000000000000000000000", ".5"}
+	for _, n := range valid {
+		if err := ValidateNumber(n); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", n, err)
+		}
+	}
+	invalid := []string{"", "abc", "1.2.3", "1E-131", "1E126", "123456789012345678901234567890123456789", "--1"}
+	for _, n := range invalid {
+		if err := ValidateNumber(n); err == nil {
+			t.Errorf("Expected %q to be invalid", n)
+		}
+	}
+}
+
+func TestValidateTableName(t *testing.T) {
+	for _, name := range []string{"abc", "my-table_v1.2"} {
+		if err := ValidateTableName(name); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"ab", "my table", "tab$le"} {
+		if err := ValidateTableName(name); err == nil {
+			t.Errorf("Expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestReservedIdentifiers(t *testing.T) {
+	words := reservedIdentifiers("attribute_exists(#name) AND status = :s AND size(tags) > :n OR NOT count BETWEEN :a AND :b")
+	if len(words) != 2 || words[0] != "status" || words[1] != "count" {
+		t.Errorf("Expected status and count to be reported, got %v", words)
+	}
+}
+
+func TestValidationBeforeSending(t *testing.T) {
+	sent := false
+	c, s := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		sent = true
+	})
+	defer s.Close()
+	err := c.UpdateItemRaw("x", AttributeSet{"id": {S: "a"}}, AttributeSet{"n": {N: "1E200"}}, UpdateTypePut)
+	errs, ok := err.(ValidationErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("Expected 2 validation errors, got %v", err)
+	}
+	if sent {
+		t.Error("Invalid request should not be sent")
+	}
+}
+
+func TestValidationMessageKeepsPercent(t *testing.T) {
+	e := BasicRequest{TableName: "100%done"}.validate()
+	if len(e) != 1 || !strings.Contains(e[0].Message, `"100%done"`) {
+		t.Errorf("Unexpected validation errors %v", e)
+	}
+}
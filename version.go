@@ -0,0 +1,88 @@
+package dynamo
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// ErrVersionConflict is returned by PutItem and UpdateItem when the stored version of an item no longer matches the
+// version field of the document being written, i.e. someone else wrote the item since it was read.
+var ErrVersionConflict = errors.New("Version conflict: item was modified since it was read")
+
+// version is the field of a document tagged `dynamo:",version"`, used for optimistic locking.
+type version struct {
+	name  string
+	value reflect.Value
+}
+
+// getVersion returns the version field of doc, or nil if it doesn't have one.
+func getVersion(doc interface{}) (*version, error) {
+	v := reflect.ValueOf(doc)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, nil
+	}
+	t := v.Type()
+	var ver *version
+	for i := 0; i < t.NumField(); i++ {
+		tag := parseFieldTag(t.Field(i))
+		if tag.ignore || !tag.version {
+			continue
+		} else if ver != nil {
+			return nil, errors.New("Multiple fields are tagged as version")
+		}
+		switch k := v.Field(i).Kind(); k {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			return nil, fmt.Errorf("Version field %q must be an integer, was %v", tag.name, k)
+		}
+		ver = &version{name: tag.name, value: v.Field(i)}
+	}
+	return ver, nil
+}
+
+func (ver *version) current() int64 {
+	switch ver.value.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(ver.value.Uint())
+	}
+	return ver.value.Int()
+}
+
+// expected returns the condition that the stored version equals the in-memory one. A zero version means the item
+// has never been written, so it must not exist yet.
+func (ver *version) expected() map[string]ExpectedValue {
+	n := ver.current()
+	if n == 0 {
+		return map[string]ExpectedValue{ver.name: {Exists: false}}
+	}
+	return map[string]ExpectedValue{ver.name: {Exists: true, Value: &AttributeVal{N: strconv.FormatInt(n, 10)}}}
+}
+
+// next returns the attribute value to store as the new version.
+func (ver *version) next() AttributeVal {
+	return AttributeVal{N: strconv.FormatInt(ver.current()+1, 10)}
+}
+
+// result translates a failed condition into ErrVersionConflict, and bumps the in-memory version after a successful
+// write if the document was passed by pointer.
+func (ver *version) result(err error) error {
+	if ver == nil {
+		return err
+	} else if IsErrorCode(err, ConditionalCheckFailedException) {
+		return ErrVersionConflict
+	} else if err == nil && ver.value.CanSet() {
+		switch ver.value.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			ver.value.SetUint(ver.value.Uint() + 1)
+		default:
+			ver.value.SetInt(ver.value.Int() + 1)
+		}
+	}
+	return err
+}